@@ -1,15 +1,23 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
+	"errors"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/adi-253/Talkie/backend/internal/config"
 	"github.com/adi-253/Talkie/backend/internal/handlers"
+	"github.com/adi-253/Talkie/backend/internal/logging"
+	"github.com/adi-253/Talkie/backend/internal/ratelimit"
 	"github.com/adi-253/Talkie/backend/internal/services"
 	"github.com/adi-253/Talkie/backend/internal/supabase"
 	"github.com/go-chi/chi/v5"
@@ -20,81 +28,325 @@ import (
 func main() {
 	// Load configuration from environment
 	cfg := config.Load()
+	logging.SetDebug(cfg.LogLevel == "debug")
 
 	// Initialize Supabase client
 	db := supabase.NewClient(cfg)
 
+	// Fail fast on schema drift rather than on the first request
+	if !cfg.SkipSchemaCheck {
+		if err := db.CheckSchema(); err != nil {
+			log.Fatalf("Supabase schema check failed: %v", err)
+		}
+	}
+
 	// Initialize services
-	roomService := services.NewRoomService(db)
+	clock := services.RealClock{}
+
+	signingKey := []byte(cfg.MessageSigningKey)
+	if len(signingKey) == 0 {
+		signingKey = make([]byte, 32)
+		if _, err := rand.Read(signingKey); err != nil {
+			log.Fatalf("Failed to generate message signing key: %v", err)
+		}
+		log.Println("MESSAGE_SIGNING_KEY is not set, signing secrets will change on restart")
+	}
+	messageService := services.NewMessageService(db, services.MessageLimits{
+		MaxContentLength:      cfg.MessageMaxContent,
+		MaxReplyPreviewLength: cfg.MaxReplyPreviewLength,
+		MaxAttachments:        cfg.MaxAttachments,
+		MaxAttachmentSize:     cfg.MaxAttachmentSize,
+	}, services.MessageRetention{
+		MaxCount: cfg.MessageRetentionMaxCount,
+		MaxAge:   cfg.MessageRetentionMaxAge,
+	}, clock, services.NewMessageSigner(signingKey), services.AllowAllSendAuthorizer{})
+	var webhooks *services.WebhookNotifier
+	if cfg.WebhookURL != "" {
+		webhooks = services.NewWebhookNotifier(cfg.WebhookURL, cfg.WebhookSecret, clock)
+		go webhooks.Start()
+	}
+	roomService := services.NewRoomService(db, messageService, webhooks, services.RoomSettings{
+		Avatars:        cfg.Avatars,
+		DefaultAvatar:  cfg.DefaultAvatar,
+		NameTemplate:   cfg.RoomNameTemplate,
+		WelcomeMessage: cfg.RoomWelcomeMessage,
+		SlugRoomIDs:    cfg.SlugRoomIDs,
+		RoomIDBytes:    cfg.RoomIDBytes,
+		MaxJoinsPerIP:  cfg.MaxJoinsPerIP,
+		ReservedNames:  cfg.ReservedRoomNames,
+		CacheTTL:       cfg.RoomCacheTTL,
+	}, clock, rand.Reader)
+	var archiveHook services.ArchiveHook = services.NoopArchiveHook{}
+	if cfg.ArchiveWebhookURL != "" {
+		archiveHook = services.NewWebhookArchiveHook(cfg.ArchiveWebhookURL)
+	}
 	cleanupService := services.NewCleanupService(
 		db,
+		messageService,
+		archiveHook,
+		webhooks,
+		roomService.InvalidateRoom,
+		roomService.ForgetRoom,
 		1*time.Minute, // Check every minute
-		5*time.Minute, // Delete rooms inactive for 5 minutes
+		cfg.ParticipantTimeout,
+		cfg.RoomTimeout,
+		cfg.InactivityWarningWindow,
+		cfg.CleanupOrphanRooms,
+		clock,
 	)
 
+	maintenanceService := services.NewMaintenanceService(db, cfg.MaintenanceMaxBackoff)
+	typingService := services.NewTypingService(db, cfg.TypingTTL, clock)
+	roomService.OnRoomDeleted(typingService.ForgetRoom)
+	signalService := services.NewSignalService(db, cfg.SignalTTL, clock)
+
 	// Start background cleanup worker
 	go cleanupService.Start()
 
-	// Initialize message service for HTTP message endpoints
-	messageService := services.NewMessageService()
+	// Start background worker that expires messages past their room's TTL
+	go messageService.StartExpirySweeper(cfg.MessageExpiryInterval)
+
+	// Per-participant send throttle: allow a short burst, then a sustained rate
+	var sendLimiter *ratelimit.TokenBucket
+	if cfg.MessageRatePerMinute > 0 {
+		sendLimiter = ratelimit.NewTokenBucket(cfg.MessageBurst, time.Minute/time.Duration(cfg.MessageRatePerMinute))
+	}
+
+	// Aggregate safety valve across all senders; typing and reactions are shed first
+	var globalLimiter *ratelimit.TokenBucket
+	if cfg.GlobalMessageRate > 0 {
+		globalLimiter = ratelimit.NewTokenBucket(cfg.GlobalMessageBurst, time.Second/time.Duration(cfg.GlobalMessageRate))
+	}
+	chatThroughput := handlers.GlobalThroughput(globalLimiter, cfg.GlobalMessageBurst, false)
+	lowPriorityThroughput := handlers.GlobalThroughput(globalLimiter, cfg.GlobalMessageBurst, true)
+
+	var identityLimiter *ratelimit.TokenBucket
+	if cfg.IdentityChangeInterval > 0 {
+		identityLimiter = ratelimit.NewTokenBucket(1, cfg.IdentityChangeInterval)
+	}
 
 	// Initialize handlers
 	// Note: Real-time messaging is now handled by Supabase Realtime on the frontend
-	roomHandler := handlers.NewRoomHandler(roomService)
-	messageHandler := handlers.NewMessageHandler(messageService)
+	roomHandler := handlers.NewRoomHandler(roomService, identityLimiter, handlers.PageSize{
+		Default: cfg.RoomsPageSize,
+		Max:     cfg.RoomsMaxPageSize,
+	})
+	messageHandler := handlers.NewMessageHandler(messageService, sendLimiter)
+	typingHandler := handlers.NewTypingHandler(typingService, roomService)
+	signalHandler := handlers.NewSignalHandler(signalService)
+	configHandler := handlers.NewConfigHandler(handlers.ClientConfig{
+		HeartbeatInterval: cfg.ClientHeartbeatInterval,
+		HeartbeatJitter:   cfg.ClientHeartbeatJitter,
+		PollInterval:      cfg.ClientPollInterval,
+		MaxMessageSize:    cfg.MessageMaxContent,
+		Features:          cfg.Features.List(),
+	})
+	adminHandler := handlers.NewAdminHandler(roomService, messageService, cleanupService, maintenanceService)
+
+	// Toggle maintenance mode on SIGUSR1 for draining during rolling deploys
+	go watchMaintenanceSignal(maintenanceService)
+
+	// Only trust forwarded client IPs from configured proxies
+	trustedProxies, err := handlers.ParseTrustedProxies(cfg.TrustedProxies)
+	if err != nil {
+		log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+	}
 
 	// Set up router with middleware
 	r := chi.NewRouter()
 
 	// Middleware stack
 	r.Use(middleware.Logger)
-	r.Use(middleware.Recoverer)
 	r.Use(middleware.RequestID)
-	r.Use(middleware.RealIP)
+	r.Use(handlers.RequestIDHeader)
+	r.Use(handlers.Recover)
+	r.Use(handlers.TrustedRealIP(trustedProxies))
 
 	// CORS configuration - reads from CORS_ORIGINS env var
 	// Format: comma-separated list of origins, e.g., "http://localhost:5173,https://talkie.example.com"
 	corsOrigins := getCorsOrigins()
 	log.Printf("CORS allowed origins: %v", corsOrigins)
 
-	r.Use(cors.Handler(cors.Options{
-		AllowedOrigins:   corsOrigins,
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type"},
-		ExposedHeaders:   []string{"Link"},
-		AllowCredentials: true,
-		MaxAge:           300,
-	}))
+	// The strict allowlist only applies to the API; monitoring endpoints below
+	// expose nothing sensitive and may be hit cross-origin by uptime monitors.
+	apiCORS := newAPICORS(corsOrigins, cfg.CORSExposedHeaders)
+	monitoringCORS := newMonitoringCORS()
+
+	// Per-IP rate limits for the endpoints most prone to spam.
+	// Room creation and message sends have separate quotas.
+	createRoomLimit := handlers.RateLimit(ratelimit.NewLimiter(cfg.RateLimitPerMinute, time.Minute))
+	sendMessageLimit := handlers.RateLimit(ratelimit.NewLimiter(cfg.RateLimitPerMinute, time.Minute))
 
-	// TODO: Add rate limiting middleware to prevent DoS/spam attacks.
-	// Recommended approach: IP-based token bucket with two tiers:
-	//   - Global: ~100 requests/min per IP (all endpoints)
-	//   - Strict: ~20 requests/min per IP (POST /api/rooms, POST /api/rooms/{id}/messages)
+	// Refuse new rooms and joins while draining for a deploy
+	maintenance := handlers.MaintenanceGuard(maintenanceService)
 
-	// Health check endpoint
-	r.Get("/health", handlers.HealthCheck)
+	// Shed joins during reconnect storms, with a jittered retry hint
+	joinLoadShed := handlers.LoadShed(cfg.JoinMaxInFlight, cfg.MaintenanceMaxBackoff)
+
+	// TODO: Add a global rate limit tier (~100 requests/min per IP, all endpoints).
+
+	// Health check endpoint, allowed from any origin
+	r.With(monitoringCORS).Get("/health", handlers.HealthCheck)
+	// Preflights are answered by the CORS middleware; the route only makes chi accept OPTIONS
+	r.With(monitoringCORS).Options("/health", func(w http.ResponseWriter, r *http.Request) {})
+
+	// Readiness endpoint, fails if the cleanup worker has stalled
+	r.With(monitoringCORS).Get("/ready", handlers.ReadinessCheck(cleanupService))
+	r.With(monitoringCORS).Options("/ready", func(w http.ResponseWriter, r *http.Request) {})
 
 	// Note: Real-time messaging (/ws) is now handled by Supabase Realtime on the frontend
 
+	// Optional endpoints return 404 when their feature is switched off
+	feature := func(name string) func(http.Handler) http.Handler {
+		return handlers.RequireFeature(cfg.Features, name)
+	}
+
+	// Bulk leave is fed by sendBeacon, which can't send application/json
+	// cross-origin without a preflight, so it sits outside RequireJSON
+	beacon := r.With(apiCORS, handlers.RequireBeaconJSON, handlers.MaxBodySize(cfg.MaxRequestBodySize))
+	beacon.Post("/api/leave", roomHandler.BulkLeave)
+	beacon.Options("/api/leave", func(w http.ResponseWriter, r *http.Request) {})
+
 	// API routes
 	r.Route("/api", func(r chi.Router) {
+		r.Use(apiCORS)
+		r.Use(handlers.RequireJSON)
+		r.Use(handlers.MaxBodySize(cfg.MaxRequestBodySize))
+
 		r.Route("/rooms", func(r chi.Router) {
 			r.Get("/", roomHandler.ListRooms)
-			r.Post("/", roomHandler.CreateRoom)
-			r.Get("/{id}", roomHandler.GetRoom)
-			r.Post("/{id}/join", roomHandler.JoinRoom)
-			r.Post("/{id}/leave", roomHandler.LeaveRoom)
-			r.Post("/{id}/heartbeat", roomHandler.Heartbeat)
-			// Message endpoints (kept as fallback)
-			r.Get("/{id}/messages", messageHandler.GetMessages)
-			r.Post("/{id}/messages", messageHandler.SendMessage)
+			r.With(maintenance, createRoomLimit).Post("/", roomHandler.CreateRoom)
+
+			// Malformed room IDs are rejected before they reach a Supabase filter
+			r.Route("/{id}", func(r chi.Router) {
+				r.Use(handlers.ValidateRoomID)
+
+				r.Get("/", roomHandler.GetRoom)
+				r.With(maintenance, joinLoadShed).Post("/join", roomHandler.JoinRoom)
+				r.Post("/leave", roomHandler.LeaveRoom)
+				r.Patch("/participants/{participantId}", roomHandler.UpdateParticipant)
+				r.Post("/heartbeat", roomHandler.Heartbeat)
+				r.With(feature(config.FeatureLock)).Post("/lock", roomHandler.LockRoom)
+				r.With(feature(config.FeatureLock)).Post("/unlock", roomHandler.UnlockRoom)
+				r.Post("/transfer-host", roomHandler.TransferHost)
+				r.Post("/close", roomHandler.CloseRoom)
+				r.With(feature(config.FeatureRegenerateID)).Post("/regenerate-id", roomHandler.RegenerateRoomID)
+				r.With(feature(config.FeatureKeyRotation)).Post("/rotate-key", roomHandler.RotateKey)
+				r.With(feature(config.FeatureKickInactive)).Post("/kick-inactive", roomHandler.KickInactive)
+				// Message endpoints (kept as fallback)
+				r.Get("/messages", messageHandler.GetMessages)
+				r.Get("/messages/count", messageHandler.CountMessages)
+				r.Get("/messages/{messageId}", messageHandler.GetMessage)
+				r.With(sendMessageLimit, chatThroughput).Post("/messages", messageHandler.SendMessage)
+				r.With(feature(config.FeatureEphemeral)).Post("/read", messageHandler.MarkRead)
+				r.With(feature(config.FeatureSearch)).Get("/search", messageHandler.SearchMessages)
+				// Typing state for polling clients
+				r.With(feature(config.FeatureTyping)).Get("/typing", typingHandler.GetTyping)
+				r.With(feature(config.FeatureTyping), lowPriorityThroughput).Post("/typing", typingHandler.SetTyping)
+				// Raised hands and reactions
+				r.With(feature(config.FeatureSignals)).Get("/signal", signalHandler.GetSignals)
+				r.With(feature(config.FeatureSignals), lowPriorityThroughput).Post("/signal", signalHandler.SendSignal)
+			})
+		})
+
+		r.Get("/participants/{participantId}", roomHandler.GetParticipant)
+		r.Get("/avatars", roomHandler.ListAvatars)
+		r.Get("/config/client", configHandler.GetClientConfig)
+
+		// Internal admin endpoints, guarded by ADMIN_TOKEN
+		r.Route("/admin", func(r chi.Router) {
+			r.Use(handlers.AdminAuth(cfg.AdminToken))
+			r.Get("/debug/stats", adminHandler.DebugStats)
+			r.Get("/counts", adminHandler.Counts)
+			r.Get("/participants", adminHandler.ListParticipants)
+			r.Post("/maintenance", adminHandler.SetMaintenance)
+			r.Post("/cleanup/orphans", adminHandler.CleanupOrphans)
+			r.With(handlers.ValidateRoomID).Post("/rooms/{id}/announce", adminHandler.PostAnnouncement)
 		})
 	})
 
 	// Start server
-	addr := fmt.Sprintf(":%s", cfg.ServerPort)
-	log.Printf("🚀 Talkie backend starting on %s", addr)
-	log.Fatal(http.ListenAndServe(addr, r))
+	srv := &http.Server{
+		Addr:    fmt.Sprintf(":%s", cfg.ServerPort),
+		Handler: r,
+	}
+	ln, err := net.Listen("tcp", srv.Addr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	go func() {
+		if err := serve(srv, ln, cfg); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal(err)
+		}
+	}()
+
+	waitForShutdown(srv, maintenanceService, cleanupService, messageService)
+}
+
+// newAPICORS returns the CORS middleware for /api routes, which only allows
+// the configured origins.
+func newAPICORS(origins, exposedHeaders []string) func(http.Handler) http.Handler {
+	return cors.Handler(cors.Options{
+		AllowedOrigins:   origins,
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type"},
+		ExposedHeaders:   exposedHeaders,
+		AllowCredentials: true,
+		MaxAge:           300,
+	})
+}
+
+// newMonitoringCORS returns the CORS middleware for health and readiness
+// endpoints, which allows read-only requests from any origin.
+func newMonitoringCORS() func(http.Handler) http.Handler {
+	return cors.Handler(cors.Options{
+		AllowedOrigins: []string{"*"},
+		AllowedMethods: []string{"GET", "HEAD", "OPTIONS"},
+		MaxAge:         300,
+	})
+}
+
+// serve accepts connections on ln, terminating TLS natively when a certificate
+// and key are configured and serving plain HTTP otherwise.
+func serve(srv *http.Server, ln net.Listener, cfg *config.Config) error {
+	if cfg.TLSEnabled() {
+		log.Printf("🚀 Talkie backend starting on %s (TLS)", srv.Addr)
+		return srv.ServeTLS(ln, cfg.TLSCertFile, cfg.TLSKeyFile)
+	}
+	log.Printf("🚀 Talkie backend starting on %s", srv.Addr)
+	return srv.Serve(ln)
+}
+
+// waitForShutdown blocks until SIGINT/SIGTERM, then warns connected clients
+// with a server_shutdown advisory before stopping workers and the server.
+func waitForShutdown(srv *http.Server, m *services.MaintenanceService, cleanup *services.CleanupService, messages *services.MessageService) {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	<-sigs
+	log.Println("Shutting down...")
+
+	if err := m.Shutdown(); err != nil {
+		log.Printf("Failed to broadcast shutdown advisories: %v", err)
+	}
+	cleanup.Stop()
+	messages.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Printf("Server shutdown error: %v", err)
+	}
+}
+
+// watchMaintenanceSignal toggles maintenance mode each time SIGUSR1 is received.
+func watchMaintenanceSignal(m *services.MaintenanceService) {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGUSR1)
+	for range sigs {
+		if err := m.Toggle(); err != nil {
+			log.Printf("Failed to toggle maintenance mode: %v", err)
+		}
+	}
 }
 
 // getCorsOrigins returns allowed CORS origins from environment or defaults
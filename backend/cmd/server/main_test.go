@@ -0,0 +1,190 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adi-253/Talkie/backend/internal/config"
+	"github.com/adi-253/Talkie/backend/internal/handlers"
+	"github.com/go-chi/chi/v5"
+)
+
+// writeSelfSignedCert writes a self-signed certificate and key for 127.0.0.1
+// to dir and returns their paths and the parsed certificate.
+func writeSelfSignedCert(t *testing.T, dir string) (certFile, keyFile string, cert *x509.Certificate) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "talkie-test"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cert, err = x509.ParseCertificate(der); err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile, keyFile = filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile, cert
+}
+
+// startServer serves a handler answering "ok" on a random local port.
+func startServer(t *testing.T, cfg *config.Config) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	})}
+	go serve(srv, ln, cfg)
+	t.Cleanup(func() { srv.Close() })
+	return ln.Addr().String()
+}
+
+func TestServeTLSWhenConfigured(t *testing.T) {
+	certFile, keyFile, cert := writeSelfSignedCert(t, t.TempDir())
+	addr := startServer(t, &config.Config{TLSCertFile: certFile, TLSKeyFile: keyFile})
+
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+	resp, err := client.Get("https://" + addr + "/")
+	if err != nil {
+		t.Fatalf("HTTPS request: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.TLS == nil || string(body) != "ok" {
+		t.Errorf("response over TLS = %v / %q, want a TLS connection answering ok", resp.TLS, body)
+	}
+
+	if resp, err := http.Get("http://" + addr + "/"); err == nil {
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("plain HTTP to TLS server: status = %d, want 400", resp.StatusCode)
+		}
+	}
+}
+
+func TestServePlainHTTPWithoutTLS(t *testing.T) {
+	certFile, _, _ := writeSelfSignedCert(t, t.TempDir())
+	addr := startServer(t, &config.Config{TLSCertFile: certFile})
+
+	resp, err := http.Get("http://" + addr + "/")
+	if err != nil {
+		t.Fatalf("HTTP request: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.TLS != nil || resp.StatusCode != http.StatusOK {
+		t.Errorf("response = %d (TLS %v), want plain 200", resp.StatusCode, resp.TLS != nil)
+	}
+}
+
+func TestHealthIsExemptFromAPICORS(t *testing.T) {
+	r := chi.NewRouter()
+	r.With(newMonitoringCORS()).Get("/health", handlers.HealthCheck)
+	r.With(newMonitoringCORS()).Options("/health", func(w http.ResponseWriter, r *http.Request) {})
+	r.Route("/api", func(r chi.Router) {
+		r.Use(newAPICORS([]string{"https://talkie.example.com"}, nil))
+		r.Get("/rooms", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+	})
+
+	request := func(method, path, origin string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Origin", origin)
+		if method == http.MethodOptions {
+			req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+		}
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	tests := []struct {
+		name      string
+		method    string
+		path      string
+		origin    string
+		wantAllow string
+	}{
+		{"health from a disallowed origin", http.MethodGet, "/health", "https://uptime.example.net", "*"},
+		{"health preflight from a disallowed origin", http.MethodOptions, "/health", "https://uptime.example.net", "*"},
+		{"api from a disallowed origin", http.MethodGet, "/api/rooms", "https://uptime.example.net", ""},
+		{"api preflight from a disallowed origin", http.MethodOptions, "/api/rooms", "https://uptime.example.net", ""},
+		{"api from an allowed origin", http.MethodGet, "/api/rooms", "https://talkie.example.com", "https://talkie.example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := request(tt.method, tt.path, tt.origin)
+			if got := rec.Header().Get("Access-Control-Allow-Origin"); got != tt.wantAllow {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, tt.wantAllow)
+			}
+		})
+	}
+}
+
+func TestAPICORSExposesConfiguredHeaders(t *testing.T) {
+	tests := []struct {
+		name string
+		env  string
+		want []string
+	}{
+		{"defaults", "", []string{"Link", "X-Request-Id", "Retry-After", "X-Ratelimit-Limit", "X-Ratelimit-Remaining", "X-Ratelimit-Reset"}},
+		{"configured", "X-Request-ID, X-Custom", []string{"X-Request-Id", "X-Custom"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CORS_EXPOSED_HEADERS", tt.env)
+			cfg := config.Load()
+
+			h := newAPICORS([]string{"https://talkie.example.com"}, cfg.CORSExposedHeaders)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+			req := httptest.NewRequest(http.MethodGet, "/api/rooms", nil)
+			req.Header.Set("Origin", "https://talkie.example.com")
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			got := strings.Split(rec.Header().Get("Access-Control-Expose-Headers"), ", ")
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Access-Control-Expose-Headers = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
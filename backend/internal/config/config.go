@@ -3,6 +3,9 @@ package config
 import (
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -17,8 +20,172 @@ type Config struct {
 	// This key has elevated privileges and should never be exposed to clients
 	SupabaseKey string
 
+	// SupabaseReadReplicaURLs optionally lists read-replica URLs that lobby and admin list reads are spread across
+	SupabaseReadReplicaURLs []string
+
 	// ServerPort is the port the HTTP server listens on
 	ServerPort string
+
+	// SkipSchemaCheck disables the startup check that the Supabase tables have the expected columns
+	SkipSchemaCheck bool
+
+	// TrustedProxies lists proxy CIDRs (or IPs) whose X-Forwarded-For/X-Real-IP headers
+	// are honored. When empty, the socket address is always used as the client IP.
+	TrustedProxies []string
+
+	// CORSExposedHeaders lists response headers browsers may read on cross-origin API calls
+	CORSExposedHeaders []string
+
+	// AdminToken guards the /api/admin endpoints (sent as a Bearer token)
+	// Admin endpoints reject all requests when this is empty
+	AdminToken string
+
+	// Avatars is the allowlist of avatar identifiers participants may choose
+	Avatars []string
+
+	// DefaultAvatar is assigned to participants who join without an avatar
+	DefaultAvatar string
+
+	// MaxRequestBodySize caps API request bodies in bytes (0 disables)
+	MaxRequestBodySize int64
+
+	// MessageMaxContent is the maximum stored message content length in bytes
+	MessageMaxContent int
+
+	// MaxReplyPreviewLength caps the reply preview stored with a message (in characters)
+	MaxReplyPreviewLength int
+
+	// MaxAttachments is the maximum number of attachments per message
+	MaxAttachments int
+
+	// MaxAttachmentSize is the maximum declared size of an attachment in bytes
+	MaxAttachmentSize int64
+
+	// MessageExpiryInterval is how often messages past their room's TTL are swept
+	MessageExpiryInterval time.Duration
+
+	// MessageRetentionMaxCount caps how many messages each room keeps (0 = unlimited)
+	MessageRetentionMaxCount int
+
+	// MessageRetentionMaxAge caps how long any message is kept (0 = until room TTL/deletion)
+	MessageRetentionMaxAge time.Duration
+
+	// MessageSigningKey derives per-participant message signing secrets
+	// (random per process when empty, so secrets change on restart)
+	MessageSigningKey string
+
+	// RateLimitPerMinute is the per-IP limit for room creation and message sends
+	RateLimitPerMinute int
+
+	// MessageBurst is how many messages a participant may send in a quick burst
+	MessageBurst int
+
+	// MessageRatePerMinute is the sustained per-participant send rate after a burst (0 disables)
+	MessageRatePerMinute int
+
+	// IdentityChangeInterval is the minimum time between a participant's username/avatar changes (0 disables)
+	IdentityChangeInterval time.Duration
+
+	// ArchiveWebhookURL receives each room's stored messages as JSON before cleanup deletes it (empty disables)
+	ArchiveWebhookURL string
+
+	// WebhookURL receives signed room lifecycle events (empty disables)
+	WebhookURL string
+
+	// WebhookSecret is the HMAC key for the X-Talkie-Signature webhook header
+	WebhookSecret string
+
+	// GlobalMessageRate is the aggregate sends per second allowed across all
+	// rooms before traffic is shed, typing and reactions first (0 disables)
+	GlobalMessageRate int
+
+	// GlobalMessageBurst is how far aggregate sends may briefly exceed GlobalMessageRate
+	GlobalMessageBurst int
+
+	// CleanupOrphanRooms deletes rooms with no participants on every cleanup tick
+	CleanupOrphanRooms bool
+
+	// MaxJoinsPerIP caps the participants one client IP may hold in a single room (0 disables)
+	MaxJoinsPerIP int
+
+	// JoinMaxInFlight sheds joins with 503 above this many concurrent join requests (0 disables)
+	JoinMaxInFlight int
+
+	// MaintenanceMaxBackoff bounds the reconnect backoff hint sent when entering maintenance mode
+	MaintenanceMaxBackoff time.Duration
+
+	// ParticipantTimeout is how long a participant can be inactive before removal
+	ParticipantTimeout time.Duration
+
+	// RoomTimeout is how long a room can be inactive before deletion
+	RoomTimeout time.Duration
+
+	// InactivityWarningWindow is how long before the inactivity timeout a participant
+	// is warned so they can send a heartbeat (0 disables warnings)
+	InactivityWarningWindow time.Duration
+
+	// ClientHeartbeatInterval is the heartbeat interval recommended to clients
+	ClientHeartbeatInterval time.Duration
+
+	// ClientHeartbeatJitter is the max random delay clients add to each heartbeat
+	ClientHeartbeatJitter time.Duration
+
+	// ClientPollInterval is the message polling interval recommended to clients
+	ClientPollInterval time.Duration
+
+	// LogLevel is "info" (default) or "debug"; debug adds per-message and per-broadcast lines
+	LogLevel string
+
+	// RoomsPageSize and RoomsMaxPageSize bound GET /api/rooms (default and maximum limit)
+	RoomsPageSize    int
+	RoomsMaxPageSize int
+
+	// RoomNameTemplate names rooms created without a name, e.g. "Room {n} - {date}"
+	RoomNameTemplate string
+
+	// RoomWelcomeMessage is posted as a system message in every new room (empty disables)
+	RoomWelcomeMessage string
+
+	// RoomIDBytes is the random bytes in generated room IDs (hex, so IDs are twice as long)
+	RoomIDBytes int
+
+	// SlugRoomIDs derives room IDs from room names instead of random IDs
+	SlugRoomIDs bool
+
+	// RoomCacheTTL is how long room lookups are cached in memory (0 disables)
+	RoomCacheTTL time.Duration
+
+	// ReservedRoomNames are room IDs and names users can't create rooms with (case-insensitive)
+	ReservedRoomNames []string
+
+	// TypingTTL is how long a typing update stays visible to polling clients
+	TypingTTL time.Duration
+
+	// Features gates optional endpoints; FEATURES lists the enabled ones (default: all)
+	Features FeatureFlags
+
+	// SignalTTL is how long a raised hand or reaction stays active
+	SignalTTL time.Duration
+
+	// TLSCertFile and TLSKeyFile enable native HTTPS when both are set
+	TLSCertFile string
+	TLSKeyFile  string
+}
+
+// defaultAvatars matches the avatar options offered by the frontend join form.
+var defaultAvatars = []string{
+	"fox", "bear", "owl", "cat", "dog", "panda",
+	"bunny", "wolf", "koala", "penguin", "lion", "frog",
+}
+
+// defaultReservedRoomNames covers the API's own path segments.
+var defaultReservedRoomNames = []string{"admin", "api", "health"}
+
+// defaultExposedHeaders are the response headers the API emits that the
+// frontend reads (pagination, request correlation and rate limiting).
+var defaultExposedHeaders = []string{
+	"Link", "X-Request-ID", "Retry-After",
+	"X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset",
 }
 
 // Load reads environment variables and returns a populated Config struct.
@@ -35,6 +202,64 @@ func Load() *Config {
 		SupabaseURL: getEnv("SUPABASE_URL", ""),
 		SupabaseKey: getEnv("SUPABASE_SERVICE_ROLE_KEY", ""),
 		ServerPort:  getEnv("PORT", "8080"),
+
+		SupabaseReadReplicaURLs: getEnvList("SUPABASE_READ_REPLICA_URLS", nil),
+
+		AdminToken:      getEnv("ADMIN_TOKEN", ""),
+		TrustedProxies:  getEnvList("TRUSTED_PROXIES", nil),
+		SkipSchemaCheck: getEnvBool("SKIP_SCHEMA_CHECK", false),
+
+		CORSExposedHeaders: getEnvList("CORS_EXPOSED_HEADERS", defaultExposedHeaders),
+
+		Avatars:                  getEnvList("AVATARS", defaultAvatars),
+		MaxRequestBodySize:       int64(getEnvInt("MAX_REQUEST_BODY_SIZE", 1024*1024)),
+		MessageMaxContent:        getEnvInt("MESSAGE_MAX_CONTENT", 64*1024),
+		MaxReplyPreviewLength:    getEnvInt("MAX_REPLY_PREVIEW_LENGTH", 100),
+		MaxAttachments:           getEnvInt("MAX_ATTACHMENTS", 5),
+		MaxAttachmentSize:        int64(getEnvInt("MAX_ATTACHMENT_SIZE", 25*1024*1024)),
+		MessageExpiryInterval:    getEnvPositiveDuration("MESSAGE_EXPIRY_INTERVAL", 10*time.Second),
+		MessageRetentionMaxCount: getEnvInt("MESSAGE_RETENTION_MAX_COUNT", 0),
+		MessageRetentionMaxAge:   getEnvDuration("MESSAGE_RETENTION_MAX_AGE", 0),
+		MessageSigningKey:        getEnv("MESSAGE_SIGNING_KEY", ""),
+		RateLimitPerMinute:       getEnvInt("RATE_LIMIT_PER_MINUTE", 20),
+		MessageBurst:             getEnvInt("MESSAGE_BURST", 10),
+		MessageRatePerMinute:     getEnvInt("MESSAGE_RATE_PER_MINUTE", 30),
+		IdentityChangeInterval:   getEnvDuration("IDENTITY_CHANGE_INTERVAL", 10*time.Second),
+		ArchiveWebhookURL:        getEnv("ARCHIVE_WEBHOOK_URL", ""),
+		WebhookURL:               getEnv("WEBHOOK_URL", ""),
+		WebhookSecret:            getEnv("WEBHOOK_SECRET", ""),
+		GlobalMessageRate:        getEnvInt("GLOBAL_MESSAGE_RATE", 0),
+		GlobalMessageBurst:       getEnvInt("GLOBAL_MESSAGE_BURST", 200),
+		CleanupOrphanRooms:       getEnvBool("CLEANUP_ORPHAN_ROOMS", false),
+		MaxJoinsPerIP:            getEnvInt("MAX_JOINS_PER_IP", 0),
+		JoinMaxInFlight:          getEnvInt("JOIN_MAX_IN_FLIGHT", 0),
+		MaintenanceMaxBackoff:    getEnvDuration("MAINTENANCE_MAX_BACKOFF", 30*time.Second),
+		ParticipantTimeout:       getEnvDuration("PARTICIPANT_TIMEOUT", 5*time.Minute),
+		RoomTimeout:              getEnvDuration("ROOM_TIMEOUT", 5*time.Minute),
+		InactivityWarningWindow:  getEnvDuration("INACTIVITY_WARNING_WINDOW", 2*time.Minute),
+		ClientHeartbeatInterval:  getEnvDuration("CLIENT_HEARTBEAT_INTERVAL", 30*time.Second),
+		ClientHeartbeatJitter:    getEnvDuration("CLIENT_HEARTBEAT_JITTER", 5*time.Second),
+		ClientPollInterval:       getEnvDuration("CLIENT_POLL_INTERVAL", 3*time.Second),
+		LogLevel:                 getEnv("LOG_LEVEL", "info"),
+		RoomsPageSize:            getEnvInt("ROOMS_PAGE_SIZE", 50),
+		RoomsMaxPageSize:         getEnvInt("ROOMS_MAX_PAGE_SIZE", 200),
+		RoomNameTemplate:         getEnv("ROOM_NAME_TEMPLATE", ""),
+		RoomWelcomeMessage:       getEnv("ROOM_WELCOME_MESSAGE", ""),
+		RoomIDBytes:              getEnvInt("ROOM_ID_BYTES", 4),
+		SlugRoomIDs:              getEnvBool("SLUG_ROOM_IDS", false),
+		RoomCacheTTL:             getEnvDuration("ROOM_CACHE_TTL", 2*time.Second),
+		ReservedRoomNames:        getEnvList("RESERVED_ROOM_NAMES", defaultReservedRoomNames),
+		TypingTTL:                getEnvDuration("TYPING_TTL", 3*time.Second),
+		SignalTTL:                getEnvDuration("SIGNAL_TTL", 2*time.Minute),
+		TLSCertFile:              getEnv("TLS_CERT_FILE", ""),
+		TLSKeyFile:               getEnv("TLS_KEY_FILE", ""),
+	}
+
+	config.Features = NewFeatureFlags(getEnvList("FEATURES", allFeatures))
+
+	config.DefaultAvatar = getEnv("DEFAULT_AVATAR", "")
+	if config.DefaultAvatar == "" && len(config.Avatars) > 0 {
+		config.DefaultAvatar = config.Avatars[0]
 	}
 
 	// Validate required configuration
@@ -44,10 +269,24 @@ func Load() *Config {
 	if config.SupabaseKey == "" {
 		log.Println("WARNING: SUPABASE_SERVICE_ROLE_KEY is not set")
 	}
+	if (config.TLSCertFile == "") != (config.TLSKeyFile == "") {
+		log.Println("WARNING: TLS_CERT_FILE and TLS_KEY_FILE must both be set, serving plain HTTP")
+	}
+	if config.WebhookURL != "" && config.WebhookSecret == "" {
+		log.Println("WARNING: WEBHOOK_SECRET is not set, webhook signatures can be forged")
+	}
+	if config.AdminToken == "" {
+		log.Println("WARNING: ADMIN_TOKEN is not set, admin endpoints are disabled")
+	}
 
 	return config
 }
 
+// TLSEnabled reports whether both a TLS certificate and key are configured.
+func (c *Config) TLSEnabled() bool {
+	return c.TLSCertFile != "" && c.TLSKeyFile != ""
+}
+
 // getEnv retrieves an environment variable or returns a default value
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
@@ -55,3 +294,76 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+// getEnvInt retrieves an integer environment variable or returns a default value
+// if it is unset or cannot be parsed.
+func getEnvInt(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("WARNING: invalid value for %s (%q), using default %d", key, value, defaultValue)
+		return defaultValue
+	}
+	return parsed
+}
+
+// getEnvBool retrieves a boolean environment variable (e.g. "true", "1") or
+// returns a default value if it is unset or cannot be parsed.
+func getEnvBool(key string, defaultValue bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("WARNING: invalid value for %s (%q), using default %v", key, value, defaultValue)
+		return defaultValue
+	}
+	return parsed
+}
+
+// getEnvDuration retrieves a duration environment variable (e.g. "90s", "2m") or
+// returns a default value if it is unset or cannot be parsed.
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("WARNING: invalid value for %s (%q), using default %v", key, value, defaultValue)
+		return defaultValue
+	}
+	return parsed
+}
+
+// getEnvPositiveDuration is getEnvDuration for intervals that must be
+// positive (e.g. ticker periods); zero or negative values use the default.
+func getEnvPositiveDuration(key string, defaultValue time.Duration) time.Duration {
+	parsed := getEnvDuration(key, defaultValue)
+	if parsed <= 0 {
+		log.Printf("WARNING: %s must be positive (got %v), using default %v", key, parsed, defaultValue)
+		return defaultValue
+	}
+	return parsed
+}
+
+// getEnvList retrieves a comma-separated environment variable as a slice of
+// trimmed, non-empty values, or returns a default value if it is unset.
+func getEnvList(key string, defaultValue []string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
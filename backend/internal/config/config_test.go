@@ -0,0 +1,41 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGetEnvPositiveDuration(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"", 10 * time.Second},
+		{"30s", 30 * time.Second},
+		{"0", 10 * time.Second},
+		{"-5s", 10 * time.Second},
+		{"bogus", 10 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			t.Setenv("TEST_INTERVAL", tt.value)
+			if got := getEnvPositiveDuration("TEST_INTERVAL", 10*time.Second); got != tt.want {
+				t.Errorf("getEnvPositiveDuration(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadClientSettings(t *testing.T) {
+	t.Setenv("CLIENT_HEARTBEAT_INTERVAL", "20s")
+	t.Setenv("CLIENT_HEARTBEAT_JITTER", "4s")
+	t.Setenv("CLIENT_POLL_INTERVAL", "2s")
+	t.Setenv("MESSAGE_MAX_CONTENT", "4096")
+
+	cfg := Load()
+	if cfg.ClientHeartbeatInterval != 20*time.Second || cfg.ClientHeartbeatJitter != 4*time.Second ||
+		cfg.ClientPollInterval != 2*time.Second || cfg.MessageMaxContent != 4096 {
+		t.Errorf("client settings = %v / %v / %v / %d, want 20s / 4s / 2s / 4096",
+			cfg.ClientHeartbeatInterval, cfg.ClientHeartbeatJitter, cfg.ClientPollInterval, cfg.MessageMaxContent)
+	}
+}
@@ -0,0 +1,51 @@
+package config
+
+import "sort"
+
+// Optional features that can be switched off per deployment with FEATURES.
+const (
+	FeatureLock         = "lock"
+	FeatureKeyRotation  = "key_rotation"
+	FeatureRegenerateID = "regenerate_id"
+	FeatureKickInactive = "kick_inactive"
+	FeatureEphemeral    = "ephemeral"
+	FeatureSearch       = "search"
+	FeatureTyping       = "typing"
+	FeatureSignals      = "signals"
+)
+
+// allFeatures is every known feature; all are enabled when FEATURES is unset.
+var allFeatures = []string{
+	FeatureLock, FeatureKeyRotation, FeatureRegenerateID, FeatureKickInactive,
+	FeatureEphemeral, FeatureSearch, FeatureTyping, FeatureSignals,
+}
+
+// FeatureFlags is the set of optional features enabled for this deployment.
+type FeatureFlags struct {
+	enabled map[string]bool
+}
+
+// NewFeatureFlags enables exactly the named features. Unknown names are kept
+// so flags can be staged in config before the code that reads them ships.
+func NewFeatureFlags(names []string) FeatureFlags {
+	enabled := make(map[string]bool, len(names))
+	for _, name := range names {
+		enabled[name] = true
+	}
+	return FeatureFlags{enabled: enabled}
+}
+
+// Enabled reports whether the named feature is on.
+func (f FeatureFlags) Enabled(name string) bool {
+	return f.enabled[name]
+}
+
+// List returns the enabled features in sorted order.
+func (f FeatureFlags) List() []string {
+	names := make([]string, 0, len(f.enabled))
+	for name := range f.enabled {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,25 @@
+package config
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestFeaturesFromEnv(t *testing.T) {
+	t.Setenv("FEATURES", "")
+	all := Load().Features
+	for _, name := range allFeatures {
+		if !all.Enabled(name) {
+			t.Errorf("FEATURES unset: %q disabled, want every feature on", name)
+		}
+	}
+
+	t.Setenv("FEATURES", "search, lock,future_flag")
+	flags := Load().Features
+	if got := flags.List(); !slices.Equal(got, []string{"future_flag", "lock", "search"}) {
+		t.Errorf("enabled features = %v, want [future_flag lock search]", got)
+	}
+	if flags.Enabled(FeatureTyping) {
+		t.Error("typing enabled although it isn't listed")
+	}
+}
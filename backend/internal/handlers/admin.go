@@ -0,0 +1,232 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"log"
+	"net/http"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/adi-253/Talkie/backend/internal/models"
+	"github.com/adi-253/Talkie/backend/internal/services"
+	"github.com/go-chi/chi/v5"
+)
+
+// AdminHandler contains HTTP handlers for internal admin operations.
+// All routes must be mounted behind the AdminAuth middleware.
+type AdminHandler struct {
+	roomService        *services.RoomService
+	messageService     *services.MessageService
+	cleanupService     *services.CleanupService
+	maintenanceService *services.MaintenanceService
+}
+
+// NewAdminHandler creates a new AdminHandler instance.
+func NewAdminHandler(
+	roomService *services.RoomService,
+	messageService *services.MessageService,
+	cleanupService *services.CleanupService,
+	maintenanceService *services.MaintenanceService,
+) *AdminHandler {
+	return &AdminHandler{
+		roomService:        roomService,
+		messageService:     messageService,
+		cleanupService:     cleanupService,
+		maintenanceService: maintenanceService,
+	}
+}
+
+// CleanupOrphansResponse reports how many orphan rooms were deleted.
+type CleanupOrphansResponse struct {
+	Deleted int `json:"deleted"`
+}
+
+// MaintenanceRequest is the request body for toggling maintenance mode.
+type MaintenanceRequest struct {
+	Enabled bool `json:"enabled"`
+}
+
+// DebugStatsResponse reports runtime and room statistics for capacity planning.
+type DebugStatsResponse struct {
+	Goroutines         int    `json:"goroutines"`
+	HeapAllocBytes     uint64 `json:"heap_alloc_bytes"`
+	ActiveRooms        int    `json:"active_rooms"`
+	ActiveParticipants int    `json:"active_participants"`
+}
+
+// CountsResponse reports the total number of rooms and participants.
+type CountsResponse struct {
+	Rooms        int `json:"rooms"`
+	Participants int `json:"participants"`
+}
+
+// adminPageSize bounds admin list endpoints.
+var adminPageSize = PageSize{Default: 50, Max: 200}
+
+// ParticipantsPageResponse is one page of participants across all rooms.
+type ParticipantsPageResponse struct {
+	Participants []models.Participant `json:"participants"`
+	Limit        int                  `json:"limit"`
+	Offset       int                  `json:"offset"`
+}
+
+// AdminAuth returns middleware that requires the configured admin token as a
+// Bearer token. If no token is configured, all admin requests are rejected.
+func AdminAuth(token string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if token == "" {
+				writeError(w, r, http.StatusForbidden, "admin API is disabled")
+				return
+			}
+
+			provided := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+				writeError(w, r, http.StatusUnauthorized, "unauthorized")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// DebugStats handles GET /api/admin/debug/stats
+// Returns goroutine count, heap usage and the number of active rooms/participants.
+func (h *AdminHandler) DebugStats(w http.ResponseWriter, r *http.Request) {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	rooms, participants, err := h.roomService.CountActive()
+	if err != nil {
+		log.Printf("[Admin] Failed to count active rooms: %v", err)
+		writeInternalError(w, r, err)
+		return
+	}
+
+	response := DebugStatsResponse{
+		Goroutines:         runtime.NumGoroutine(),
+		HeapAllocBytes:     mem.HeapAlloc,
+		ActiveRooms:        rooms,
+		ActiveParticipants: participants,
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+// Counts handles GET /api/admin/counts
+// Returns the number of active rooms and participants for dashboards.
+func (h *AdminHandler) Counts(w http.ResponseWriter, r *http.Request) {
+	rooms, participants, err := h.roomService.CountActive()
+	if err != nil {
+		log.Printf("[Admin] Failed to count active rooms: %v", err)
+		writeInternalError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, CountsResponse{Rooms: rooms, Participants: participants})
+}
+
+// ListParticipants handles GET /api/admin/participants
+// Returns participants across all rooms, paginated.
+// Query params:
+//   - limit: page size (default 50, max 200)
+//   - offset: number of participants to skip
+func (h *AdminHandler) ListParticipants(w http.ResponseWriter, r *http.Request) {
+	limit, offset, ok := parsePage(w, r, adminPageSize)
+	if !ok {
+		return
+	}
+
+	participants, err := h.roomService.ListAllParticipants(limit, offset)
+	if err != nil {
+		log.Printf("[Admin] Failed to list participants: %v", err)
+		writeInternalError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, ParticipantsPageResponse{
+		Participants: participants,
+		Limit:        limit,
+		Offset:       offset,
+	})
+}
+
+// PostAnnouncement handles POST /api/admin/rooms/{id}/announce
+// Posts a plaintext system message to the room's history.
+func (h *AdminHandler) PostAnnouncement(w http.ResponseWriter, r *http.Request) {
+	roomID := chi.URLParam(r, "id")
+	if roomID == "" {
+		writeError(w, r, http.StatusBadRequest, "room ID is required")
+		return
+	}
+
+	var req models.AnnouncementRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeDecodeError(w, r, err)
+		return
+	}
+
+	if req.Content == "" {
+		writeError(w, r, http.StatusBadRequest, "content is required")
+		return
+	}
+
+	msg := h.messageService.PostSystemMessage(roomID, req.Content)
+	log.Printf("[Admin] Posted announcement %s in room %s", msg.ID, roomID)
+	writeJSON(w, http.StatusCreated, msg)
+}
+
+// CleanupOrphans handles POST /api/admin/cleanup/orphans
+// Immediately deletes rooms that have no participants.
+func (h *AdminHandler) CleanupOrphans(w http.ResponseWriter, r *http.Request) {
+	deleted, err := h.cleanupService.CleanupOrphanRooms()
+	if err != nil {
+		log.Printf("[Admin] Failed to clean up orphan rooms: %v", err)
+		writeInternalError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, CleanupOrphansResponse{Deleted: deleted})
+}
+
+// SetMaintenance handles POST /api/admin/maintenance
+// Enables or disables maintenance mode. Enabling it refuses new sessions and
+// advises connected clients to reconnect with a jittered backoff.
+func (h *AdminHandler) SetMaintenance(w http.ResponseWriter, r *http.Request) {
+	var req MaintenanceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeDecodeError(w, r, err)
+		return
+	}
+
+	if req.Enabled {
+		if err := h.maintenanceService.Enable(); err != nil {
+			log.Printf("[Admin] Failed to broadcast reconnect advisories: %v", err)
+			writeInternalError(w, r, err)
+			return
+		}
+	} else {
+		h.maintenanceService.Disable()
+	}
+
+	writeJSON(w, http.StatusOK, MaintenanceRequest{Enabled: h.maintenanceService.Enabled()})
+}
+
+// MaintenanceGuard returns middleware that refuses requests with 503 and a
+// Retry-After hint while maintenance mode is enabled.
+func MaintenanceGuard(m *services.MaintenanceService) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if m.Enabled() {
+				retryAfter := int(m.MaxBackoff().Seconds()) + 1
+				w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+				writeError(w, r, http.StatusServiceUnavailable, "server is in maintenance mode")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
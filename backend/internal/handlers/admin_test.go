@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/adi-253/Talkie/backend/internal/models"
+	"github.com/adi-253/Talkie/backend/internal/services"
+)
+
+func TestAdminAuth(t *testing.T) {
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) }
+
+	tests := []struct {
+		name   string
+		token  string
+		header string
+		want   int
+	}{
+		{"disabled without a token", "", "Bearer anything", http.StatusForbidden},
+		{"missing header", "secret", "", http.StatusUnauthorized},
+		{"wrong token", "secret", "Bearer nope", http.StatusUnauthorized},
+		{"token without Bearer prefix", "secret", "secret-but-longer", http.StatusUnauthorized},
+		{"valid token", "secret", "Bearer secret", http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := AdminAuth(tt.token)(http.HandlerFunc(ok))
+			rec := serve(t, http.MethodGet, "/api/admin/debug/stats", h.ServeHTTP, "/api/admin/debug/stats", nil, "Authorization", tt.header)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestDebugStats(t *testing.T) {
+	env := newTestEnv(t)
+	env.seedRoom("room1")
+	env.join(t, "room1", "alice", "")
+	env.join(t, "room1", "bob", "")
+	env.seedRoom("room2")
+
+	h := NewAdminHandler(env.rooms, env.messages, nil, nil)
+	rec := serve(t, http.MethodGet, "/api/admin/debug/stats", h.DebugStats, "/api/admin/debug/stats", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+	}
+
+	var fields map[string]interface{}
+	decode(t, rec, &fields)
+	for _, field := range []string{"goroutines", "heap_alloc_bytes", "active_rooms", "active_participants"} {
+		if _, ok := fields[field]; !ok {
+			t.Errorf("response is missing %q: %s", field, rec.Body)
+		}
+	}
+
+	var stats DebugStatsResponse
+	decode(t, rec, &stats)
+	if stats.Goroutines <= 0 || stats.HeapAllocBytes == 0 {
+		t.Errorf("runtime stats not filled in: %+v", stats)
+	}
+	if stats.ActiveRooms != 2 || stats.ActiveParticipants != 2 {
+		t.Errorf("active rooms/participants = %d/%d, want 2/2", stats.ActiveRooms, stats.ActiveParticipants)
+	}
+}
+
+func TestMaintenanceModeRefusesJoinsAndAdvisesReconnect(t *testing.T) {
+	env := newTestEnv(t)
+	env.seedRoom("room1")
+	env.seedRoom("room2")
+	maintenance := services.NewMaintenanceService(env.db, 30*time.Second)
+	admin := NewAdminHandler(env.rooms, env.messages, nil, maintenance)
+	join := MaintenanceGuard(maintenance)(http.HandlerFunc(NewRoomHandler(env.rooms, nil, PageSize{Default: 50, Max: 200}).JoinRoom)).ServeHTTP
+
+	rec := serve(t, http.MethodPost, "/api/admin/maintenance", admin.SetMaintenance, "/api/admin/maintenance", MaintenanceRequest{Enabled: true})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("enable: status = %d: %s", rec.Code, rec.Body)
+	}
+
+	advisories := env.srv.BroadcastsFor("reconnect")
+	topics := make([]string, 0, len(advisories))
+	for _, b := range advisories {
+		topics = append(topics, b.Topic)
+		var payload struct {
+			Reason    string `json:"reason"`
+			BackoffMS int64  `json:"backoff_ms"`
+		}
+		if err := json.Unmarshal(b.Payload, &payload); err != nil {
+			t.Fatalf("decode advisory: %v", err)
+		}
+		if payload.Reason != "maintenance" || payload.BackoffMS < 0 || payload.BackoffMS >= 30000 {
+			t.Errorf("advisory = %+v, want maintenance with backoff in [0, 30s)", payload)
+		}
+	}
+	slices.Sort(topics)
+	if !slices.Equal(topics, []string{"room:room1", "room:room2"}) {
+		t.Errorf("reconnect advisories = %v, want one per room", topics)
+	}
+
+	rec = serve(t, http.MethodPost, "/api/rooms/{id}/join", join, "/api/rooms/room1/join", models.JoinRoomRequest{Username: "late"})
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("join during maintenance: status = %d, Retry-After = %q, want 503 with Retry-After", rec.Code, rec.Header().Get("Retry-After"))
+	}
+
+	rec = serve(t, http.MethodPost, "/api/admin/maintenance", admin.SetMaintenance, "/api/admin/maintenance", MaintenanceRequest{Enabled: false})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("disable: status = %d: %s", rec.Code, rec.Body)
+	}
+	rec = serve(t, http.MethodPost, "/api/rooms/{id}/join", join, "/api/rooms/room1/join", models.JoinRoomRequest{Username: "late"})
+	if rec.Code != http.StatusOK {
+		t.Errorf("join after maintenance: status = %d, want 200: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestCleanupOrphans(t *testing.T) {
+	env := newTestEnv(t)
+	old := time.Now().UTC().Add(-time.Hour)
+	env.srv.AddRoom(models.Room{ID: "empty", Name: "Empty", CreatedAt: old, LastActiveAt: old, Persist: true, KeyVersion: 1})
+	env.srv.AddRoom(models.Room{ID: "busy", Name: "Busy", CreatedAt: old, LastActiveAt: old, Persist: true, KeyVersion: 1})
+	env.join(t, "busy", "alice", "")
+	cleanup := services.NewCleanupService(env.db, env.messages, nil, nil, env.rooms.InvalidateRoom, env.rooms.ForgetRoom, time.Minute,
+		5*time.Minute, 5*time.Minute, 0, false, services.RealClock{})
+	h := NewAdminHandler(env.rooms, env.messages, cleanup, nil)
+
+	rec := serve(t, http.MethodPost, "/api/admin/cleanup/orphans", h.CleanupOrphans, "/api/admin/cleanup/orphans", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	var resp CleanupOrphansResponse
+	decode(t, rec, &resp)
+	if resp.Deleted != 1 {
+		t.Errorf("deleted = %d, want 1", resp.Deleted)
+	}
+	if _, ok := env.srv.Room("empty"); ok {
+		t.Error("empty room still exists")
+	}
+	if _, ok := env.srv.Room("busy"); !ok {
+		t.Error("populated room was deleted")
+	}
+}
+
+func TestListParticipantsAcrossRooms(t *testing.T) {
+	env := newTestEnv(t)
+	var want []string
+	for _, roomID := range []string{"room1", "room2", "room3"} {
+		env.seedRoom(roomID)
+		p, _ := env.join(t, roomID, "user-"+roomID, "")
+		want = append(want, p.ID)
+	}
+	p, _ := env.join(t, "room1", "second", "")
+	want = append(want, p.ID)
+
+	h := AdminAuth("secret")(http.HandlerFunc(NewAdminHandler(env.rooms, env.messages, nil, nil).ListParticipants))
+	if rec := serve(t, http.MethodGet, "/api/admin/participants", h.ServeHTTP, "/api/admin/participants", nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("without token: status = %d, want 401", rec.Code)
+	}
+
+	var got []string
+	for offset := 0; offset < 6; offset += 3 {
+		target := "/api/admin/participants?limit=3&offset=" + strconv.Itoa(offset)
+		rec := serve(t, http.MethodGet, "/api/admin/participants", h.ServeHTTP, target, nil, "Authorization", "Bearer secret")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+		}
+		var page ParticipantsPageResponse
+		decode(t, rec, &page)
+		if page.Limit != 3 || page.Offset != offset {
+			t.Errorf("page limit/offset = %d/%d, want 3/%d", page.Limit, page.Offset, offset)
+		}
+		for _, p := range page.Participants {
+			got = append(got, p.ID)
+		}
+	}
+
+	slices.Sort(want)
+	slices.Sort(got)
+	if !slices.Equal(got, want) {
+		t.Errorf("participants across pages = %v, want %v", got, want)
+	}
+
+	rec := serve(t, http.MethodGet, "/api/admin/participants", h.ServeHTTP, "/api/admin/participants?limit=500", nil, "Authorization", "Bearer secret")
+	var page ParticipantsPageResponse
+	decode(t, rec, &page)
+	if page.Limit != adminPageSize.Max {
+		t.Errorf("oversized limit = %d, want clamped to %d", page.Limit, adminPageSize.Max)
+	}
+}
+
+func TestCountsReportsRoomsAndParticipants(t *testing.T) {
+	env := newTestEnv(t)
+	env.seedRoom("room1")
+	env.seedRoom("room2")
+	env.join(t, "room1", "alice", "")
+	env.join(t, "room1", "bob", "")
+	env.join(t, "room2", "carol", "")
+	env.srv.ResetRequests()
+
+	h := NewAdminHandler(env.rooms, env.messages, nil, nil)
+	rec := serve(t, http.MethodGet, "/api/admin/counts", h.Counts, "/api/admin/counts", nil)
+	var resp CountsResponse
+	decode(t, rec, &resp)
+	if resp.Rooms != 2 || resp.Participants != 3 {
+		t.Errorf("counts = %+v, want 2 rooms and 3 participants", resp)
+	}
+	for _, req := range env.srv.Requests() {
+		if req.Query.Get("limit") != "0" {
+			t.Errorf("%s %s?%s fetched rows, want count-only queries", req.Method, req.Path, req.Query.Encode())
+		}
+	}
+}
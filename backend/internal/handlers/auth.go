@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/adi-253/Talkie/backend/internal/services"
+)
+
+// participantSecret returns the participant credential sent as
+// "Authorization: Bearer <signing secret>", or "" if none was sent.
+func participantSecret(r *http.Request) string {
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, "Bearer ") {
+		return ""
+	}
+	return strings.TrimPrefix(header, "Bearer ")
+}
+
+// participantAuthenticator checks participant credentials; implemented by
+// services.RoomService and services.MessageService.
+type participantAuthenticator interface {
+	Authenticate(participantID, secret string) bool
+}
+
+// requireParticipant writes a 401 and returns false unless the request carries
+// participantID's credential, so a body participant_id can't be spoofed.
+func requireParticipant(w http.ResponseWriter, r *http.Request, auth participantAuthenticator, participantID string) bool {
+	if !auth.Authenticate(participantID, participantSecret(r)) {
+		writeError(w, r, http.StatusUnauthorized, services.ErrInvalidCredential.Error())
+		return false
+	}
+	return true
+}
@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+)
+
+// MaxBodySize returns middleware that caps request bodies at limit bytes so an
+// oversized body can't exhaust memory when decoded. Requests that declare a
+// larger Content-Length are refused with 413 up front; bodies without a
+// declared length fail on read once past the limit (see writeDecodeError).
+// A limit of 0 disables the cap.
+func MaxBodySize(limit int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if limit <= 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > limit {
+				writeError(w, r, http.StatusRequestEntityTooLarge, "request body too large")
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, limit)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// writeDecodeError writes the error response for a request body that failed
+// to decode: 413 if it exceeded MaxBodySize, otherwise 400.
+func writeDecodeError(w http.ResponseWriter, r *http.Request, err error) {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		writeError(w, r, http.StatusRequestEntityTooLarge, "request body too large")
+		return
+	}
+	writeError(w, r, http.StatusBadRequest, "invalid request body")
+}
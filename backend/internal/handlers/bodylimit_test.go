@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMaxBodySizeOnCreateRoom(t *testing.T) {
+	env := newTestEnv(t)
+	create := NewRoomHandler(env.rooms, nil, PageSize{Default: 50, Max: 200}).CreateRoom
+	oversized := `{"name":"` + strings.Repeat("a", 1024) + `"}`
+
+	tests := []struct {
+		name          string
+		limit         int64
+		body          string
+		unknownLength bool
+		want          int
+	}{
+		{"small body", 256, `{"name":"Book Club"}`, false, http.StatusCreated},
+		{"declared length over the limit", 256, oversized, false, http.StatusRequestEntityTooLarge},
+		{"undeclared length over the limit", 256, oversized, true, http.StatusRequestEntityTooLarge},
+		{"unparsable body under the limit falls back to defaults", 256, `{"name":`, false, http.StatusCreated},
+		{"limit disabled", 0, oversized, false, http.StatusCreated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := MaxBodySize(tt.limit)(http.HandlerFunc(create))
+			req := httptest.NewRequest(http.MethodPost, "/api/rooms", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.unknownLength {
+				req.ContentLength = -1
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+}
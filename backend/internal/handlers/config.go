@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+)
+
+// ClientConfigResponse holds server-recommended client settings.
+// Durations are in milliseconds so browsers can use them directly with timers.
+type ClientConfigResponse struct {
+	// HeartbeatIntervalMs is how often clients should POST a heartbeat
+	HeartbeatIntervalMs int64 `json:"heartbeat_interval_ms"`
+
+	// HeartbeatJitterMs is the maximum random delay clients should add to each
+	// heartbeat so they don't all hit the server at the same moment
+	HeartbeatJitterMs int64 `json:"heartbeat_jitter_ms"`
+
+	// PollIntervalMs is how often polling clients should fetch new messages
+	PollIntervalMs int64 `json:"poll_interval_ms"`
+
+	// MaxMessageSize is the maximum stored message content length in bytes
+	MaxMessageSize int `json:"max_message_size"`
+
+	// Features lists the optional features enabled on this server
+	Features []string `json:"features"`
+}
+
+// ClientConfig holds the server settings advertised to clients.
+type ClientConfig struct {
+	HeartbeatInterval time.Duration
+	HeartbeatJitter   time.Duration
+	PollInterval      time.Duration
+	MaxMessageSize    int
+	Features          []string
+}
+
+// ConfigHandler serves client configuration derived from server config.
+type ConfigHandler struct {
+	config ClientConfig
+}
+
+// NewConfigHandler creates a new ConfigHandler instance.
+func NewConfigHandler(config ClientConfig) *ConfigHandler {
+	return &ConfigHandler{config: config}
+}
+
+// GetClientConfig handles GET /api/config/client
+// Returns recommended client settings so clients can self-configure on join.
+func (h *ConfigHandler) GetClientConfig(w http.ResponseWriter, r *http.Request) {
+	response := ClientConfigResponse{
+		HeartbeatIntervalMs: h.config.HeartbeatInterval.Milliseconds(),
+		HeartbeatJitterMs:   h.config.HeartbeatJitter.Milliseconds(),
+		PollIntervalMs:      h.config.PollInterval.Milliseconds(),
+		MaxMessageSize:      h.config.MaxMessageSize,
+		Features:            h.config.Features,
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestGetClientConfig(t *testing.T) {
+	h := NewConfigHandler(ClientConfig{
+		HeartbeatInterval: 20 * time.Second,
+		HeartbeatJitter:   4 * time.Second,
+		PollInterval:      2500 * time.Millisecond,
+		MaxMessageSize:    4096,
+		Features:          []string{"reactions", "typing"},
+	})
+
+	rec := serve(t, http.MethodGet, "/api/config/client", h.GetClientConfig, "/api/config/client", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	var got ClientConfigResponse
+	decode(t, rec, &got)
+
+	want := ClientConfigResponse{
+		HeartbeatIntervalMs: 20000,
+		HeartbeatJitterMs:   4000,
+		PollIntervalMs:      2500,
+		MaxMessageSize:      4096,
+		Features:            []string{"reactions", "typing"},
+	}
+	if got.HeartbeatIntervalMs != want.HeartbeatIntervalMs || got.HeartbeatJitterMs != want.HeartbeatJitterMs ||
+		got.PollIntervalMs != want.PollIntervalMs || got.MaxMessageSize != want.MaxMessageSize ||
+		!slices.Equal(got.Features, want.Features) {
+		t.Errorf("config = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"mime"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// RequireJSON is middleware that rejects POST, PUT and PATCH requests whose
+// body isn't declared as application/json with 415 Unsupported Media Type,
+// instead of letting handlers fail with a confusing decode error.
+// Requests without a body (e.g. creating a room with defaults) are allowed.
+func RequireJSON(next http.Handler) http.Handler {
+	return requireMediaType(next, "application/json")
+}
+
+// RequireBeaconJSON is RequireJSON for endpoints fed by navigator.sendBeacon.
+// It also accepts JSON declared as text/plain: unlike application/json that
+// type is CORS-safelisted, so cross-origin beacons don't need a preflight.
+func RequireBeaconJSON(next http.Handler) http.Handler {
+	return requireMediaType(next, "application/json", "text/plain")
+}
+
+// requireMediaType rejects POST, PUT and PATCH requests with a body whose
+// Content-Type isn't one of mediaTypes.
+func requireMediaType(next http.Handler, mediaTypes ...string) http.Handler {
+	message := "Content-Type must be " + strings.Join(mediaTypes, " or ")
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost, http.MethodPut, http.MethodPatch:
+			if r.ContentLength == 0 {
+				break
+			}
+			mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+			if err != nil || !slices.Contains(mediaTypes, mediaType) {
+				writeError(w, r, http.StatusUnsupportedMediaType, message)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequireJSON(t *testing.T) {
+	env := newTestEnv(t)
+	h := RequireJSON(http.HandlerFunc(NewRoomHandler(env.rooms, nil, PageSize{Default: 50, Max: 200}).CreateRoom))
+
+	tests := []struct {
+		name        string
+		method      string
+		contentType string
+		body        string
+		want        int
+	}{
+		{"json body", http.MethodPost, "application/json", `{"name":"Book Club"}`, http.StatusCreated},
+		{"json with charset", http.MethodPost, "application/json; charset=utf-8", `{"name":"Book Club"}`, http.StatusCreated},
+		{"form body", http.MethodPost, "application/x-www-form-urlencoded", "name=Book+Club", http.StatusUnsupportedMediaType},
+		{"text body", http.MethodPost, "text/plain", `{"name":"Book Club"}`, http.StatusUnsupportedMediaType},
+		{"missing content type", http.MethodPost, "", `{"name":"Book Club"}`, http.StatusUnsupportedMediaType},
+		{"empty body create", http.MethodPost, "", "", http.StatusCreated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/api/rooms", strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+
+	// Only POST, PUT and PATCH are checked
+	req := httptest.NewRequest(http.MethodGet, "/api/rooms", nil)
+	rec := httptest.NewRecorder()
+	RequireJSON(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })).ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("GET: status = %d, want 200", rec.Code)
+	}
+}
+
+func TestRequireBeaconJSON(t *testing.T) {
+	h := RequireBeaconJSON(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }))
+
+	tests := []struct {
+		contentType string
+		want        int
+	}{
+		{"application/json", http.StatusOK},
+		{"text/plain;charset=UTF-8", http.StatusOK},
+		{"application/x-www-form-urlencoded", http.StatusUnsupportedMediaType},
+		{"", http.StatusUnsupportedMediaType},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/api/leave", strings.NewReader(`[]`))
+		if tt.contentType != "" {
+			req.Header.Set("Content-Type", tt.contentType)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("Content-Type %q: status = %d, want %d: %s", tt.contentType, rec.Code, tt.want, rec.Body)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/adi-253/Talkie/backend/internal/supabase"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// ErrorResponse is the JSON body returned for all API errors.
+// RequestID lets users give us a reference we can correlate with server logs.
+type ErrorResponse struct {
+	Error     string `json:"error"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// RequestIDHeader is middleware that echoes the request ID generated by
+// middleware.RequestID back to the client in the X-Request-ID header.
+// It must be mounted after middleware.RequestID.
+func RequestIDHeader(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if reqID := middleware.GetReqID(r.Context()); reqID != "" {
+			w.Header().Set("X-Request-ID", reqID)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// writeInternalError writes an error response for an unexpected service error.
+// Upstream rate limiting is reported as 503 with Retry-After so clients back off;
+// anything else is a 500.
+func writeInternalError(w http.ResponseWriter, r *http.Request, err error) {
+	var rateLimitErr *supabase.RateLimitError
+	if errors.As(err, &rateLimitErr) {
+		retryAfter := int(math.Ceil(rateLimitErr.RetryAfter.Seconds()))
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+		writeError(w, r, http.StatusServiceUnavailable, "service is busy, please retry")
+		return
+	}
+	writeError(w, r, http.StatusInternalServerError, err.Error())
+}
+
+// writeError writes a JSON error response carrying the request ID and logs
+// the error with the same ID so it can be correlated later.
+func writeError(w http.ResponseWriter, r *http.Request, status int, message string) {
+	reqID := middleware.GetReqID(r.Context())
+	log.Printf("[Error] request_id=%s status=%d %s %s: %s", reqID, status, r.Method, r.URL.Path, message)
+
+	writeJSON(w, status, ErrorResponse{
+		Error:     message,
+		RequestID: reqID,
+	})
+}
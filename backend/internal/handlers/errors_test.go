@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/adi-253/Talkie/backend/internal/supabase"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+func TestErrorResponseCarriesRequestID(t *testing.T) {
+	var reqID string
+	h := middleware.RequestID(RequestIDHeader(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reqID = middleware.GetReqID(r.Context())
+		writeError(w, r, http.StatusBadRequest, "bad input")
+	})))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/rooms", nil))
+
+	if reqID == "" {
+		t.Fatal("middleware did not set a request ID")
+	}
+	if got := rec.Header().Get("X-Request-ID"); got != reqID {
+		t.Errorf("X-Request-ID = %q, want %q", got, reqID)
+	}
+	var body ErrorResponse
+	decode(t, rec, &body)
+	if body.Error != "bad input" || body.RequestID != reqID {
+		t.Errorf("body = %+v, want error %q with request_id %q", body, "bad input", reqID)
+	}
+}
+
+func TestRequestIDHeaderEchoesIncomingID(t *testing.T) {
+	h := middleware.RequestID(RequestIDHeader(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, r, http.StatusNotFound, "missing")
+	})))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/rooms/x", nil)
+	req.Header.Set(middleware.RequestIDHeader, "client-ref-42")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	var body ErrorResponse
+	decode(t, rec, &body)
+	if rec.Header().Get("X-Request-ID") != "client-ref-42" || body.RequestID != "client-ref-42" {
+		t.Errorf("header = %q, body request_id = %q, want client-ref-42", rec.Header().Get("X-Request-ID"), body.RequestID)
+	}
+}
+
+func TestUpstreamRateLimitMapsTo503(t *testing.T) {
+	rec := serve(t, http.MethodPost, "/api/rooms", func(w http.ResponseWriter, r *http.Request) {
+		writeInternalError(w, r, fmt.Errorf("failed to create room: %w", &supabase.RateLimitError{RetryAfter: 1500 * time.Millisecond}))
+	}, "/api/rooms", nil)
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want 503", rec.Code)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "2" {
+		t.Errorf("Retry-After = %q, want 2 (rounded up)", got)
+	}
+}
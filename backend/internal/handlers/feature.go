@@ -0,0 +1,21 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/adi-253/Talkie/backend/internal/config"
+)
+
+// RequireFeature is middleware that responds 404 Not Found when the named
+// feature is disabled, so a switched-off endpoint looks like it doesn't exist.
+func RequireFeature(flags config.FeatureFlags, name string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !flags.Enabled(name) {
+				writeError(w, r, http.StatusNotFound, "not found")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/adi-253/Talkie/backend/internal/config"
+)
+
+func TestRequireFeature(t *testing.T) {
+	env := newTestEnv(t)
+	env.seedRoom("room1")
+	search := NewMessageHandler(env.messages, nil).SearchMessages
+
+	tests := []struct {
+		name  string
+		flags config.FeatureFlags
+		want  int
+	}{
+		{"flag off", config.NewFeatureFlags([]string{config.FeatureLock}), http.StatusNotFound},
+		{"flag on", config.NewFeatureFlags([]string{config.FeatureSearch}), http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := RequireFeature(tt.flags, config.FeatureSearch)(http.HandlerFunc(search))
+			rec := serve(t, http.MethodGet, "/api/rooms/{id}/messages/search", h.ServeHTTP, "/api/rooms/room1/messages/search?q=hi", nil)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+}
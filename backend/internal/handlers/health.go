@@ -3,6 +3,8 @@ package handlers
 import (
 	"encoding/json"
 	"net/http"
+
+	"github.com/adi-253/Talkie/backend/internal/services"
 )
 
 // HealthResponse represents the health check response structure.
@@ -23,3 +25,27 @@ func HealthCheck(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(response)
 }
+
+// ReadinessCheck returns a handler for GET /ready that reports unhealthy (503)
+// if a background worker has stalled, so monitoring catches it.
+func ReadinessCheck(cleanup *services.CleanupService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		response := HealthResponse{
+			Status:  "ok",
+			Message: "Talkie backend is ready",
+		}
+		status := http.StatusOK
+
+		if err := cleanup.CheckLiveness(); err != nil {
+			response = HealthResponse{
+				Status:  "unhealthy",
+				Message: err.Error(),
+			}
+			status = http.StatusServiceUnavailable
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(response)
+	}
+}
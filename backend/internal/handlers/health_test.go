@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/adi-253/Talkie/backend/internal/services"
+)
+
+func TestReadinessCheck(t *testing.T) {
+	env := newTestEnv(t)
+
+	tests := []struct {
+		name       string
+		interval   time.Duration
+		wantCode   int
+		wantStatus string
+	}{
+		{"recent run", time.Hour, http.StatusOK, "ok"},
+		{"stalled worker", time.Nanosecond, http.StatusServiceUnavailable, "unhealthy"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cleanup := services.NewCleanupService(env.db, env.messages, nil, nil, nil, nil, tt.interval,
+				time.Minute, time.Hour, 0, false, services.RealClock{})
+			time.Sleep(time.Millisecond)
+
+			rec := serve(t, http.MethodGet, "/ready", ReadinessCheck(cleanup), "/ready", nil)
+			if rec.Code != tt.wantCode {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantCode)
+			}
+			var resp HealthResponse
+			decode(t, rec, &resp)
+			if resp.Status != tt.wantStatus {
+				t.Errorf("status field = %q, want %q", resp.Status, tt.wantStatus)
+			}
+		})
+	}
+}
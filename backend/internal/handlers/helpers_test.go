@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/adi-253/Talkie/backend/internal/models"
+	"github.com/adi-253/Talkie/backend/internal/services"
+	"github.com/adi-253/Talkie/backend/internal/supabase"
+	"github.com/adi-253/Talkie/backend/internal/supabase/supabasetest"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/google/uuid"
+)
+
+// testEnv wires real services to a fake Supabase project.
+type testEnv struct {
+	srv      *supabasetest.Server
+	db       *supabase.Client
+	messages *services.MessageService
+	rooms    *services.RoomService
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+	srv := supabasetest.NewServer(t)
+	db := supabase.NewClient(srv.Config())
+	clock := services.RealClock{}
+	messages := services.NewMessageService(db, services.MessageLimits{}, services.MessageRetention{}, clock,
+		services.NewMessageSigner([]byte("test-signing-key")), nil)
+	rooms := services.NewRoomService(db, messages, nil, services.RoomSettings{
+		Avatars:       []string{"avatar1", "avatar2"},
+		DefaultAvatar: "avatar1",
+		RoomIDBytes:   4,
+	}, clock, rand.Reader)
+	return &testEnv{srv: srv, db: db, messages: messages, rooms: rooms}
+}
+
+// seedRoom stores a room directly in the fake database.
+func (e *testEnv) seedRoom(id string) models.Room {
+	now := time.Now().UTC()
+	room := models.Room{ID: id, Name: "Test Room", CreatedAt: now, LastActiveAt: now, Persist: true, KeyVersion: 1}
+	e.srv.AddRoom(room)
+	return room
+}
+
+// join adds a participant through RoomService and returns it with its secret.
+func (e *testEnv) join(t *testing.T, roomID, username, role string) (models.Participant, string) {
+	t.Helper()
+	p, _, _, err := e.rooms.JoinRoom(roomID, username, "", role, "", "", "")
+	if err != nil {
+		t.Fatalf("JoinRoom(%s): %v", username, err)
+	}
+	return *p, e.rooms.SigningSecret(p.ID)
+}
+
+// serve routes a single request to h mounted at pattern and records the response.
+// headers are given as name, value pairs.
+func serve(t *testing.T, method, pattern string, h http.HandlerFunc, target string, body interface{}, headers ...string) *httptest.ResponseRecorder {
+	t.Helper()
+	var reader *bytes.Reader
+	switch b := body.(type) {
+	case nil:
+		reader = bytes.NewReader(nil)
+	case string:
+		reader = bytes.NewReader([]byte(b))
+	default:
+		data, err := json.Marshal(b)
+		if err != nil {
+			t.Fatalf("marshal body: %v", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req := httptest.NewRequest(method, target, reader)
+	req.Header.Set("Content-Type", "application/json")
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+
+	r := chi.NewRouter()
+	r.Use(middleware.RequestID)
+	r.Method(method, pattern, h)
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	return rec
+}
+
+// decode unmarshals a JSON response body.
+func decode(t *testing.T, rec *httptest.ResponseRecorder, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+		t.Fatalf("decode response %q: %v", rec.Body.String(), err)
+	}
+}
+
+// bearer returns the Authorization header pair for a participant secret.
+func bearer(secret string) []string {
+	return []string{"Authorization", "Bearer " + secret}
+}
+
+// newID returns a random participant-style ID.
+func newID() string {
+	return uuid.New().String()
+}
@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/adi-253/Talkie/backend/internal/services"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// ValidateRoomID is middleware that rejects requests whose {id} path param
+// isn't a well-formed room ID with 400 Bad Request, so malformed IDs never
+// reach a Supabase filter. It must run after the {id} param is routed.
+func ValidateRoomID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !services.ValidRoomID(chi.URLParam(r, "id")) {
+			writeError(w, r, http.StatusBadRequest, "invalid room ID")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// validUUID reports whether id is a UUID, the format of participant and message IDs.
+func validUUID(id string) bool {
+	_, err := uuid.Parse(id)
+	return err == nil
+}
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestValidateRoomID(t *testing.T) {
+	tests := []struct {
+		name string
+		id   string
+		want int
+	}{
+		{"hex ID", "5d4a7e9f", http.StatusOK},
+		{"long hex ID", strings.Repeat("ab", 24), http.StatusOK},
+		{"slug", "book-club-2024", http.StatusOK},
+		{"too long", strings.Repeat("a", 49), http.StatusBadRequest},
+		{"uppercase", "5D4A7E9F", http.StatusBadRequest},
+		{"filter injection", "x&participants.id=neq.0", http.StatusBadRequest},
+		{"operator syntax", "eq.abc", http.StatusBadRequest},
+		{"spaces", "book club", http.StatusBadRequest},
+		{"leading hyphen", "-abc", http.StatusBadRequest},
+		{"double hyphen", "a--b", http.StatusBadRequest},
+		{"unicode", "café", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reached := false
+			h := ValidateRoomID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { reached = true }))
+			rec := serve(t, http.MethodGet, "/api/rooms/{id}", h.ServeHTTP, "/api/rooms/"+url.PathEscape(tt.id), nil)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+			if reached != (tt.want == http.StatusOK) {
+				t.Errorf("handler reached = %v for a %d", reached, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidUUID(t *testing.T) {
+	for id, want := range map[string]bool{
+		newID():                   true,
+		"":                        false,
+		"not-a-uuid":              false,
+		"5d4a7e9f":                false,
+		newID() + "&room_id=eq.x": false,
+	} {
+		if got := validUUID(id); got != want {
+			t.Errorf("validUUID(%q) = %v, want %v", id, got, want)
+		}
+	}
+}
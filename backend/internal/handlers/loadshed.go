@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// LoadShed returns middleware that refuses requests with 503 once more than
+// maxInFlight are being handled concurrently, e.g. when thousands of clients
+// rejoin at once after a restart. Each refused request gets a random
+// Retry-After in [1s, maxBackoff] so the retries spread out instead of
+// arriving as another storm. A maxInFlight of 0 disables shedding.
+func LoadShed(maxInFlight int, maxBackoff time.Duration) func(http.Handler) http.Handler {
+	var inFlight atomic.Int64
+
+	return func(next http.Handler) http.Handler {
+		if maxInFlight <= 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer inFlight.Add(-1)
+			if inFlight.Add(1) > int64(maxInFlight) {
+				w.Header().Set("Retry-After", strconv.Itoa(jitteredRetryAfter(maxBackoff)))
+				writeError(w, r, http.StatusServiceUnavailable, "server is busy, please retry")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// jitteredRetryAfter returns a random Retry-After value in whole seconds,
+// at least 1 and at most maxBackoff rounded up.
+func jitteredRetryAfter(maxBackoff time.Duration) int {
+	limit := int(math.Ceil(maxBackoff.Seconds()))
+	if limit <= 1 {
+		return 1
+	}
+	return 1 + rand.IntN(limit)
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLoadShedRefusesAboveThreshold(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	h := LoadShed(2, 5*time.Second)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/join", nil))
+		}()
+		<-started
+	}
+
+	// With two joins in flight, further joins are shed with a backoff hint
+	for i := 0; i < 20; i++ {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/join", nil))
+		if rec.Code != http.StatusServiceUnavailable {
+			t.Fatalf("status = %d, want 503", rec.Code)
+		}
+		retry, err := strconv.Atoi(rec.Header().Get("Retry-After"))
+		if err != nil || retry < 1 || retry > 5 {
+			t.Fatalf("Retry-After = %q, want 1-5 seconds", rec.Header().Get("Retry-After"))
+		}
+	}
+
+	close(release)
+	wg.Wait()
+
+	go func() { <-started }()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/join", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("after load drops: status = %d, want 200", rec.Code)
+	}
+}
+
+func TestLoadShedDisabled(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	h := LoadShed(0, time.Second)(next)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/join", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("status = %d, want 200", rec.Code)
+	}
+}
+
+func TestJitteredRetryAfterBounds(t *testing.T) {
+	for _, tt := range []struct {
+		backoff time.Duration
+		max     int
+	}{
+		{0, 1},
+		{500 * time.Millisecond, 1},
+		{2500 * time.Millisecond, 3},
+		{10 * time.Second, 10},
+	} {
+		for i := 0; i < 50; i++ {
+			if got := jitteredRetryAfter(tt.backoff); got < 1 || got > tt.max {
+				t.Fatalf("jitteredRetryAfter(%v) = %d, want 1-%d", tt.backoff, got, tt.max)
+			}
+		}
+	}
+}
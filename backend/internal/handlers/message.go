@@ -2,11 +2,15 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
+	"strconv"
 	"time"
 
+	"github.com/adi-253/Talkie/backend/internal/logging"
 	"github.com/adi-253/Talkie/backend/internal/models"
+	"github.com/adi-253/Talkie/backend/internal/ratelimit"
 	"github.com/adi-253/Talkie/backend/internal/services"
 	"github.com/go-chi/chi/v5"
 )
@@ -15,62 +19,123 @@ import (
 // Provides a polling-based fallback when WebSocket realtime fails.
 type MessageHandler struct {
 	messageService *services.MessageService
+
+	// sendLimiter throttles sends per participant (nil disables)
+	sendLimiter *ratelimit.TokenBucket
 }
 
 // NewMessageHandler creates a new MessageHandler instance.
-func NewMessageHandler(messageService *services.MessageService) *MessageHandler {
-	return &MessageHandler{messageService: messageService}
+// sendLimiter applies a burst and sustained send rate per participant; nil disables it.
+func NewMessageHandler(messageService *services.MessageService, sendLimiter *ratelimit.TokenBucket) *MessageHandler {
+	return &MessageHandler{messageService: messageService, sendLimiter: sendLimiter}
 }
 
 // SendMessage handles POST /api/rooms/{id}/messages
-// Stores an encrypted message for the room.
+// Stores an encrypted message for the room. The response is the stored message,
+// including its assigned seq and server_timestamp, so polling clients can
+// order it consistently with messages received over realtime.
 func (h *MessageHandler) SendMessage(w http.ResponseWriter, r *http.Request) {
 	roomID := chi.URLParam(r, "id")
 	if roomID == "" {
-		http.Error(w, "room ID is required", http.StatusBadRequest)
+		writeError(w, r, http.StatusBadRequest, "room ID is required")
 		return
 	}
 
 	var req models.SendMessageRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "invalid request body", http.StatusBadRequest)
+		writeDecodeError(w, r, err)
 		return
 	}
 
 	if req.Content == "" {
-		http.Error(w, "content is required", http.StatusBadRequest)
+		writeError(w, r, http.StatusBadRequest, "content is required")
+		return
+	}
+	if req.ParticipantID == "" {
+		writeError(w, r, http.StatusBadRequest, "participant ID is required")
 		return
 	}
 
-	msg := h.messageService.SendMessage(roomID, req)
-	log.Printf("[Message] Stored message %s in room %s from participant %s", msg.ID, roomID, req.ParticipantID)
+	// Throttle per participant
+	if h.sendLimiter != nil {
+		if !applyRateLimit(w, r, h.sendLimiter.Allow(roomID+"/"+req.ParticipantID)) {
+			return
+		}
+	}
+
+	msg, err := h.messageService.SendMessage(roomID, req)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidMessage):
+			writeError(w, r, http.StatusBadRequest, err.Error())
+			return
+		case errors.Is(err, services.ErrForbidden), errors.Is(err, services.ErrInvalidSignature), errors.Is(err, services.ErrSendDenied):
+			writeError(w, r, http.StatusForbidden, err.Error())
+			return
+		case errors.Is(err, services.ErrRoomNotFound):
+			writeError(w, r, http.StatusNotFound, err.Error())
+			return
+		}
+		log.Printf("[Message] Failed to store message in room %s: %v", roomID, err)
+		writeInternalError(w, r, err)
+		return
+	}
+	logging.Debugf("[Message] Stored message %s in room %s from participant %s", msg.ID, roomID, req.ParticipantID)
 	writeJSON(w, http.StatusCreated, msg)
 }
 
+// maxMessagesLimit caps the limit query param of GetMessages.
+const maxMessagesLimit = 500
+
 // GetMessages handles GET /api/rooms/{id}/messages
-// Returns messages for the room, optionally filtered by 'after' timestamp.
+// Returns messages for the room, optionally filtered by timestamp and sender.
 // Query params:
-//   - after: ISO 8601 timestamp to get messages after (for polling)
+//   - after: ISO 8601 server timestamp to get messages after (for polling; use server_timestamp)
+//   - participant_id: only return messages from this participant
+//   - order: "asc" (default, send order) or "desc" (newest first)
+//   - limit: return at most this many messages (capped at maxMessagesLimit),
+//     counted after the other filters and in the requested order
 func (h *MessageHandler) GetMessages(w http.ResponseWriter, r *http.Request) {
 	roomID := chi.URLParam(r, "id")
 	if roomID == "" {
-		http.Error(w, "room ID is required", http.StatusBadRequest)
+		writeError(w, r, http.StatusBadRequest, "room ID is required")
 		return
 	}
 
+	filter := services.MessageFilter{
+		ParticipantID: r.URL.Query().Get("participant_id"),
+	}
+
 	// Parse optional 'after' query param for incremental polling
-	var afterTime time.Time
 	afterParam := r.URL.Query().Get("after")
 	if afterParam != "" {
 		parsed, err := time.Parse(time.RFC3339Nano, afterParam)
 		if err != nil {
-			http.Error(w, "invalid 'after' timestamp format", http.StatusBadRequest)
+			writeError(w, r, http.StatusBadRequest, "invalid 'after' timestamp format")
+			return
+		}
+		filter.After = parsed
+	}
+
+	switch r.URL.Query().Get("order") {
+	case "", "asc":
+	case "desc":
+		filter.Descending = true
+	default:
+		writeError(w, r, http.StatusBadRequest, "'order' must be 'asc' or 'desc'")
+		return
+	}
+
+	if param := r.URL.Query().Get("limit"); param != "" {
+		parsed, err := strconv.Atoi(param)
+		if err != nil || parsed <= 0 {
+			writeError(w, r, http.StatusBadRequest, "invalid 'limit' value")
 			return
 		}
-		afterTime = parsed
+		filter.Limit = min(parsed, maxMessagesLimit)
 	}
 
-	messages := h.messageService.GetMessages(roomID, afterTime)
+	messages := h.messageService.GetMessages(roomID, filter)
 	
 	response := models.GetMessagesResponse{
 		Messages: messages,
@@ -78,3 +143,110 @@ func (h *MessageHandler) GetMessages(w http.ResponseWriter, r *http.Request) {
 	
 	writeJSON(w, http.StatusOK, response)
 }
+
+// MarkRead handles POST /api/rooms/{id}/read
+// Advances the participant's read cursor; ephemeral messages are deleted once
+// every current participant has read them. Requires the participant's signing
+// secret as a Bearer token, so participants can only advance their own cursor.
+func (h *MessageHandler) MarkRead(w http.ResponseWriter, r *http.Request) {
+	roomID := chi.URLParam(r, "id")
+	if roomID == "" {
+		writeError(w, r, http.StatusBadRequest, "room ID is required")
+		return
+	}
+
+	var req models.MarkReadRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeDecodeError(w, r, err)
+		return
+	}
+
+	if req.ParticipantID == "" {
+		writeError(w, r, http.StatusBadRequest, "participant ID is required")
+		return
+	}
+	if !requireParticipant(w, r, h.messageService, req.ParticipantID) {
+		return
+	}
+
+	if err := h.messageService.MarkRead(roomID, req.ParticipantID, req.Seq); err != nil {
+		if errors.Is(err, services.ErrForbidden) {
+			writeError(w, r, http.StatusForbidden, err.Error())
+			return
+		}
+		log.Printf("[Message] Failed to mark read in room %s for %s: %v", roomID, req.ParticipantID, err)
+		writeInternalError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetMessage handles GET /api/rooms/{id}/messages/{messageId}
+// Returns a single stored message, e.g. to resolve a deep link or reply parent.
+func (h *MessageHandler) GetMessage(w http.ResponseWriter, r *http.Request) {
+	roomID := chi.URLParam(r, "id")
+	messageID := chi.URLParam(r, "messageId")
+	if roomID == "" || !validUUID(messageID) {
+		writeError(w, r, http.StatusBadRequest, "invalid message ID")
+		return
+	}
+
+	msg, err := h.messageService.GetMessage(roomID, messageID)
+	if err != nil {
+		writeError(w, r, http.StatusNotFound, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, msg)
+}
+
+// CountMessages handles GET /api/rooms/{id}/messages/count
+// Returns the number of messages in the room; rooms with no messages return 0.
+// Query params:
+//   - after_seq: only count messages with a greater sequence number (for unread badges)
+func (h *MessageHandler) CountMessages(w http.ResponseWriter, r *http.Request) {
+	roomID := chi.URLParam(r, "id")
+	if roomID == "" {
+		writeError(w, r, http.StatusBadRequest, "room ID is required")
+		return
+	}
+
+	var afterSeq int64
+	if param := r.URL.Query().Get("after_seq"); param != "" {
+		parsed, err := strconv.ParseInt(param, 10, 64)
+		if err != nil || parsed < 0 {
+			writeError(w, r, http.StatusBadRequest, "invalid 'after_seq' value")
+			return
+		}
+		afterSeq = parsed
+	}
+
+	writeJSON(w, http.StatusOK, models.MessageCountResponse{
+		Count: h.messageService.GetMessageCount(roomID, afterSeq),
+	})
+}
+
+// SearchMessages handles GET /api/rooms/{id}/search
+// Searches the room's plaintext system messages (encrypted messages can't be searched).
+// Query params:
+//   - q: case-insensitive substring to match (required)
+func (h *MessageHandler) SearchMessages(w http.ResponseWriter, r *http.Request) {
+	roomID := chi.URLParam(r, "id")
+	if roomID == "" {
+		writeError(w, r, http.StatusBadRequest, "room ID is required")
+		return
+	}
+
+	query := r.URL.Query().Get("q")
+	if query == "" {
+		writeError(w, r, http.StatusBadRequest, "query parameter 'q' is required")
+		return
+	}
+
+	response := models.GetMessagesResponse{
+		Messages: h.messageService.SearchSystemMessages(roomID, query),
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
@@ -0,0 +1,388 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adi-253/Talkie/backend/internal/models"
+	"github.com/adi-253/Talkie/backend/internal/ratelimit"
+	"github.com/adi-253/Talkie/backend/internal/services"
+)
+
+func TestGetMessagesParticipantFilter(t *testing.T) {
+	env := newTestEnv(t)
+	env.seedRoom("room1")
+	alice, _ := env.join(t, "room1", "alice", "")
+	bob, _ := env.join(t, "room1", "bob", "")
+	h := NewMessageHandler(env.messages, nil)
+
+	for _, sender := range []models.Participant{alice, bob, alice} {
+		if _, err := env.messages.SendMessage("room1", models.SendMessageRequest{ParticipantID: sender.ID, Content: "m"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rec := serve(t, http.MethodGet, "/api/rooms/{id}/messages", h.GetMessages, "/api/rooms/room1/messages?participant_id="+alice.ID, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	var resp models.GetMessagesResponse
+	decode(t, rec, &resp)
+	if len(resp.Messages) != 2 {
+		t.Fatalf("messages = %d, want 2", len(resp.Messages))
+	}
+	for _, msg := range resp.Messages {
+		if msg.ParticipantID != alice.ID {
+			t.Errorf("message from %s in alice's filter", msg.ParticipantID)
+		}
+	}
+
+	after := url.QueryEscape(resp.Messages[0].ServerTimestamp.Format(time.RFC3339Nano))
+	rec = serve(t, http.MethodGet, "/api/rooms/{id}/messages", h.GetMessages,
+		"/api/rooms/room1/messages?participant_id="+alice.ID+"&after="+after, nil)
+	decode(t, rec, &resp)
+	if len(resp.Messages) != 1 || resp.Messages[0].Seq != 3 {
+		t.Errorf("alice's messages after the first = %+v, want only seq 3", resp.Messages)
+	}
+}
+
+func TestSendMessageSizeLimits(t *testing.T) {
+	env := newTestEnv(t)
+	env.seedRoom("room1")
+	alice, _ := env.join(t, "room1", "alice", "")
+	messages := services.NewMessageService(env.db, services.MessageLimits{MaxContentLength: 100}, services.MessageRetention{},
+		services.RealClock{}, services.NewMessageSigner([]byte("test-signing-key")), nil)
+	h := MaxBodySize(1024)(http.HandlerFunc(NewMessageHandler(messages, nil).SendMessage)).ServeHTTP
+
+	tests := []struct {
+		name    string
+		content string
+		want    int
+	}{
+		{"within both limits", strings.Repeat("a", 100), http.StatusCreated},
+		{"content over the stored limit", strings.Repeat("a", 101), http.StatusBadRequest},
+		{"body over the transport limit", strings.Repeat("a", 2048), http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := models.SendMessageRequest{ParticipantID: alice.ID, Content: tt.content}
+			rec := serve(t, http.MethodPost, "/api/rooms/{id}/messages", h, "/api/rooms/room1/messages", body)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+	if got := len(messages.GetMessages("room1", services.MessageFilter{})); got != 1 {
+		t.Errorf("stored messages = %d, want 1", got)
+	}
+}
+
+func TestSearchMessages(t *testing.T) {
+	env := newTestEnv(t)
+	env.seedRoom("room1")
+	env.messages.PostSystemMessage("room1", "Host locked the room")
+	env.messages.PostSystemMessage("room1", "Welcome")
+	h := NewMessageHandler(env.messages, nil)
+
+	rec := serve(t, http.MethodGet, "/api/rooms/{id}/search", h.SearchMessages, "/api/rooms/room1/search?q=LOCKED", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	var resp models.GetMessagesResponse
+	decode(t, rec, &resp)
+	if len(resp.Messages) != 1 || resp.Messages[0].Seq != 1 {
+		t.Errorf("matches = %+v, want only seq 1", resp.Messages)
+	}
+
+	rec = serve(t, http.MethodGet, "/api/rooms/{id}/search", h.SearchMessages, "/api/rooms/room1/search", nil)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("search without q: status = %d, want 400", rec.Code)
+	}
+}
+
+func TestCountMessages(t *testing.T) {
+	env := newTestEnv(t)
+	env.seedRoom("room1")
+	env.seedRoom("quiet")
+	alice, _ := env.join(t, "room1", "alice", "")
+	for i := 0; i < 5; i++ {
+		if _, err := env.messages.SendMessage("room1", models.SendMessageRequest{ParticipantID: alice.ID, Content: "m"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h := NewMessageHandler(env.messages, nil)
+
+	tests := []struct {
+		name   string
+		target string
+		want   int
+	}{
+		{"populated room", "/api/rooms/room1/messages/count", 5},
+		{"after_seq", "/api/rooms/room1/messages/count?after_seq=3", 2},
+		{"after the last seq", "/api/rooms/room1/messages/count?after_seq=5", 0},
+		{"room without messages", "/api/rooms/quiet/messages/count", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(t, http.MethodGet, "/api/rooms/{id}/messages/count", h.CountMessages, tt.target, nil)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+			}
+			var resp models.MessageCountResponse
+			decode(t, rec, &resp)
+			if resp.Count != tt.want {
+				t.Errorf("count = %d, want %d", resp.Count, tt.want)
+			}
+		})
+	}
+
+	for _, param := range []string{"-1", "abc"} {
+		rec := serve(t, http.MethodGet, "/api/rooms/{id}/messages/count", h.CountMessages, "/api/rooms/room1/messages/count?after_seq="+param, nil)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("after_seq=%s: status = %d, want 400", param, rec.Code)
+		}
+	}
+}
+
+func TestObserverCannotSend(t *testing.T) {
+	env := newTestEnv(t)
+	env.seedRoom("room1")
+	observer, _ := env.join(t, "room1", "lurker", models.RoleObserver)
+	h := NewMessageHandler(env.messages, nil)
+
+	rec := serve(t, http.MethodPost, "/api/rooms/{id}/messages", h.SendMessage, "/api/rooms/room1/messages",
+		models.SendMessageRequest{ParticipantID: observer.ID, Content: "hi"})
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("status = %d, want 403: %s", rec.Code, rec.Body)
+	}
+
+	rec = serve(t, http.MethodPost, "/api/rooms/{id}/messages", h.SendMessage, "/api/rooms/room1/messages",
+		models.SendMessageRequest{Content: "hi"})
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("missing participant_id: status = %d, want 400: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestGetMessage(t *testing.T) {
+	env := newTestEnv(t)
+	env.seedRoom("room1")
+	env.seedRoom("room2")
+	alice, _ := env.join(t, "room1", "alice", "")
+	h := NewMessageHandler(env.messages, nil)
+
+	sent, err := env.messages.SendMessage("room1", models.SendMessageRequest{ParticipantID: alice.ID, Content: "deep link"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := serve(t, http.MethodGet, "/api/rooms/{id}/messages/{messageId}", h.GetMessage, "/api/rooms/room1/messages/"+sent.ID, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("existing message: status = %d: %s", rec.Code, rec.Body)
+	}
+	var got models.Message
+	decode(t, rec, &got)
+	if got.ID != sent.ID || got.Content != "deep link" || got.Seq != sent.Seq {
+		t.Errorf("got %+v, want message %s", got, sent.ID)
+	}
+
+	tests := []struct {
+		name   string
+		target string
+		want   int
+	}{
+		{"missing", "/api/rooms/room1/messages/" + newID(), http.StatusNotFound},
+		{"other room", "/api/rooms/room2/messages/" + sent.ID, http.StatusNotFound},
+		{"malformed ID", "/api/rooms/room1/messages/not-a-uuid", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(t, http.MethodGet, "/api/rooms/{id}/messages/{messageId}", h.GetMessage, tt.target, nil)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+}
+
+func TestSendMessageResponseCarriesSeq(t *testing.T) {
+	env := newTestEnv(t)
+	env.seedRoom("room1")
+	alice, _ := env.join(t, "room1", "alice", "")
+	h := NewMessageHandler(env.messages, nil)
+
+	before := time.Now().UTC()
+	for want := int64(1); want <= 2; want++ {
+		rec := serve(t, http.MethodPost, "/api/rooms/{id}/messages", h.SendMessage, "/api/rooms/room1/messages",
+			models.SendMessageRequest{ParticipantID: alice.ID, Content: "hi", Timestamp: before.Add(-time.Hour)})
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+		}
+
+		var fields map[string]json.RawMessage
+		decode(t, rec, &fields)
+		for _, field := range []string{"seq", "server_timestamp", "timestamp"} {
+			if _, ok := fields[field]; !ok {
+				t.Errorf("response is missing %q: %s", field, rec.Body)
+			}
+		}
+		var msg models.Message
+		decode(t, rec, &msg)
+		if msg.Seq != want {
+			t.Errorf("seq = %d, want %d", msg.Seq, want)
+		}
+		if msg.ServerTimestamp.Before(before) {
+			t.Errorf("server_timestamp = %v, want assigned at send (after %v)", msg.ServerTimestamp, before)
+		}
+	}
+}
+
+func TestSendMessageThrottledPerParticipant(t *testing.T) {
+	env := newTestEnv(t)
+	env.seedRoom("room1")
+	alice, _ := env.join(t, "room1", "alice", "")
+	bob, _ := env.join(t, "room1", "bob", "")
+	h := NewMessageHandler(env.messages, ratelimit.NewTokenBucket(2, time.Hour))
+
+	send := func(p models.Participant) int {
+		return serve(t, http.MethodPost, "/api/rooms/{id}/messages", h.SendMessage, "/api/rooms/room1/messages",
+			models.SendMessageRequest{ParticipantID: p.ID, Content: "hi"}).Code
+	}
+
+	for i := 0; i < 2; i++ {
+		if code := send(alice); code != http.StatusCreated {
+			t.Fatalf("burst message %d: status = %d, want 201", i+1, code)
+		}
+	}
+	if code := send(alice); code != http.StatusTooManyRequests {
+		t.Errorf("message past the burst: status = %d, want 429", code)
+	}
+	if code := send(bob); code != http.StatusCreated {
+		t.Errorf("another participant: status = %d, want 201", code)
+	}
+}
+
+func TestMarkReadRequiresCredentialAndMembership(t *testing.T) {
+	env := newTestEnv(t)
+	env.seedRoom("room1")
+	env.seedRoom("room2")
+	alice, aliceSecret := env.join(t, "room1", "alice", "")
+	outsider, outsiderSecret := env.join(t, "room2", "outsider", "")
+	h := NewMessageHandler(env.messages, nil)
+
+	tests := []struct {
+		name    string
+		body    models.MarkReadRequest
+		headers []string
+		want    int
+	}{
+		{"no credential", models.MarkReadRequest{ParticipantID: alice.ID, Seq: 1}, nil, http.StatusUnauthorized},
+		{"another participant's credential", models.MarkReadRequest{ParticipantID: alice.ID, Seq: 1}, bearer(outsiderSecret), http.StatusUnauthorized},
+		{"non-member", models.MarkReadRequest{ParticipantID: outsider.ID, Seq: 1}, bearer(outsiderSecret), http.StatusForbidden},
+		{"member", models.MarkReadRequest{ParticipantID: alice.ID, Seq: 1}, bearer(aliceSecret), http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(t, http.MethodPost, "/api/rooms/{id}/read", h.MarkRead, "/api/rooms/room1/read", tt.body, tt.headers...)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+}
+
+func TestGetMessagesOrderAndLimit(t *testing.T) {
+	env := newTestEnv(t)
+	env.seedRoom("room1")
+	alice, _ := env.join(t, "room1", "alice", "")
+	h := NewMessageHandler(env.messages, nil)
+	for i := 0; i < 5; i++ {
+		if _, err := env.messages.SendMessage("room1", models.SendMessageRequest{ParticipantID: alice.ID, Content: "m"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		query string
+		want  []int64
+	}{
+		{"", []int64{1, 2, 3, 4, 5}},
+		{"?order=asc", []int64{1, 2, 3, 4, 5}},
+		{"?order=desc", []int64{5, 4, 3, 2, 1}},
+		{"?limit=2", []int64{1, 2}},
+		{"?order=desc&limit=2", []int64{5, 4}},
+		{"?order=desc&limit=50", []int64{5, 4, 3, 2, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			rec := serve(t, http.MethodGet, "/api/rooms/{id}/messages", h.GetMessages, "/api/rooms/room1/messages"+tt.query, nil)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+			}
+			var resp models.GetMessagesResponse
+			decode(t, rec, &resp)
+			var seqs []int64
+			for _, msg := range resp.Messages {
+				seqs = append(seqs, msg.Seq)
+			}
+			if !slices.Equal(seqs, tt.want) {
+				t.Errorf("seqs = %v, want %v", seqs, tt.want)
+			}
+		})
+	}
+
+	for i := 0; i < maxMessagesLimit; i++ {
+		env.messages.PostSystemMessage("room1", "filler")
+	}
+	rec := serve(t, http.MethodGet, "/api/rooms/{id}/messages", h.GetMessages, "/api/rooms/room1/messages?limit=100000", nil)
+	var resp models.GetMessagesResponse
+	decode(t, rec, &resp)
+	if len(resp.Messages) != maxMessagesLimit {
+		t.Errorf("oversized limit returned %d messages, want capped at %d", len(resp.Messages), maxMessagesLimit)
+	}
+
+	for _, query := range []string{"?order=newest", "?limit=0", "?limit=-1", "?limit=ten"} {
+		if rec := serve(t, http.MethodGet, "/api/rooms/{id}/messages", h.GetMessages, "/api/rooms/room1/messages"+query, nil); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", query, rec.Code)
+		}
+	}
+}
+
+// denyUser is a SendAuthorizer that refuses one participant.
+type denyUser string
+
+func (d denyUser) AuthorizeSend(roomID, participantID string) (bool, string) {
+	return participantID != string(d), "not on the allowlist"
+}
+
+func TestSendMessageDeniedByAuthorizerReturns403(t *testing.T) {
+	env := newTestEnv(t)
+	env.seedRoom("room1")
+	alice, _ := env.join(t, "room1", "alice", "")
+	bob, _ := env.join(t, "room1", "bob", "")
+	messages := services.NewMessageService(env.db, services.MessageLimits{}, services.MessageRetention{}, services.RealClock{},
+		services.NewMessageSigner([]byte("test-signing-key")), denyUser(bob.ID))
+	h := NewMessageHandler(messages, nil)
+
+	send := func(participantID string) *httptest.ResponseRecorder {
+		return serve(t, http.MethodPost, "/api/rooms/{id}/messages", h.SendMessage, "/api/rooms/room1/messages",
+			models.SendMessageRequest{ParticipantID: participantID, Content: "hi"})
+	}
+	if rec := send(alice.ID); rec.Code != http.StatusCreated {
+		t.Errorf("allowed participant: status = %d: %s", rec.Code, rec.Body)
+	}
+	rec := send(bob.ID)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("denied participant: status = %d, want 403: %s", rec.Code, rec.Body)
+	}
+	var body ErrorResponse
+	decode(t, rec, &body)
+	if !strings.Contains(body.Error, "not on the allowlist") {
+		t.Errorf("error = %q, want the authorizer's reason", body.Error)
+	}
+}
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// PageSize bounds the page size of a paginated list endpoint.
+type PageSize struct {
+	// Default is used when the client doesn't send a limit
+	Default int
+
+	// Max caps any requested limit
+	Max int
+}
+
+// parsePage reads the limit and offset query params, applying the page size
+// bounds. On invalid values it writes a 400 and returns ok == false.
+func parsePage(w http.ResponseWriter, r *http.Request, size PageSize) (limit, offset int, ok bool) {
+	limit = size.Default
+	if param := r.URL.Query().Get("limit"); param != "" {
+		parsed, err := strconv.Atoi(param)
+		if err != nil || parsed <= 0 {
+			writeError(w, r, http.StatusBadRequest, "invalid 'limit' value")
+			return 0, 0, false
+		}
+		limit = parsed
+	}
+	limit = max(min(limit, size.Max), 1)
+
+	if param := r.URL.Query().Get("offset"); param != "" {
+		parsed, err := strconv.Atoi(param)
+		if err != nil || parsed < 0 {
+			writeError(w, r, http.StatusBadRequest, "invalid 'offset' value")
+			return 0, 0, false
+		}
+		offset = parsed
+	}
+	return limit, offset, true
+}
+
+// setNextLink advertises the next page in a Link header (rel="next") when the
+// current page is full, so clients can keep paging without a response envelope.
+func setNextLink(w http.ResponseWriter, r *http.Request, limit, offset, count int) {
+	if count < limit {
+		return
+	}
+	next := *r.URL
+	query := next.Query()
+	query.Set("limit", strconv.Itoa(limit))
+	query.Set("offset", strconv.Itoa(offset+limit))
+	next.RawQuery = query.Encode()
+	w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, next.RequestURI()))
+}
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/adi-253/Talkie/backend/internal/ratelimit"
+)
+
+// RateLimit returns middleware that limits requests per client IP.
+// It advertises the quota via X-RateLimit-Limit, X-RateLimit-Remaining and
+// X-RateLimit-Reset (unix seconds) headers, and responds 429 with Retry-After
+// once the limit is exceeded.
+func RateLimit(l *ratelimit.Limiter) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !applyRateLimit(w, r, l.Allow(clientIP(r))) {
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// applyRateLimit sets the X-RateLimit-* headers for a rate limit result.
+// If the request isn't allowed it writes a 429 with Retry-After and returns false.
+func applyRateLimit(w http.ResponseWriter, r *http.Request, result ratelimit.Result) bool {
+	w.Header().Set("X-RateLimit-Limit", strconv.Itoa(result.Limit))
+	w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(result.ResetAt.Unix(), 10))
+
+	if !result.Allowed {
+		retryAfter := int(time.Until(result.ResetAt).Seconds()) + 1
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+		writeError(w, r, http.StatusTooManyRequests, "rate limit exceeded")
+		return false
+	}
+	return true
+}
+
+// clientIP extracts the normalized client IP from the request's remote address.
+// TrustedRealIP may already have replaced RemoteAddr with a bare IP.
+func clientIP(r *http.Request) string {
+	if ip := parseIP(r.RemoteAddr); ip != nil {
+		return ip.String()
+	}
+	return r.RemoteAddr
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/adi-253/Talkie/backend/internal/ratelimit"
+)
+
+func TestRateLimitHeaders(t *testing.T) {
+	const window = time.Second
+	h := RateLimit(ratelimit.NewLimiter(2, window))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	send := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/rooms", nil)
+		req.RemoteAddr = "203.0.113.7:5000"
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for i, want := range []string{"1", "0"} {
+		rec := send()
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("request %d: status = %d, want 201", i+1, rec.Code)
+		}
+		if got := rec.Header().Get("X-RateLimit-Limit"); got != "2" {
+			t.Errorf("request %d: X-RateLimit-Limit = %q, want 2", i+1, got)
+		}
+		if got := rec.Header().Get("X-RateLimit-Remaining"); got != want {
+			t.Errorf("request %d: X-RateLimit-Remaining = %q, want %s", i+1, got, want)
+		}
+		reset, err := strconv.ParseInt(rec.Header().Get("X-RateLimit-Reset"), 10, 64)
+		if err != nil || reset < time.Now().Unix() {
+			t.Errorf("request %d: X-RateLimit-Reset = %q, want a future unix time", i+1, rec.Header().Get("X-RateLimit-Reset"))
+		}
+	}
+
+	rec := send()
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("over limit: status = %d, want 429", rec.Code)
+	}
+	if rec.Header().Get("Retry-After") == "" || rec.Header().Get("X-RateLimit-Remaining") != "0" {
+		t.Errorf("over limit headers = %v, want Retry-After and 0 remaining", rec.Header())
+	}
+
+	time.Sleep(window)
+	rec = send()
+	if rec.Code != http.StatusCreated || rec.Header().Get("X-RateLimit-Remaining") != "1" {
+		t.Errorf("after window: status = %d, remaining = %q, want 201 with 1 remaining", rec.Code, rec.Header().Get("X-RateLimit-Remaining"))
+	}
+}
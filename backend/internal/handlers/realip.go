@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ParseTrustedProxies parses a list of CIDRs (or bare IPs) of proxies whose
+// forwarded headers may be trusted.
+func ParseTrustedProxies(entries []string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, entry := range entries {
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q", entry)
+			}
+			bits := 128
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, ipNet, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", entry, err)
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
+
+// TrustedRealIP returns middleware that sets r.RemoteAddr to the client IP.
+// Unlike middleware.RealIP, X-Forwarded-For and X-Real-IP are only honored when
+// the connection comes from a trusted proxy, so clients can't spoof their IP to
+// bypass per-IP limits. The resulting IP is normalized (e.g. IPv4-mapped IPv6
+// addresses become plain IPv4) so per-IP buckets key consistently.
+func TrustedRealIP(trusted []*net.IPNet) func(http.Handler) http.Handler {
+	isTrusted := func(ip net.IP) bool {
+		for _, n := range trusted {
+			if n.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip := parseIP(r.RemoteAddr)
+			if ip != nil && isTrusted(ip) {
+				ip = forwardedIP(r, ip, isTrusted)
+			}
+			if ip != nil {
+				r.RemoteAddr = ip.String()
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// forwardedIP returns the client IP from the forwarded headers of a request
+// received from a trusted proxy. X-Forwarded-For is walked from the right,
+// skipping trusted proxies, so entries prepended by the client are ignored.
+// Falls back to X-Real-IP, then to the proxy's own IP.
+func forwardedIP(r *http.Request, proxyIP net.IP, isTrusted func(net.IP) bool) net.IP {
+	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+		hops := strings.Split(xff, ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			ip := parseIP(strings.TrimSpace(hops[i]))
+			if ip == nil {
+				break
+			}
+			if !isTrusted(ip) || i == 0 {
+				return ip
+			}
+		}
+	}
+
+	if ip := parseIP(r.Header.Get("X-Real-IP")); ip != nil {
+		return ip
+	}
+	return proxyIP
+}
+
+// parseIP parses an IP address with or without a port, normalizing
+// IPv4-mapped IPv6 addresses to IPv4. Returns nil if it isn't a valid IP.
+func parseIP(addr string) net.IP {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+	ip := net.ParseIP(strings.TrimSpace(addr))
+	if ip == nil {
+		return nil
+	}
+	if v4 := ip.To4(); v4 != nil {
+		return v4
+	}
+	return ip
+}
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTrustedRealIP(t *testing.T) {
+	trusted, err := ParseTrustedProxies([]string{"10.0.0.0/8", "fd00::1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		xff        string
+		realIP     string
+		want       string
+	}{
+		{"untrusted peer spoofing X-Forwarded-For", "203.0.113.9:4000", "198.51.100.1", "", "203.0.113.9"},
+		{"untrusted peer spoofing X-Real-IP", "203.0.113.9:4000", "", "198.51.100.1", "203.0.113.9"},
+		{"trusted proxy forwarding the client", "10.1.2.3:4000", "198.51.100.1", "", "198.51.100.1"},
+		{"trusted proxy with a client-prepended hop", "10.1.2.3:4000", "1.1.1.1, 198.51.100.1", "", "198.51.100.1"},
+		{"trusted proxy chain", "10.1.2.3:4000", "198.51.100.1, 10.9.9.9", "", "198.51.100.1"},
+		{"trusted proxy with X-Real-IP", "10.1.2.3:4000", "", "198.51.100.1", "198.51.100.1"},
+		{"trusted proxy without headers", "10.1.2.3:4000", "", "", "10.1.2.3"},
+		{"trusted IPv6 proxy", "[fd00::1]:4000", "2001:db8::7", "", "2001:db8::7"},
+		{"IPv4-mapped IPv6 peer", "[::ffff:203.0.113.9]:4000", "", "", "203.0.113.9"},
+		{"IPv6 peer is normalized", "[2001:DB8:0:0::7]:4000", "", "", "2001:db8::7"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			h := TrustedRealIP(trusted)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = clientIP(r)
+			}))
+			req := httptest.NewRequest(http.MethodPost, "/api/rooms", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.xff != "" {
+				req.Header.Set("X-Forwarded-For", tt.xff)
+			}
+			if tt.realIP != "" {
+				req.Header.Set("X-Real-IP", tt.realIP)
+			}
+			h.ServeHTTP(httptest.NewRecorder(), req)
+			if got != tt.want {
+				t.Errorf("client IP = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTrustedProxiesRejectsInvalidEntries(t *testing.T) {
+	for _, entry := range []string{"not-an-ip", "10.0.0.0/33"} {
+		if _, err := ParseTrustedProxies([]string{entry}); err == nil {
+			t.Errorf("ParseTrustedProxies(%q) succeeded, want an error", entry)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// Recover is middleware that turns a panic in a handler into a JSON 500
+// carrying the request ID, and logs the stack trace with the same ID so a
+// user's report can be tied to the exact panic. It must be mounted after
+// middleware.RequestID.
+func Recover(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			rec := recover()
+			if rec == nil {
+				return
+			}
+			// The server uses this to abort a response; let it through
+			if rec == http.ErrAbortHandler {
+				panic(rec)
+			}
+
+			reqID := middleware.GetReqID(r.Context())
+			log.Printf("[Panic] request_id=%s %s %s: %v\n%s", reqID, r.Method, r.URL.Path, rec, debug.Stack())
+
+			// Upgraded connections have no usable response writer
+			if r.Header.Get("Connection") == "Upgrade" {
+				return
+			}
+			writeError(w, r, http.StatusInternalServerError, "internal server error")
+		}()
+
+		next.ServeHTTP(w, r)
+	})
+}
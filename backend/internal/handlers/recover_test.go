@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+func TestRecoverReturnsJSONWithRequestID(t *testing.T) {
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	h := middleware.RequestID(RequestIDHeader(Recover(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/rooms", nil))
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want 500", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+		t.Errorf("Content-Type = %q, want JSON", ct)
+	}
+	var body ErrorResponse
+	decode(t, rec, &body)
+	reqID := rec.Header().Get("X-Request-ID")
+	if reqID == "" || body.RequestID != reqID {
+		t.Fatalf("body request_id = %q, X-Request-ID = %q, want the same non-empty ID", body.RequestID, reqID)
+	}
+
+	out := logs.String()
+	if !strings.Contains(out, "[Panic] request_id="+reqID) || !strings.Contains(out, "boom") {
+		t.Errorf("panic log does not carry the request ID and value:\n%s", out)
+	}
+	if !strings.Contains(out, "recover_test.go") {
+		t.Errorf("panic log has no stack trace pointing at the handler:\n%s", out)
+	}
+}
+
+func TestRecoverRepanicsAbortHandler(t *testing.T) {
+	h := Recover(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+
+	defer func() {
+		if rec := recover(); rec != http.ErrAbortHandler {
+			t.Errorf("recovered %v, want http.ErrAbortHandler to propagate", rec)
+		}
+	}()
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+}
@@ -2,11 +2,16 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"time"
 
 	"github.com/adi-253/Talkie/backend/internal/models"
+	"github.com/adi-253/Talkie/backend/internal/ratelimit"
 	"github.com/adi-253/Talkie/backend/internal/services"
+	"github.com/adi-253/Talkie/backend/internal/supabase"
 	"github.com/go-chi/chi/v5"
 )
 
@@ -14,11 +19,19 @@ import (
 // All handlers follow RESTful conventions and return JSON responses.
 type RoomHandler struct {
 	roomService *services.RoomService
+
+	// identityLimiter throttles username/avatar changes per participant (nil disables)
+	identityLimiter *ratelimit.TokenBucket
+
+	// roomsPage bounds the page size of the room list
+	roomsPage PageSize
 }
 
 // NewRoomHandler creates a new RoomHandler instance.
-func NewRoomHandler(roomService *services.RoomService) *RoomHandler {
-	return &RoomHandler{roomService: roomService}
+// identityLimiter rate limits username/avatar changes per participant; nil disables it.
+// roomsPage bounds how many rooms one list request returns.
+func NewRoomHandler(roomService *services.RoomService, identityLimiter *ratelimit.TokenBucket, roomsPage PageSize) *RoomHandler {
+	return &RoomHandler{roomService: roomService, identityLimiter: identityLimiter, roomsPage: roomsPage}
 }
 
 // CreateRoom handles POST /api/rooms
@@ -26,14 +39,32 @@ func NewRoomHandler(roomService *services.RoomService) *RoomHandler {
 func (h *RoomHandler) CreateRoom(w http.ResponseWriter, r *http.Request) {
 	var req models.CreateRoomRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			writeDecodeError(w, r, err)
+			return
+		}
 		// If no body, use default name
 		req.Name = ""
 	}
 
-	room, err := h.roomService.CreateRoom(req.Name)
+	if req.MessageTTLSeconds < 0 {
+		writeError(w, r, http.StatusBadRequest, "message_ttl_seconds must not be negative")
+		return
+	}
+
+	persist := req.Persist == nil || *req.Persist
+	room, err := h.roomService.CreateRoom(req.ID, req.Name, time.Duration(req.MessageTTLSeconds)*time.Second, persist, req.RequireSignatures)
 	if err != nil {
 		log.Printf("[Room] Failed to create room: %v", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		switch {
+		case errors.Is(err, services.ErrInvalidRoomSlug), errors.Is(err, services.ErrInvalidRoomID):
+			writeError(w, r, http.StatusBadRequest, err.Error())
+		case errors.Is(err, services.ErrRoomNameTaken), errors.Is(err, services.ErrRoomIDTaken), errors.Is(err, services.ErrReservedRoomName):
+			writeError(w, r, http.StatusConflict, err.Error())
+		default:
+			writeInternalError(w, r, err)
+		}
 		return
 	}
 
@@ -49,11 +80,17 @@ func (h *RoomHandler) CreateRoom(w http.ResponseWriter, r *http.Request) {
 // ListRooms handles GET /api/rooms
 // Returns all active rooms.
 func (h *RoomHandler) ListRooms(w http.ResponseWriter, r *http.Request) {
-	rooms, err := h.roomService.ListRooms()
+	limit, offset, ok := parsePage(w, r, h.roomsPage)
+	if !ok {
+		return
+	}
+
+	rooms, err := h.roomService.ListRooms(limit, offset)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeInternalError(w, r, err)
 		return
 	}
+	setNextLink(w, r, limit, offset, len(rooms))
 	writeJSON(w, http.StatusOK, rooms)
 }
 
@@ -62,14 +99,18 @@ func (h *RoomHandler) ListRooms(w http.ResponseWriter, r *http.Request) {
 func (h *RoomHandler) GetRoom(w http.ResponseWriter, r *http.Request) {
 	roomID := chi.URLParam(r, "id")
 	if roomID == "" {
-		http.Error(w, "room ID is required", http.StatusBadRequest)
+		writeError(w, r, http.StatusBadRequest, "room ID is required")
 		return
 	}
 
 	room, participants, err := h.roomService.GetRoom(roomID)
 	if err != nil {
 		log.Printf("[Room] Room %s not found: %v", roomID, err)
-		http.Error(w, "room not found", http.StatusNotFound)
+		if errors.Is(err, supabase.ErrUpstreamRateLimited) {
+			writeInternalError(w, r, err)
+			return
+		}
+		writeError(w, r, http.StatusNotFound, "room not found")
 		return
 	}
 
@@ -81,30 +122,77 @@ func (h *RoomHandler) GetRoom(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, response)
 }
 
+// ListAvatars handles GET /api/avatars
+// Returns the allowed avatar identifiers so the join UI can render valid choices.
+func (h *RoomHandler) ListAvatars(w http.ResponseWriter, r *http.Request) {
+	response := models.AvatarsResponse{
+		Avatars: h.roomService.Avatars(),
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// GetParticipant handles GET /api/participants/{participantId}
+// Returns the participant record so a reconnecting client can restore its session.
+func (h *RoomHandler) GetParticipant(w http.ResponseWriter, r *http.Request) {
+	participantID := chi.URLParam(r, "participantId")
+	if !validUUID(participantID) {
+		writeError(w, r, http.StatusBadRequest, "invalid participant ID")
+		return
+	}
+
+	participant, err := h.roomService.GetParticipant(participantID)
+	if err != nil {
+		if errors.Is(err, services.ErrParticipantNotFound) {
+			writeError(w, r, http.StatusNotFound, err.Error())
+			return
+		}
+		log.Printf("[Room] Failed to get participant %s: %v", participantID, err)
+		writeInternalError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, participant)
+}
+
 // JoinRoom handles POST /api/rooms/{id}/join
 // Adds a new participant to the room with their chosen username and avatar.
+// Rejoining with participant_id requires the participant's signing secret as a
+// Bearer token; the secret is only returned on a fresh join.
 func (h *RoomHandler) JoinRoom(w http.ResponseWriter, r *http.Request) {
 	roomID := chi.URLParam(r, "id")
 	if roomID == "" {
-		http.Error(w, "room ID is required", http.StatusBadRequest)
+		writeError(w, r, http.StatusBadRequest, "room ID is required")
 		return
 	}
 
 	var req models.JoinRoomRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "invalid request body", http.StatusBadRequest)
+		writeDecodeError(w, r, err)
 		return
 	}
 
 	if req.Username == "" {
-		http.Error(w, "username is required", http.StatusBadRequest)
+		writeError(w, r, http.StatusBadRequest, "username is required")
 		return
 	}
 
-	participant, room, participants, err := h.roomService.JoinRoom(roomID, req.Username, req.Avatar)
+	participant, room, participants, err := h.roomService.JoinRoom(roomID, req.Username, req.Avatar, req.Role, req.ParticipantID, participantSecret(r), clientIP(r))
 	if err != nil {
 		log.Printf("[Room] Failed to join room %s for user %s: %v", roomID, req.Username, err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		switch {
+		case errors.Is(err, services.ErrInvalidAvatar), errors.Is(err, services.ErrInvalidRole):
+			writeError(w, r, http.StatusBadRequest, err.Error())
+		case errors.Is(err, services.ErrRoomNotFound):
+			writeError(w, r, http.StatusNotFound, err.Error())
+		case errors.Is(err, services.ErrInvalidCredential):
+			writeError(w, r, http.StatusUnauthorized, err.Error())
+		case errors.Is(err, services.ErrRoomLocked):
+			writeError(w, r, http.StatusLocked, err.Error())
+		case errors.Is(err, services.ErrTooManyJoins):
+			writeError(w, r, http.StatusTooManyRequests, err.Error())
+		default:
+			writeInternalError(w, r, err)
+		}
 		return
 	}
 
@@ -115,33 +203,133 @@ func (h *RoomHandler) JoinRoom(w http.ResponseWriter, r *http.Request) {
 		Room:          *room,
 		Participants:  participants,
 	}
+	// A resumed session already holds its secret (it had to present it)
+	if participant.ID != req.ParticipantID {
+		response.SigningSecret = h.roomService.SigningSecret(participant.ID)
+	}
 
 	writeJSON(w, http.StatusOK, response)
 }
 
+// maxBulkLeaveEntries bounds how many rooms one bulk leave request may leave.
+const maxBulkLeaveEntries = 20
+
+// BulkLeave handles POST /api/leave
+// Leaves several rooms at once, e.g. from a beacon sent when a tab closes
+// (send it as a Blob of type text/plain so cross-origin beacons skip the CORS
+// preflight). Each entry carries its participant's credential and is handled
+// best-effort and independently; leaving is idempotent, so an entry for a
+// participant that already left succeeds.
+func (h *RoomHandler) BulkLeave(w http.ResponseWriter, r *http.Request) {
+	var entries []models.BulkLeaveEntry
+	if err := json.NewDecoder(r.Body).Decode(&entries); err != nil {
+		writeDecodeError(w, r, err)
+		return
+	}
+
+	if len(entries) > maxBulkLeaveEntries {
+		writeError(w, r, http.StatusBadRequest, fmt.Sprintf("at most %d entries are allowed", maxBulkLeaveEntries))
+		return
+	}
+
+	results := make([]models.BulkLeaveResult, len(entries))
+	for i, entry := range entries {
+		results[i] = models.BulkLeaveResult{RoomID: entry.RoomID, ParticipantID: entry.ParticipantID}
+
+		if entry.RoomID == "" || entry.ParticipantID == "" {
+			results[i].Error = "room ID and participant ID are required"
+			continue
+		}
+		if !services.ValidRoomID(entry.RoomID) {
+			results[i].Error = "invalid room ID"
+			continue
+		}
+		if !h.roomService.Authenticate(entry.ParticipantID, entry.Secret) {
+			results[i].Error = services.ErrInvalidCredential.Error()
+			continue
+		}
+		if err := h.roomService.LeaveRoom(entry.RoomID, entry.ParticipantID); err != nil {
+			log.Printf("[Room] Failed to leave room %s for participant %s: %v", entry.RoomID, entry.ParticipantID, err)
+			results[i].Error = "failed to leave room"
+			continue
+		}
+		results[i].OK = true
+	}
+
+	writeJSON(w, http.StatusOK, models.BulkLeaveResponse{Results: results})
+}
+
+// UpdateParticipant handles PATCH /api/rooms/{id}/participants/{participantId}
+// Updates a participant's own details (status, username, avatar); requires the
+// participant's credential. Username and avatar changes are rate limited per
+// participant and return 429 when too frequent.
+func (h *RoomHandler) UpdateParticipant(w http.ResponseWriter, r *http.Request) {
+	roomID := chi.URLParam(r, "id")
+	participantID := chi.URLParam(r, "participantId")
+	if roomID == "" || !validUUID(participantID) {
+		writeError(w, r, http.StatusBadRequest, "invalid participant ID")
+		return
+	}
+
+	var req models.UpdateParticipantRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeDecodeError(w, r, err)
+		return
+	}
+
+	if !requireParticipant(w, r, h.roomService, participantID) {
+		return
+	}
+
+	// Rapid renames spam participant_update broadcasts and enable impersonation
+	if req.ChangesIdentity() && h.identityLimiter != nil {
+		if !applyRateLimit(w, r, h.identityLimiter.Allow(roomID+"/"+participantID)) {
+			return
+		}
+	}
+
+	participant, err := h.roomService.UpdateParticipant(roomID, participantID, req)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidStatus),
+			errors.Is(err, services.ErrInvalidUsername),
+			errors.Is(err, services.ErrInvalidAvatar):
+			writeError(w, r, http.StatusBadRequest, err.Error())
+		case errors.Is(err, services.ErrParticipantNotFound):
+			writeError(w, r, http.StatusNotFound, err.Error())
+		default:
+			log.Printf("[Room] Failed to update participant %s: %v", participantID, err)
+			writeInternalError(w, r, err)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, participant)
+}
+
 // LeaveRoom handles POST /api/rooms/{id}/leave
 // Removes a participant from the room.
 func (h *RoomHandler) LeaveRoom(w http.ResponseWriter, r *http.Request) {
 	roomID := chi.URLParam(r, "id")
 	if roomID == "" {
-		http.Error(w, "room ID is required", http.StatusBadRequest)
+		writeError(w, r, http.StatusBadRequest, "room ID is required")
 		return
 	}
 
 	var req models.LeaveRoomRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "invalid request body", http.StatusBadRequest)
+		writeDecodeError(w, r, err)
 		return
 	}
 
 	if req.ParticipantID == "" {
-		http.Error(w, "participant ID is required", http.StatusBadRequest)
+		writeError(w, r, http.StatusBadRequest, "participant ID is required")
 		return
 	}
 
 	if err := h.roomService.LeaveRoom(roomID, req.ParticipantID); err != nil {
 		log.Printf("[Room] Failed to leave room %s for participant %s: %v", roomID, req.ParticipantID, err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeInternalError(w, r, err)
 		return
 	}
 
@@ -149,12 +337,277 @@ func (h *RoomHandler) LeaveRoom(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// LockRoom handles POST /api/rooms/{id}/lock
+// Prevents new participants from joining. Host only; requires the host's
+// signing secret as a Bearer token, like every host-only action.
+func (h *RoomHandler) LockRoom(w http.ResponseWriter, r *http.Request) {
+	h.setRoomLocked(w, r, true)
+}
+
+// UnlockRoom handles POST /api/rooms/{id}/unlock
+// Allows new participants to join again. Host only.
+func (h *RoomHandler) UnlockRoom(w http.ResponseWriter, r *http.Request) {
+	h.setRoomLocked(w, r, false)
+}
+
+// setRoomLocked is the shared implementation of LockRoom and UnlockRoom.
+func (h *RoomHandler) setRoomLocked(w http.ResponseWriter, r *http.Request, locked bool) {
+	roomID := chi.URLParam(r, "id")
+	if roomID == "" {
+		writeError(w, r, http.StatusBadRequest, "room ID is required")
+		return
+	}
+
+	var req models.LockRoomRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeDecodeError(w, r, err)
+		return
+	}
+
+	if req.ParticipantID == "" {
+		writeError(w, r, http.StatusBadRequest, "participant ID is required")
+		return
+	}
+	if !requireParticipant(w, r, h.roomService, req.ParticipantID) {
+		return
+	}
+
+	room, err := h.roomService.SetRoomLocked(roomID, req.ParticipantID, locked)
+	if err != nil {
+		log.Printf("[Room] Failed to set locked=%v on room %s by %s: %v", locked, roomID, req.ParticipantID, err)
+		switch {
+		case errors.Is(err, services.ErrRoomNotFound):
+			writeError(w, r, http.StatusNotFound, err.Error())
+		case errors.Is(err, services.ErrNotHost):
+			writeError(w, r, http.StatusForbidden, err.Error())
+		default:
+			writeInternalError(w, r, err)
+		}
+		return
+	}
+
+	log.Printf("[Room] Room %s locked=%v by host %s", roomID, locked, req.ParticipantID)
+	writeJSON(w, http.StatusOK, room)
+}
+
+// KickInactive handles POST /api/rooms/{id}/kick-inactive
+// Removes participants idle for longer than idle_seconds. Only the room host may do this.
+func (h *RoomHandler) KickInactive(w http.ResponseWriter, r *http.Request) {
+	roomID := chi.URLParam(r, "id")
+	if roomID == "" {
+		writeError(w, r, http.StatusBadRequest, "room ID is required")
+		return
+	}
+
+	var req models.KickInactiveRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeDecodeError(w, r, err)
+		return
+	}
+
+	if req.ParticipantID == "" {
+		writeError(w, r, http.StatusBadRequest, "participant ID is required")
+		return
+	}
+	if req.IdleSeconds <= 0 {
+		writeError(w, r, http.StatusBadRequest, "idle_seconds must be positive")
+		return
+	}
+	if !requireParticipant(w, r, h.roomService, req.ParticipantID) {
+		return
+	}
+
+	removed, err := h.roomService.KickInactive(roomID, req.ParticipantID, time.Duration(req.IdleSeconds)*time.Second)
+	if err != nil {
+		log.Printf("[Room] Failed to kick inactive participants from room %s by %s: %v", roomID, req.ParticipantID, err)
+		switch {
+		case errors.Is(err, services.ErrRoomNotFound):
+			writeError(w, r, http.StatusNotFound, err.Error())
+		case errors.Is(err, services.ErrNotHost):
+			writeError(w, r, http.StatusForbidden, err.Error())
+		default:
+			writeInternalError(w, r, err)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, models.KickInactiveResponse{Removed: removed})
+}
+
+// RotateKey handles POST /api/rooms/{id}/rotate-key
+// Moves the room to the next key epoch. Only the room host may do this.
+func (h *RoomHandler) RotateKey(w http.ResponseWriter, r *http.Request) {
+	roomID := chi.URLParam(r, "id")
+	if roomID == "" {
+		writeError(w, r, http.StatusBadRequest, "room ID is required")
+		return
+	}
+
+	var req models.RotateKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeDecodeError(w, r, err)
+		return
+	}
+
+	if req.ParticipantID == "" {
+		writeError(w, r, http.StatusBadRequest, "participant ID is required")
+		return
+	}
+	if !requireParticipant(w, r, h.roomService, req.ParticipantID) {
+		return
+	}
+
+	room, err := h.roomService.RotateRoomKey(roomID, req.ParticipantID)
+	if err != nil {
+		log.Printf("[Room] Failed to rotate key of room %s by %s: %v", roomID, req.ParticipantID, err)
+		switch {
+		case errors.Is(err, services.ErrRoomNotFound):
+			writeError(w, r, http.StatusNotFound, err.Error())
+		case errors.Is(err, services.ErrNotHost):
+			writeError(w, r, http.StatusForbidden, err.Error())
+		case errors.Is(err, services.ErrKeyRotationConflict):
+			writeError(w, r, http.StatusConflict, err.Error())
+		default:
+			writeInternalError(w, r, err)
+		}
+		return
+	}
+
+	log.Printf("[Room] Room %s key rotated to version %d by host %s", roomID, room.KeyVersion, req.ParticipantID)
+	writeJSON(w, http.StatusOK, room)
+}
+
+// CloseRoom handles POST /api/rooms/{id}/close
+// Deletes the room and its messages for everyone. Only the room host may do
+// this; the room is fully torn down when the response is sent.
+func (h *RoomHandler) CloseRoom(w http.ResponseWriter, r *http.Request) {
+	roomID := chi.URLParam(r, "id")
+	if roomID == "" {
+		writeError(w, r, http.StatusBadRequest, "room ID is required")
+		return
+	}
+
+	var req models.CloseRoomRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeDecodeError(w, r, err)
+		return
+	}
+
+	if req.ParticipantID == "" {
+		writeError(w, r, http.StatusBadRequest, "participant ID is required")
+		return
+	}
+	if !requireParticipant(w, r, h.roomService, req.ParticipantID) {
+		return
+	}
+
+	if err := h.roomService.CloseRoom(roomID, req.ParticipantID); err != nil {
+		log.Printf("[Room] Failed to close room %s by %s: %v", roomID, req.ParticipantID, err)
+		switch {
+		case errors.Is(err, services.ErrRoomNotFound):
+			writeError(w, r, http.StatusNotFound, err.Error())
+		case errors.Is(err, services.ErrNotHost):
+			writeError(w, r, http.StatusForbidden, err.Error())
+		default:
+			writeInternalError(w, r, err)
+		}
+		return
+	}
+
+	log.Printf("[Room] Room %s closed by host %s", roomID, req.ParticipantID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// TransferHost handles POST /api/rooms/{id}/transfer-host
+// Hands the host role to another participant. Only the room host may do this.
+func (h *RoomHandler) TransferHost(w http.ResponseWriter, r *http.Request) {
+	roomID := chi.URLParam(r, "id")
+	if roomID == "" {
+		writeError(w, r, http.StatusBadRequest, "room ID is required")
+		return
+	}
+
+	var req models.TransferHostRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeDecodeError(w, r, err)
+		return
+	}
+
+	if req.ParticipantID == "" || !validUUID(req.NewHostID) {
+		writeError(w, r, http.StatusBadRequest, "participant ID and a valid new host ID are required")
+		return
+	}
+	if !requireParticipant(w, r, h.roomService, req.ParticipantID) {
+		return
+	}
+
+	room, err := h.roomService.TransferHost(roomID, req.ParticipantID, req.NewHostID)
+	if err != nil {
+		log.Printf("[Room] Failed to transfer host of room %s from %s: %v", roomID, req.ParticipantID, err)
+		switch {
+		case errors.Is(err, services.ErrRoomNotFound):
+			writeError(w, r, http.StatusNotFound, err.Error())
+		case errors.Is(err, services.ErrNotHost):
+			writeError(w, r, http.StatusForbidden, err.Error())
+		case errors.Is(err, services.ErrInvalidHostTarget):
+			writeError(w, r, http.StatusBadRequest, err.Error())
+		default:
+			writeInternalError(w, r, err)
+		}
+		return
+	}
+
+	log.Printf("[Room] Room %s host transferred from %s to %s", roomID, req.ParticipantID, req.NewHostID)
+	writeJSON(w, http.StatusOK, room)
+}
+
+// RegenerateRoomID handles POST /api/rooms/{id}/regenerate-id
+// Moves the room to a fresh ID. Only the room host may do this.
+func (h *RoomHandler) RegenerateRoomID(w http.ResponseWriter, r *http.Request) {
+	roomID := chi.URLParam(r, "id")
+	if roomID == "" {
+		writeError(w, r, http.StatusBadRequest, "room ID is required")
+		return
+	}
+
+	var req models.RegenerateRoomIDRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeDecodeError(w, r, err)
+		return
+	}
+
+	if req.ParticipantID == "" {
+		writeError(w, r, http.StatusBadRequest, "participant ID is required")
+		return
+	}
+	if !requireParticipant(w, r, h.roomService, req.ParticipantID) {
+		return
+	}
+
+	room, err := h.roomService.RegenerateRoomID(roomID, req.ParticipantID)
+	if err != nil {
+		log.Printf("[Room] Failed to regenerate ID of room %s by %s: %v", roomID, req.ParticipantID, err)
+		switch {
+		case errors.Is(err, services.ErrRoomNotFound):
+			writeError(w, r, http.StatusNotFound, err.Error())
+		case errors.Is(err, services.ErrNotHost):
+			writeError(w, r, http.StatusForbidden, err.Error())
+		default:
+			writeInternalError(w, r, err)
+		}
+		return
+	}
+
+	log.Printf("[Room] Room %s moved to %s by host %s", roomID, room.ID, req.ParticipantID)
+	writeJSON(w, http.StatusOK, models.RegenerateRoomIDResponse{RoomID: room.ID})
+}
+
 // Heartbeat handles POST /api/rooms/{id}/heartbeat
 // Updates the room and participant's activity timestamp to prevent auto-deletion.
 func (h *RoomHandler) Heartbeat(w http.ResponseWriter, r *http.Request) {
 	roomID := chi.URLParam(r, "id")
 	if roomID == "" {
-		http.Error(w, "room ID is required", http.StatusBadRequest)
+		writeError(w, r, http.StatusBadRequest, "room ID is required")
 		return
 	}
 
@@ -166,7 +619,7 @@ func (h *RoomHandler) Heartbeat(w http.ResponseWriter, r *http.Request) {
 
 	if err := h.roomService.UpdateHeartbeat(roomID, req.ParticipantID); err != nil {
 		log.Printf("[Room] Heartbeat failed for room %s participant %s: %v", roomID, req.ParticipantID, err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeInternalError(w, r, err)
 		return
 	}
 
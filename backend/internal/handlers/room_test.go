@@ -0,0 +1,557 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adi-253/Talkie/backend/internal/models"
+	"github.com/adi-253/Talkie/backend/internal/ratelimit"
+	"github.com/adi-253/Talkie/backend/internal/services"
+	"github.com/go-chi/chi/v5"
+)
+
+func TestLockRoomRequiresHostCredential(t *testing.T) {
+	env := newTestEnv(t)
+	env.seedRoom("room1")
+	host, hostSecret := env.join(t, "room1", "host", "")
+	guest, guestSecret := env.join(t, "room1", "guest", "")
+	h := NewRoomHandler(env.rooms, nil, PageSize{Default: 50, Max: 200})
+
+	tests := []struct {
+		name    string
+		body    models.LockRoomRequest
+		headers []string
+		want    int
+	}{
+		{"spoofed host ID without credential", models.LockRoomRequest{ParticipantID: host.ID}, nil, http.StatusUnauthorized},
+		{"host ID with another participant's secret", models.LockRoomRequest{ParticipantID: host.ID}, bearer(guestSecret), http.StatusUnauthorized},
+		{"authenticated non-host", models.LockRoomRequest{ParticipantID: guest.ID}, bearer(guestSecret), http.StatusForbidden},
+		{"authenticated host", models.LockRoomRequest{ParticipantID: host.ID}, bearer(hostSecret), http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(t, http.MethodPost, "/api/rooms/{id}/lock", h.LockRoom, "/api/rooms/room1/lock", tt.body, tt.headers...)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+}
+
+func TestJoinLockedRoomReturns423(t *testing.T) {
+	env := newTestEnv(t)
+	env.seedRoom("room1")
+	host, hostSecret := env.join(t, "room1", "host", "")
+	h := NewRoomHandler(env.rooms, nil, PageSize{Default: 50, Max: 200})
+
+	rec := serve(t, http.MethodPost, "/api/rooms/{id}/lock", h.LockRoom, "/api/rooms/room1/lock",
+		models.LockRoomRequest{ParticipantID: host.ID}, bearer(hostSecret)...)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("lock status = %d: %s", rec.Code, rec.Body)
+	}
+
+	rec = serve(t, http.MethodPost, "/api/rooms/{id}/join", h.JoinRoom, "/api/rooms/room1/join", models.JoinRoomRequest{Username: "late"})
+	if rec.Code != http.StatusLocked {
+		t.Errorf("join status = %d, want 423: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestHostActionsRejectSpoofedParticipantID(t *testing.T) {
+	env := newTestEnv(t)
+	env.seedRoom("room1")
+	host, _ := env.join(t, "room1", "host", "")
+	guest, guestSecret := env.join(t, "room1", "guest", "")
+	h := NewRoomHandler(env.rooms, nil, PageSize{Default: 50, Max: 200})
+
+	actions := []struct {
+		path    string
+		handler http.HandlerFunc
+		body    interface{}
+	}{
+		{"lock", h.LockRoom, models.LockRoomRequest{ParticipantID: host.ID}},
+		{"unlock", h.UnlockRoom, models.LockRoomRequest{ParticipantID: host.ID}},
+		{"transfer-host", h.TransferHost, models.TransferHostRequest{ParticipantID: host.ID, NewHostID: guest.ID}},
+		{"kick-inactive", h.KickInactive, models.KickInactiveRequest{ParticipantID: host.ID, IdleSeconds: 60}},
+		{"close", h.CloseRoom, models.CloseRoomRequest{ParticipantID: host.ID}},
+		{"rotate-key", h.RotateKey, models.RotateKeyRequest{ParticipantID: host.ID}},
+		{"regenerate-id", h.RegenerateRoomID, models.RegenerateRoomIDRequest{ParticipantID: host.ID}},
+	}
+	for _, a := range actions {
+		t.Run(a.path, func(t *testing.T) {
+			for _, headers := range [][]string{nil, bearer(guestSecret), bearer("not-base64!")} {
+				rec := serve(t, http.MethodPost, "/api/rooms/{id}/"+a.path, a.handler, "/api/rooms/room1/"+a.path, a.body, headers...)
+				if rec.Code != http.StatusUnauthorized {
+					t.Errorf("headers %v: status = %d, want 401: %s", headers, rec.Code, rec.Body)
+				}
+			}
+		})
+	}
+
+	if room, ok := env.srv.Room("room1"); !ok || room.HostParticipantID != host.ID || room.Locked {
+		t.Errorf("room changed by unauthenticated requests: %+v (exists: %v)", room, ok)
+	}
+}
+
+func TestGetParticipant(t *testing.T) {
+	env := newTestEnv(t)
+	env.seedRoom("room1")
+	alice, _ := env.join(t, "room1", "alice", "")
+	gone, _ := env.join(t, "room1", "gone", "")
+	env.srv.DeleteParticipant(gone.ID)
+	h := NewRoomHandler(env.rooms, nil, PageSize{Default: 50, Max: 200})
+
+	rec := serve(t, http.MethodGet, "/api/participants/{participantId}", h.GetParticipant, "/api/participants/"+alice.ID, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("existing participant: status = %d: %s", rec.Code, rec.Body)
+	}
+	var got models.Participant
+	decode(t, rec, &got)
+	if got.ID != alice.ID || got.RoomID != "room1" || got.Username != "alice" || got.Avatar != alice.Avatar {
+		t.Errorf("participant = %+v, want %+v", got, alice)
+	}
+
+	tests := []struct {
+		name string
+		id   string
+		want int
+	}{
+		{"cleaned up", gone.ID, http.StatusNotFound},
+		{"never existed", newID(), http.StatusNotFound},
+		{"malformed ID", "not-a-uuid", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(t, http.MethodGet, "/api/participants/{participantId}", h.GetParticipant, "/api/participants/"+tt.id, nil)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+}
+
+func TestListAvatarsMatchesJoinValidation(t *testing.T) {
+	env := newTestEnv(t)
+	env.seedRoom("room1")
+	h := NewRoomHandler(env.rooms, nil, PageSize{Default: 50, Max: 200})
+
+	rec := serve(t, http.MethodGet, "/api/avatars", h.ListAvatars, "/api/avatars", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	var resp models.AvatarsResponse
+	decode(t, rec, &resp)
+	if !slices.Equal(resp.Avatars, []string{"avatar1", "avatar2"}) {
+		t.Fatalf("avatars = %v, want the configured [avatar1 avatar2]", resp.Avatars)
+	}
+
+	for _, avatar := range resp.Avatars {
+		rec := serve(t, http.MethodPost, "/api/rooms/{id}/join", h.JoinRoom, "/api/rooms/room1/join",
+			models.JoinRoomRequest{Username: "user-" + avatar, Avatar: avatar})
+		if rec.Code != http.StatusOK {
+			t.Errorf("join with listed avatar %q: status = %d: %s", avatar, rec.Code, rec.Body)
+		}
+	}
+
+	rec = serve(t, http.MethodPost, "/api/rooms/{id}/join", h.JoinRoom, "/api/rooms/room1/join",
+		models.JoinRoomRequest{Username: "rogue", Avatar: "avatar99"})
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("join with unlisted avatar: status = %d, want 400: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestKickInactive(t *testing.T) {
+	env := newTestEnv(t)
+	env.seedRoom("room1")
+	host, hostSecret := env.join(t, "room1", "host", "")
+	active, activeSecret := env.join(t, "room1", "active", "")
+	longAgo := time.Now().UTC().Add(-30 * time.Minute)
+	idle := models.Participant{ID: newID(), RoomID: "room1", Username: "idle", JoinedAt: longAgo, LastActiveAt: longAgo, Role: models.RoleParticipant}
+	env.srv.AddParticipant(idle)
+	h := NewRoomHandler(env.rooms, nil, PageSize{Default: 50, Max: 200})
+
+	kick := func(participantID string, idleSeconds int, headers ...string) *httptest.ResponseRecorder {
+		return serve(t, http.MethodPost, "/api/rooms/{id}/kick-inactive", h.KickInactive, "/api/rooms/room1/kick-inactive",
+			models.KickInactiveRequest{ParticipantID: participantID, IdleSeconds: idleSeconds}, headers...)
+	}
+
+	if rec := kick(active.ID, 600, bearer(activeSecret)...); rec.Code != http.StatusForbidden {
+		t.Errorf("non-host: status = %d, want 403", rec.Code)
+	}
+	if rec := kick(host.ID, 600); rec.Code != http.StatusUnauthorized {
+		t.Errorf("host without credential: status = %d, want 401", rec.Code)
+	}
+	if rec := kick(host.ID, 0, bearer(hostSecret)...); rec.Code != http.StatusBadRequest {
+		t.Errorf("zero idle_seconds: status = %d, want 400", rec.Code)
+	}
+
+	rec := kick(host.ID, 600, bearer(hostSecret)...)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("host: status = %d: %s", rec.Code, rec.Body)
+	}
+	var resp models.KickInactiveResponse
+	decode(t, rec, &resp)
+	if resp.Removed != 1 {
+		t.Errorf("removed = %d, want 1", resp.Removed)
+	}
+	if _, ok := env.srv.Participant(idle.ID); ok {
+		t.Error("idle participant was not removed")
+	}
+	for _, p := range []models.Participant{host, active} {
+		if _, ok := env.srv.Participant(p.ID); !ok {
+			t.Errorf("%s was removed", p.Username)
+		}
+	}
+	var leaves []string
+	for _, b := range env.srv.BroadcastsFor("participant") {
+		var payload struct {
+			Action      string             `json:"action"`
+			Participant models.Participant `json:"participant"`
+		}
+		if err := json.Unmarshal(b.Payload, &payload); err != nil {
+			t.Fatal(err)
+		}
+		if payload.Action == "leave" {
+			leaves = append(leaves, payload.Participant.ID)
+		}
+	}
+	if !slices.Equal(leaves, []string{idle.ID}) {
+		t.Errorf("leave broadcasts for %v, want only the idle participant", leaves)
+	}
+}
+
+func TestCreateRoomWithRequestedID(t *testing.T) {
+	env := newTestEnv(t)
+	env.seedRoom("taken")
+	h := NewRoomHandler(env.rooms, nil, PageSize{Default: 50, Max: 200})
+
+	tests := []struct {
+		name string
+		id   string
+		want int
+	}{
+		{"valid custom ID", "weekly-standup", http.StatusCreated},
+		{"colliding ID", "taken", http.StatusConflict},
+		{"uppercase", "Weekly", http.StatusBadRequest},
+		{"double hyphen", "weekly--standup", http.StatusBadRequest},
+		{"too long", strings.Repeat("a", 49), http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(t, http.MethodPost, "/api/rooms", h.CreateRoom, "/api/rooms", models.CreateRoomRequest{ID: tt.id, Name: "Standup"})
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+			if tt.want != http.StatusCreated {
+				return
+			}
+			var resp models.CreateRoomResponse
+			decode(t, rec, &resp)
+			if resp.RoomID != tt.id {
+				t.Errorf("room_id = %q, want %q", resp.RoomID, tt.id)
+			}
+		})
+	}
+	if got := len(env.srv.Rooms()); got != 2 {
+		t.Errorf("stored rooms = %d, want 2", got)
+	}
+}
+
+func TestBulkLeave(t *testing.T) {
+	env := newTestEnv(t)
+	env.seedRoom("room1")
+	env.seedRoom("room2")
+	alice1, alice1Secret := env.join(t, "room1", "alice", "")
+	bob, _ := env.join(t, "room1", "bob", "")
+	alice2, alice2Secret := env.join(t, "room2", "alice", "")
+	gone := newID()
+	h := NewRoomHandler(env.rooms, nil, PageSize{Default: 50, Max: 200})
+
+	entries := []models.BulkLeaveEntry{
+		{RoomID: "room1", ParticipantID: alice1.ID, Secret: alice1Secret},
+		{RoomID: "room2", ParticipantID: alice2.ID, Secret: alice2Secret},
+		{RoomID: "room1", ParticipantID: gone, Secret: env.rooms.SigningSecret(gone)},
+		{RoomID: "Not A Room", ParticipantID: alice1.ID, Secret: alice1Secret},
+		{RoomID: "room1"},
+		{RoomID: "room1", ParticipantID: bob.ID},
+		{RoomID: "room1", ParticipantID: bob.ID, Secret: alice1Secret},
+	}
+	// Beacons send the JSON as text/plain
+	rec := serve(t, http.MethodPost, "/api/leave", h.BulkLeave, "/api/leave", entries, "Content-Type", "text/plain;charset=UTF-8")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	var resp models.BulkLeaveResponse
+	decode(t, rec, &resp)
+	if len(resp.Results) != len(entries) {
+		t.Fatalf("results = %d, want %d", len(resp.Results), len(entries))
+	}
+	for i, want := range []bool{true, true, true, false, false, false, false} {
+		res := resp.Results[i]
+		if res.OK != want || (res.Error == "") != want {
+			t.Errorf("result %d = %+v, want ok=%v", i, res, want)
+		}
+		if res.RoomID != entries[i].RoomID || res.ParticipantID != entries[i].ParticipantID {
+			t.Errorf("result %d is for %s/%s, want %s/%s", i, res.RoomID, res.ParticipantID, entries[i].RoomID, entries[i].ParticipantID)
+		}
+	}
+
+	if _, ok := env.srv.Participant(alice1.ID); ok {
+		t.Error("alice is still in room1")
+	}
+	if _, ok := env.srv.Participant(bob.ID); !ok {
+		t.Error("bob was removed without a valid credential")
+	}
+	if _, ok := env.srv.Room("room1"); !ok {
+		t.Error("room1 was deleted while bob is still in it")
+	}
+	if _, ok := env.srv.Room("room2"); ok {
+		t.Error("room2 was not deleted after its last participant left")
+	}
+
+	// Leaving again is idempotent
+	rec = serve(t, http.MethodPost, "/api/leave", h.BulkLeave, "/api/leave", entries[:1])
+	decode(t, rec, &resp)
+	if len(resp.Results) != 1 || !resp.Results[0].OK {
+		t.Errorf("repeated leave = %+v, want ok", resp.Results)
+	}
+
+	tooMany := make([]models.BulkLeaveEntry, maxBulkLeaveEntries+1)
+	if rec := serve(t, http.MethodPost, "/api/leave", h.BulkLeave, "/api/leave", tooMany); rec.Code != http.StatusBadRequest {
+		t.Errorf("oversized batch: status = %d, want 400", rec.Code)
+	}
+}
+
+func TestIdentityChangesAreRateLimited(t *testing.T) {
+	const interval = 100 * time.Millisecond
+	env := newTestEnv(t)
+	env.seedRoom("room1")
+	alice, aliceSecret := env.join(t, "room1", "alice", "")
+	bob, bobSecret := env.join(t, "room1", "bob", "")
+	h := NewRoomHandler(env.rooms, ratelimit.NewTokenBucket(1, interval), PageSize{Default: 50, Max: 200})
+
+	update := func(p models.Participant, req models.UpdateParticipantRequest, headers ...string) int {
+		return serve(t, http.MethodPatch, "/api/rooms/{id}/participants/{participantId}", h.UpdateParticipant,
+			"/api/rooms/room1/participants/"+p.ID, req, headers...).Code
+	}
+	name := func(s string) models.UpdateParticipantRequest { return models.UpdateParticipantRequest{Username: &s} }
+
+	// Unauthenticated renames are refused before they can spend the rate limit
+	if code := update(alice, name("mallory")); code != http.StatusUnauthorized {
+		t.Errorf("rename without credential: status = %d, want 401", code)
+	}
+	if code := update(alice, name("mallory"), bearer(bobSecret)...); code != http.StatusUnauthorized {
+		t.Errorf("rename with another participant's secret: status = %d, want 401", code)
+	}
+
+	if code := update(alice, name("alice2"), bearer(aliceSecret)...); code != http.StatusOK {
+		t.Fatalf("first rename: status = %d, want 200", code)
+	}
+	if code := update(alice, name("bob"), bearer(aliceSecret)...); code != http.StatusTooManyRequests {
+		t.Errorf("rapid rename: status = %d, want 429", code)
+	}
+	status := "away"
+	if code := update(alice, models.UpdateParticipantRequest{Status: &status}, bearer(aliceSecret)...); code != http.StatusOK {
+		t.Errorf("status change: status = %d, want 200 (not an identity change)", code)
+	}
+	if code := update(bob, name("bobby"), bearer(bobSecret)...); code != http.StatusOK {
+		t.Errorf("another participant's rename: status = %d, want 200", code)
+	}
+
+	time.Sleep(interval + 20*time.Millisecond)
+	if code := update(alice, name("alice3"), bearer(aliceSecret)...); code != http.StatusOK {
+		t.Errorf("spaced rename: status = %d, want 200", code)
+	}
+	if stored, _ := env.srv.Participant(alice.ID); stored.Username != "alice3" {
+		t.Errorf("stored username = %q, want alice3", stored.Username)
+	}
+}
+
+func TestRejoinRequiresSessionSecret(t *testing.T) {
+	env := newTestEnv(t)
+	env.seedRoom("room1")
+	h := NewRoomHandler(env.rooms, nil, PageSize{Default: 50, Max: 200})
+	join := func(req models.JoinRoomRequest, headers ...string) *httptest.ResponseRecorder {
+		return serve(t, http.MethodPost, "/api/rooms/{id}/join", h.JoinRoom, "/api/rooms/room1/join", req, headers...)
+	}
+
+	rec := join(models.JoinRoomRequest{Username: "alice"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("fresh join: status = %d: %s", rec.Code, rec.Body)
+	}
+	var fresh models.JoinRoomResponse
+	decode(t, rec, &fresh)
+	if fresh.SigningSecret == "" || !env.rooms.Authenticate(fresh.ParticipantID, fresh.SigningSecret) {
+		t.Fatalf("fresh join secret = %q, want the participant's credential", fresh.SigningSecret)
+	}
+	_, otherSecret := env.join(t, "room1", "mallory", "")
+
+	rejoin := models.JoinRoomRequest{Username: "alice", ParticipantID: fresh.ParticipantID}
+	if rec := join(rejoin); rec.Code != http.StatusUnauthorized {
+		t.Errorf("rejoin without secret: status = %d, want 401", rec.Code)
+	}
+	if rec := join(rejoin, bearer(otherSecret)...); rec.Code != http.StatusUnauthorized {
+		t.Errorf("rejoin with another participant's secret: status = %d, want 401", rec.Code)
+	}
+
+	rec = join(rejoin, bearer(fresh.SigningSecret)...)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("rejoin with secret: status = %d: %s", rec.Code, rec.Body)
+	}
+	var resumed models.JoinRoomResponse
+	decode(t, rec, &resumed)
+	if resumed.ParticipantID != fresh.ParticipantID {
+		t.Errorf("resumed participant = %s, want %s", resumed.ParticipantID, fresh.ParticipantID)
+	}
+	if resumed.SigningSecret != "" {
+		t.Error("rejoin response repeats the signing secret")
+	}
+}
+
+func TestJoinOverPerIPCapReturns429(t *testing.T) {
+	env := newTestEnv(t)
+	env.seedRoom("room1")
+	rooms := services.NewRoomService(env.db, env.messages, nil, services.RoomSettings{MaxJoinsPerIP: 1}, services.RealClock{}, nil)
+	h := NewRoomHandler(rooms, nil, PageSize{Default: 50, Max: 200})
+
+	join := func(remoteAddr string) int {
+		req := httptest.NewRequest(http.MethodPost, "/api/rooms/room1/join", strings.NewReader(`{"username":"alice"}`))
+		req.RemoteAddr = remoteAddr
+		r := chi.NewRouter()
+		r.Post("/api/rooms/{id}/join", h.JoinRoom)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := join("203.0.113.1:5000"); code != http.StatusOK {
+		t.Fatalf("first join: status = %d, want 200", code)
+	}
+	if code := join("203.0.113.1:5001"); code != http.StatusTooManyRequests {
+		t.Errorf("second join from the same IP: status = %d, want 429", code)
+	}
+	if code := join("198.51.100.7:5000"); code != http.StatusOK {
+		t.Errorf("join from another IP: status = %d, want 200", code)
+	}
+}
+
+func TestListRoomsNeverExceedsMaxPageSize(t *testing.T) {
+	env := newTestEnv(t)
+	for i := 0; i < 7; i++ {
+		env.seedRoom(newID())
+	}
+	h := NewRoomHandler(env.rooms, nil, PageSize{Default: 3, Max: 5})
+
+	list := func(query string) ([]models.Room, *httptest.ResponseRecorder) {
+		t.Helper()
+		rec := serve(t, http.MethodGet, "/api/rooms", h.ListRooms, "/api/rooms"+query, nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET %s: status = %d: %s", query, rec.Code, rec.Body)
+		}
+		var rooms []models.Room
+		decode(t, rec, &rooms)
+		return rooms, rec
+	}
+
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{"", 3},
+		{"?limit=2", 2},
+		{"?limit=5", 5},
+		{"?limit=1000", 5},
+		{"?limit=1000&offset=5", 2},
+	}
+	for _, tt := range tests {
+		t.Run("query "+tt.query, func(t *testing.T) {
+			if rooms, _ := list(tt.query); len(rooms) != tt.want {
+				t.Errorf("got %d rooms, want %d", len(rooms), tt.want)
+			}
+		})
+	}
+
+	// Following the Link header pages through every room exactly once.
+	seen := map[string]bool{}
+	next := "?limit=1000"
+	for next != "" {
+		rooms, rec := list(next)
+		for _, room := range rooms {
+			if seen[room.ID] {
+				t.Errorf("room %s returned twice", room.ID)
+			}
+			seen[room.ID] = true
+		}
+		next = ""
+		if link := rec.Header().Get("Link"); link != "" {
+			next = link[strings.Index(link, "?"):strings.Index(link, ">")]
+		}
+	}
+	if len(seen) != 7 {
+		t.Errorf("paged through %d rooms, want 7", len(seen))
+	}
+
+	for _, query := range []string{"?limit=0", "?limit=-1", "?offset=-1", "?limit=ten"} {
+		rec := serve(t, http.MethodGet, "/api/rooms", h.ListRooms, "/api/rooms"+query, nil)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("GET %s: status = %d, want 400", query, rec.Code)
+		}
+	}
+}
+
+func TestTransferHostStatus(t *testing.T) {
+	env := newTestEnv(t)
+	env.seedRoom("room1")
+	host, hostSecret := env.join(t, "room1", "host", "")
+	guest, guestSecret := env.join(t, "room1", "guest", "")
+	h := NewRoomHandler(env.rooms, nil, PageSize{Default: 50, Max: 200})
+
+	tests := []struct {
+		name    string
+		body    models.TransferHostRequest
+		headers []string
+		want    int
+	}{
+		{"host ID without credential", models.TransferHostRequest{ParticipantID: host.ID, NewHostID: guest.ID}, nil, http.StatusUnauthorized},
+		{"authenticated non-host", models.TransferHostRequest{ParticipantID: guest.ID, NewHostID: guest.ID}, bearer(guestSecret), http.StatusForbidden},
+		{"target not in the room", models.TransferHostRequest{ParticipantID: host.ID, NewHostID: newID()}, bearer(hostSecret), http.StatusBadRequest},
+		{"invalid target ID", models.TransferHostRequest{ParticipantID: host.ID, NewHostID: "guest"}, bearer(hostSecret), http.StatusBadRequest},
+		{"authenticated host", models.TransferHostRequest{ParticipantID: host.ID, NewHostID: guest.ID}, bearer(hostSecret), http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(t, http.MethodPost, "/api/rooms/{id}/transfer-host", h.TransferHost, "/api/rooms/room1/transfer-host", tt.body, tt.headers...)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+}
+
+func TestCreateReservedRoomReturns409(t *testing.T) {
+	env := newTestEnv(t)
+	rooms := services.NewRoomService(env.db, env.messages, nil, services.RoomSettings{ReservedNames: []string{"admin"}}, services.RealClock{}, nil)
+	h := NewRoomHandler(rooms, nil, PageSize{Default: 50, Max: 200})
+
+	tests := []struct {
+		name string
+		body models.CreateRoomRequest
+		want int
+	}{
+		{"reserved name", models.CreateRoomRequest{Name: "Admin"}, http.StatusConflict},
+		{"reserved ID", models.CreateRoomRequest{ID: "admin", Name: "Chat"}, http.StatusConflict},
+		{"ordinary room", models.CreateRoomRequest{ID: "team-admin", Name: "Chat"}, http.StatusCreated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(t, http.MethodPost, "/api/rooms", h.CreateRoom, "/api/rooms", tt.body)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+}
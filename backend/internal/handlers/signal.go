@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/adi-253/Talkie/backend/internal/models"
+	"github.com/adi-253/Talkie/backend/internal/services"
+	"github.com/go-chi/chi/v5"
+)
+
+// SignalHandler contains HTTP handlers for participant signals
+// such as raised hands and reactions.
+type SignalHandler struct {
+	signalService *services.SignalService
+}
+
+// NewSignalHandler creates a new SignalHandler instance.
+func NewSignalHandler(signalService *services.SignalService) *SignalHandler {
+	return &SignalHandler{signalService: signalService}
+}
+
+// SendSignal handles POST /api/rooms/{id}/signal
+// Records a participant's signal and broadcasts it to the room as a signal event.
+func (h *SignalHandler) SendSignal(w http.ResponseWriter, r *http.Request) {
+	roomID := chi.URLParam(r, "id")
+	if roomID == "" {
+		writeError(w, r, http.StatusBadRequest, "room ID is required")
+		return
+	}
+
+	var req models.SignalRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeDecodeError(w, r, err)
+		return
+	}
+
+	if req.ParticipantID == "" {
+		writeError(w, r, http.StatusBadRequest, "participant ID is required")
+		return
+	}
+
+	signal, err := h.signalService.SendSignal(roomID, req.ParticipantID, req.Signal)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidSignal):
+			writeError(w, r, http.StatusBadRequest, err.Error())
+		case errors.Is(err, services.ErrParticipantNotFound):
+			writeError(w, r, http.StatusNotFound, err.Error())
+		default:
+			log.Printf("[Signal] Failed to send signal in room %s: %v", roomID, err)
+			writeInternalError(w, r, err)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, signal)
+}
+
+// GetSignals handles GET /api/rooms/{id}/signal
+// Returns the active signals in the room, for polling clients.
+func (h *SignalHandler) GetSignals(w http.ResponseWriter, r *http.Request) {
+	roomID := chi.URLParam(r, "id")
+	if roomID == "" {
+		writeError(w, r, http.StatusBadRequest, "room ID is required")
+		return
+	}
+
+	response := models.SignalsResponse{
+		Signals: h.signalService.GetSignals(roomID),
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/adi-253/Talkie/backend/internal/models"
+	"github.com/adi-253/Talkie/backend/internal/services"
+)
+
+func TestSendSignalStatus(t *testing.T) {
+	env := newTestEnv(t)
+	env.seedRoom("room1")
+	alice, _ := env.join(t, "room1", "alice", "")
+	h := NewSignalHandler(services.NewSignalService(env.db, time.Minute, services.RealClock{}))
+
+	tests := []struct {
+		name string
+		body models.SignalRequest
+		want int
+	}{
+		{"raise hand", models.SignalRequest{ParticipantID: alice.ID, Signal: models.SignalRaiseHand}, http.StatusOK},
+		{"unknown signal type", models.SignalRequest{ParticipantID: alice.ID, Signal: "wave"}, http.StatusBadRequest},
+		{"missing participant", models.SignalRequest{Signal: models.SignalRaiseHand}, http.StatusBadRequest},
+		{"participant not in room", models.SignalRequest{ParticipantID: newID(), Signal: models.SignalRaiseHand}, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(t, http.MethodPost, "/api/rooms/{id}/signal", h.SendSignal, "/api/rooms/room1/signal", tt.body)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+
+	rec := serve(t, http.MethodGet, "/api/rooms/{id}/signal", h.GetSignals, "/api/rooms/room1/signal", nil)
+	var resp models.SignalsResponse
+	decode(t, rec, &resp)
+	if len(resp.Signals) != 1 || resp.Signals[0].ParticipantID != alice.ID || resp.Signals[0].Signal != models.SignalRaiseHand {
+		t.Errorf("active signals = %+v, want alice's raised hand only", resp.Signals)
+	}
+}
@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/adi-253/Talkie/backend/internal/ratelimit"
+)
+
+// globalThroughputKey is the single key of the global throughput bucket.
+const globalThroughputKey = "global"
+
+// GlobalThroughput is middleware that draws every request from one shared
+// token bucket, as a safety valve against floods spread across many clients.
+// Requests are shed with 503 and Retry-After once the bucket is empty.
+// Low-priority requests (typing, reactions) only take a token while more
+// than half the burst remains, so they are shed well before chat messages.
+// A nil bucket disables the limit.
+func GlobalThroughput(bucket *ratelimit.TokenBucket, burst int, lowPriority bool) func(http.Handler) http.Handler {
+	reserve := 0
+	if lowPriority {
+		reserve = burst / 2
+	}
+
+	return func(next http.Handler) http.Handler {
+		if bucket == nil {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			result := bucket.AllowReserving(globalThroughputKey, reserve)
+			if !result.Allowed {
+				retryAfter := int(time.Until(result.ResetAt).Seconds()) + 1
+				w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+				writeError(w, r, http.StatusServiceUnavailable, "server is busy, please retry")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
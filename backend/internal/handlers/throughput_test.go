@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/adi-253/Talkie/backend/internal/ratelimit"
+)
+
+func TestGlobalThroughputShedsLowPriorityBeforeChat(t *testing.T) {
+	const burst = 10
+	bucket := ratelimit.NewTokenBucket(burst, time.Hour)
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	chat := GlobalThroughput(bucket, burst, false)(ok)
+	typing := GlobalThroughput(bucket, burst, true)(ok)
+
+	send := func(h http.Handler) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", nil))
+		return rec
+	}
+
+	// Typing drains the bucket down to the reserve, then is shed.
+	allowed := 0
+	for i := 0; i < burst; i++ {
+		if send(typing).Code == http.StatusOK {
+			allowed++
+		}
+	}
+	if allowed != burst/2 {
+		t.Errorf("typing requests allowed = %d, want %d before shedding", allowed, burst/2)
+	}
+
+	// Chat still gets the reserved half of the bucket.
+	for i := 0; i < burst/2; i++ {
+		if rec := send(chat); rec.Code != http.StatusOK {
+			t.Fatalf("chat message %d shed with %d while the reserve remained", i, rec.Code)
+		}
+	}
+
+	rec := send(chat)
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("chat on an empty bucket: status = %d, want 503", rec.Code)
+	}
+	if rec.Header().Get("Retry-After") == "" {
+		t.Error("shed response has no Retry-After header")
+	}
+}
+
+func TestGlobalThroughputDisabledWithoutBucket(t *testing.T) {
+	h := GlobalThroughput(nil, 0, true)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	for i := 0; i < 100; i++ {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("request %d: status = %d with the limit disabled", i, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/adi-253/Talkie/backend/internal/models"
+	"github.com/adi-253/Talkie/backend/internal/services"
+	"github.com/go-chi/chi/v5"
+)
+
+// TypingHandler contains HTTP handlers for typing indicators.
+// Provides typing state to polling clients that can't see realtime events.
+type TypingHandler struct {
+	typingService *services.TypingService
+	roomService   *services.RoomService
+}
+
+// NewTypingHandler creates a new TypingHandler instance.
+// - roomService: authenticates the participant updating typing state
+func NewTypingHandler(typingService *services.TypingService, roomService *services.RoomService) *TypingHandler {
+	return &TypingHandler{typingService: typingService, roomService: roomService}
+}
+
+// SetTyping handles POST /api/rooms/{id}/typing
+// Records that a participant started or stopped typing.
+// Requires the participant's credential.
+func (h *TypingHandler) SetTyping(w http.ResponseWriter, r *http.Request) {
+	roomID := chi.URLParam(r, "id")
+	if roomID == "" {
+		writeError(w, r, http.StatusBadRequest, "room ID is required")
+		return
+	}
+
+	var req models.TypingRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeDecodeError(w, r, err)
+		return
+	}
+
+	if req.ParticipantID == "" {
+		writeError(w, r, http.StatusBadRequest, "participant ID is required")
+		return
+	}
+
+	if !requireParticipant(w, r, h.roomService, req.ParticipantID) {
+		return
+	}
+
+	if err := h.typingService.SetTyping(roomID, req.ParticipantID, req.IsTyping); err != nil {
+		if errors.Is(err, services.ErrParticipantNotFound) {
+			writeError(w, r, http.StatusNotFound, err.Error())
+			return
+		}
+		log.Printf("[Typing] Failed to set typing in room %s: %v", roomID, err)
+		writeInternalError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetTyping handles GET /api/rooms/{id}/typing
+// Returns the participants currently typing in the room.
+func (h *TypingHandler) GetTyping(w http.ResponseWriter, r *http.Request) {
+	roomID := chi.URLParam(r, "id")
+	if roomID == "" {
+		writeError(w, r, http.StatusBadRequest, "room ID is required")
+		return
+	}
+
+	response := models.TypingResponse{
+		Typing: h.typingService.GetTyping(roomID),
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/adi-253/Talkie/backend/internal/models"
+	"github.com/adi-253/Talkie/backend/internal/services"
+)
+
+func TestSetTypingStatus(t *testing.T) {
+	env := newTestEnv(t)
+	env.seedRoom("room1")
+	env.seedRoom("room2")
+	alice, aliceSecret := env.join(t, "room1", "alice", "")
+	bob, _ := env.join(t, "room1", "bob", "")
+	outsider, outsiderSecret := env.join(t, "room2", "outsider", "")
+	h := NewTypingHandler(services.NewTypingService(env.db, time.Minute, services.RealClock{}), env.rooms)
+
+	tests := []struct {
+		name    string
+		body    models.TypingRequest
+		headers []string
+		want    int
+	}{
+		{"start typing", models.TypingRequest{ParticipantID: alice.ID, IsTyping: true}, bearer(aliceSecret), http.StatusNoContent},
+		{"missing participant", models.TypingRequest{IsTyping: true}, bearer(aliceSecret), http.StatusBadRequest},
+		{"no credential", models.TypingRequest{ParticipantID: bob.ID, IsTyping: true}, nil, http.StatusUnauthorized},
+		{"another participant's credential", models.TypingRequest{ParticipantID: bob.ID, IsTyping: true}, bearer(aliceSecret), http.StatusUnauthorized},
+		{"participant not in room", models.TypingRequest{ParticipantID: outsider.ID, IsTyping: true}, bearer(outsiderSecret), http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(t, http.MethodPost, "/api/rooms/{id}/typing", h.SetTyping, "/api/rooms/room1/typing", tt.body, tt.headers...)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+
+	rec := serve(t, http.MethodGet, "/api/rooms/{id}/typing", h.GetTyping, "/api/rooms/room1/typing", nil)
+	var resp models.TypingResponse
+	decode(t, rec, &resp)
+	if len(resp.Typing) != 1 || resp.Typing[0].ParticipantID != alice.ID || resp.Typing[0].Username != "alice" {
+		t.Errorf("typing = %+v, want alice only", resp.Typing)
+	}
+}
@@ -0,0 +1,22 @@
+package logging
+
+import (
+	"log"
+	"sync/atomic"
+)
+
+// debug controls whether Debugf output is written.
+var debug atomic.Bool
+
+// SetDebug enables or disables debug-level logging.
+func SetDebug(enabled bool) {
+	debug.Store(enabled)
+}
+
+// Debugf logs a message only when debug-level logging is enabled.
+// Use it for per-message and per-broadcast lines that would flood logs in busy rooms.
+func Debugf(format string, v ...interface{}) {
+	if debug.Load() {
+		log.Printf(format, v...)
+	}
+}
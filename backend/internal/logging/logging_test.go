@@ -0,0 +1,39 @@
+package logging
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"strings"
+	"testing"
+)
+
+// captureLog redirects the standard logger to a buffer for the test.
+func captureLog(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+	return &buf
+}
+
+func TestDebugfSuppressedAtInfoLevel(t *testing.T) {
+	buf := captureLog(t)
+	SetDebug(false)
+
+	Debugf("[Broadcast] sent to client %s", "abc")
+	if buf.Len() != 0 {
+		t.Errorf("info level logged %q, want nothing", buf.String())
+	}
+}
+
+func TestDebugfWrittenAtDebugLevel(t *testing.T) {
+	buf := captureLog(t)
+	SetDebug(true)
+	t.Cleanup(func() { SetDebug(false) })
+
+	Debugf("[Broadcast] sent to client %s", "abc")
+	if !strings.Contains(buf.String(), "[Broadcast] sent to client abc") {
+		t.Errorf("debug level logged %q, want the broadcast line", buf.String())
+	}
+}
@@ -12,23 +12,52 @@ type Message struct {
 	// RoomID is the room this message belongs to
 	RoomID string `json:"room_id"`
 
+	// Seq is the message's position in its room, assigned by the server (starts at 1)
+	Seq int64 `json:"seq"`
+
+	// System marks a plaintext server-generated message (e.g. an announcement)
+	System bool `json:"system,omitempty"`
+
 	// ParticipantID is the sender's participant ID
 	ParticipantID string `json:"participant_id"`
 
 	// Content is the encrypted message payload (encrypted by the client)
 	Content string `json:"content"`
 
+	// Encrypted indicates Content is an AES-GCM envelope (base64 of IV + ciphertext + tag)
+	Encrypted bool `json:"encrypted,omitempty"`
+
 	// Username is the sender's display name
 	Username string `json:"username"`
 
 	// Avatar is the sender's avatar identifier
 	Avatar string `json:"avatar"`
 
-	// Timestamp is when the message was sent
+	// Timestamp is when the message was sent according to the sender's clock,
+	// for display only; it falls back to ServerTimestamp if the client sent none
 	Timestamp time.Time `json:"timestamp"`
 
+	// ServerTimestamp is when the server stored the message; it drives
+	// ordering, the 'after' filter and expiry since client clocks can't be trusted
+	ServerTimestamp time.Time `json:"server_timestamp"`
+
 	// ReplyTo contains optional reply context
 	ReplyTo *ReplyContext `json:"reply_to,omitempty"`
+
+	// Attachments references files stored elsewhere (the server never fetches them)
+	Attachments []Attachment `json:"attachments,omitempty"`
+
+	// Ephemeral messages are deleted once every current participant has read them
+	Ephemeral bool `json:"ephemeral,omitempty"`
+}
+
+// Attachment is metadata for a file shared in a message.
+// The file itself lives in external storage (e.g. Supabase Storage).
+type Attachment struct {
+	Name string `json:"name"`
+	Mime string `json:"mime"`
+	Size int64  `json:"size"` // Size in bytes
+	URL  string `json:"url"`
 }
 
 // ReplyContext holds information about a message being replied to
@@ -40,13 +69,39 @@ type ReplyContext struct {
 // SendMessageRequest is the request body for sending a message
 type SendMessageRequest struct {
 	ParticipantID string        `json:"participant_id"`
-	Content       string        `json:"content"` // Encrypted content
+	Content       string        `json:"content"`             // Encrypted content
+	Encrypted     bool          `json:"encrypted,omitempty"` // If set, Content is validated as an AES-GCM envelope
 	Username      string        `json:"username"`
 	Avatar        string        `json:"avatar"`
+	Timestamp     time.Time     `json:"timestamp,omitempty"` // Client send time, for display only
 	ReplyTo       *ReplyContext `json:"reply_to,omitempty"`
+	Attachments   []Attachment  `json:"attachments,omitempty"`
+	Ephemeral     bool          `json:"ephemeral,omitempty"` // Burn after every current participant reads it
+
+	// Signature is base64 HMAC-SHA256 over "room_id\nparticipant_id\ncontent"
+	// with the sender's signing secret; required in rooms with require_signatures
+	Signature string `json:"signature,omitempty"`
+}
+
+// MarkReadRequest is the request body for advancing a participant's read cursor
+type MarkReadRequest struct {
+	ParticipantID string `json:"participant_id"`
+
+	// Seq is the highest message sequence number the participant has seen
+	Seq int64 `json:"seq"`
+}
+
+// AnnouncementRequest is the request body for posting a system announcement
+type AnnouncementRequest struct {
+	Content string `json:"content"`
 }
 
 // GetMessagesResponse is the response for fetching messages
 type GetMessagesResponse struct {
 	Messages []Message `json:"messages"`
 }
+
+// MessageCountResponse is the response for counting messages in a room
+type MessageCountResponse struct {
+	Count int `json:"count"`
+}
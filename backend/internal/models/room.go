@@ -21,6 +21,34 @@ type Room struct {
 	// LastActiveAt is updated on each heartbeat to track room activity
 	// Used by the cleanup service to delete inactive rooms
 	LastActiveAt time.Time `json:"last_active_at"`
+
+	// HostParticipantID is the participant allowed to manage the room
+	// The first participant to join becomes the host
+	HostParticipantID string `json:"host_participant_id,omitempty"`
+
+	// Locked prevents new participants from joining (existing ones may rejoin)
+	Locked bool `json:"locked"`
+
+	// MessageTTLSeconds expires messages older than this while the room is live (0 disables)
+	MessageTTLSeconds int `json:"message_ttl_seconds"`
+
+	// Persist stores messages for history; when false, messages are only relayed live
+	Persist bool `json:"persist"`
+
+	// KeyVersion is the current key epoch; clients derive the epoch key from
+	// EncryptionKey, KeySalt and KeyVersion. Bumped on every key rotation.
+	KeyVersion int `json:"key_version"`
+
+	// KeySalt is the random salt (base64) for deriving the current epoch key
+	KeySalt string `json:"key_salt,omitempty"`
+
+	// RequireSignatures rejects messages not signed with the sender's signing secret
+	RequireSignatures bool `json:"require_signatures"`
+}
+
+// MessageTTL returns the room's message TTL as a duration.
+func (r *Room) MessageTTL() time.Duration {
+	return time.Duration(r.MessageTTLSeconds) * time.Second
 }
 
 // Participant represents a user currently in a chat room.
@@ -43,11 +71,48 @@ type Participant struct {
 
 	// LastActiveAt is updated on each heartbeat for inactivity tracking
 	LastActiveAt time.Time `json:"last_active_at"`
+
+	// Role is RoleParticipant or RoleObserver; observers can read but not send
+	Role string `json:"role"`
+
+	// Status is an optional short custom status, e.g. "brb"
+	Status string `json:"status,omitempty"`
+
+	// DurationSeconds is how long the participant has been in the room.
+	// It is computed for responses (see SetDuration) and not stored.
+	DurationSeconds *int64 `json:"duration_seconds,omitempty"`
 }
 
+// SetDuration fills in DurationSeconds as of now.
+func (p *Participant) SetDuration(now time.Time) {
+	seconds := int64(max(now.Sub(p.JoinedAt), 0) / time.Second)
+	p.DurationSeconds = &seconds
+}
+
+// Participant roles
+const (
+	// RoleParticipant can read and send messages
+	RoleParticipant = "participant"
+
+	// RoleObserver can read messages and appears in presence, but cannot send
+	RoleObserver = "observer"
+)
+
 // CreateRoomRequest is the request body for creating a new room
 type CreateRoomRequest struct {
 	Name string `json:"name"`
+
+	// ID optionally requests a specific room ID, e.g. for rooms scheduled in advance
+	ID string `json:"id,omitempty"`
+
+	// Persist controls whether messages are stored for history (default true)
+	Persist *bool `json:"persist,omitempty"`
+
+	// MessageTTLSeconds optionally expires messages after this many seconds
+	MessageTTLSeconds int `json:"message_ttl_seconds,omitempty"`
+
+	// RequireSignatures makes the room reject unsigned or forged messages
+	RequireSignatures bool `json:"require_signatures,omitempty"`
 }
 
 // CreateRoomResponse is the response after creating a room
@@ -59,6 +124,13 @@ type CreateRoomResponse struct {
 type JoinRoomRequest struct {
 	Username string `json:"username"`
 	Avatar   string `json:"avatar"`
+
+	// Role is RoleParticipant (default) or RoleObserver
+	Role string `json:"role,omitempty"`
+
+	// ParticipantID is set when a client rejoins with a previous session
+	// The rejoin must carry the session's signing secret as a Bearer token
+	ParticipantID string `json:"participant_id,omitempty"`
 }
 
 // JoinRoomResponse is the response after joining a room
@@ -66,6 +138,10 @@ type JoinRoomResponse struct {
 	ParticipantID string        `json:"participant_id"`
 	Room          Room          `json:"room"`
 	Participants  []Participant `json:"participants"`
+
+	// SigningSecret (base64) is the HMAC key the participant signs messages with
+	// It is also the participant's credential and is only returned on a fresh join
+	SigningSecret string `json:"signing_secret,omitempty"`
 }
 
 // LeaveRoomRequest is the request body for leaving a room
@@ -73,6 +149,92 @@ type LeaveRoomRequest struct {
 	ParticipantID string `json:"participant_id"`
 }
 
+// BulkLeaveEntry identifies one room membership to leave in a bulk leave request.
+// Secret is the participant's credential, sent in the body because beacons
+// can't set an Authorization header.
+type BulkLeaveEntry struct {
+	RoomID        string `json:"room_id"`
+	ParticipantID string `json:"participant_id"`
+	Secret        string `json:"secret"`
+}
+
+// BulkLeaveResult reports the outcome of one entry of a bulk leave request
+type BulkLeaveResult struct {
+	RoomID        string `json:"room_id"`
+	ParticipantID string `json:"participant_id"`
+	OK            bool   `json:"ok"`
+	Error         string `json:"error,omitempty"`
+}
+
+// BulkLeaveResponse is the response to a bulk leave request
+type BulkLeaveResponse struct {
+	Results []BulkLeaveResult `json:"results"`
+}
+
+// LockRoomRequest is the request body for locking or unlocking a room
+type LockRoomRequest struct {
+	ParticipantID string `json:"participant_id"`
+}
+
+// UpdateParticipantRequest is the request body for updating a participant.
+// Nil fields are left unchanged.
+type UpdateParticipantRequest struct {
+	// Status sets the participant's custom status; an empty string clears it
+	Status *string `json:"status,omitempty"`
+
+	// Username and Avatar change how the participant appears to others.
+	// These changes are rate limited per participant.
+	Username *string `json:"username,omitempty"`
+	Avatar   *string `json:"avatar,omitempty"`
+}
+
+// ChangesIdentity reports whether the request changes the username or avatar.
+func (r UpdateParticipantRequest) ChangesIdentity() bool {
+	return r.Username != nil || r.Avatar != nil
+}
+
+// RegenerateRoomIDRequest is the request body for moving a room to a new ID
+type RegenerateRoomIDRequest struct {
+	ParticipantID string `json:"participant_id"`
+}
+
+// RegenerateRoomIDResponse is the response after moving a room to a new ID
+type RegenerateRoomIDResponse struct {
+	RoomID string `json:"room_id"`
+}
+
+// RotateKeyRequest is the request body for rotating a room's key epoch
+type RotateKeyRequest struct {
+	ParticipantID string `json:"participant_id"`
+}
+
+// CloseRoomRequest is the request body for closing (deleting) a room
+type CloseRoomRequest struct {
+	ParticipantID string `json:"participant_id"`
+}
+
+// TransferHostRequest is the request body for handing the host role to another participant
+type TransferHostRequest struct {
+	// ParticipantID is the current host
+	ParticipantID string `json:"participant_id"`
+
+	// NewHostID is the participant who becomes host
+	NewHostID string `json:"new_host_id"`
+}
+
+// KickInactiveRequest is the request body for removing idle participants
+type KickInactiveRequest struct {
+	ParticipantID string `json:"participant_id"`
+
+	// IdleSeconds removes participants inactive for longer than this
+	IdleSeconds int `json:"idle_seconds"`
+}
+
+// KickInactiveResponse is the response after removing idle participants
+type KickInactiveResponse struct {
+	Removed int `json:"removed"`
+}
+
 // HeartbeatRequest is used to keep the room alive
 type HeartbeatRequest struct {
 	ParticipantID string `json:"participant_id"`
@@ -83,3 +245,8 @@ type RoomInfoResponse struct {
 	Room         Room          `json:"room"`
 	Participants []Participant `json:"participants"`
 }
+
+// AvatarsResponse lists the avatar identifiers participants may choose from
+type AvatarsResponse struct {
+	Avatars []string `json:"avatars"`
+}
@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// Signal types a participant may send. Raising a hand stays up until lowered
+// or expired; reactions are momentary.
+const (
+	SignalRaiseHand = "raise_hand"
+	SignalLowerHand = "lower_hand"
+	SignalThumbsUp  = "thumbs_up"
+	SignalClap      = "clap"
+	SignalHeart     = "heart"
+	SignalLaugh     = "laugh"
+)
+
+// ParticipantSignal is an active signal from a participant in a room.
+// Signals clear automatically at ExpiresAt.
+type ParticipantSignal struct {
+	ParticipantID string    `json:"participant_id"`
+	Signal        string    `json:"signal"`
+	ExpiresAt     time.Time `json:"expires_at"`
+}
+
+// SignalRequest is the request body for sending a signal
+type SignalRequest struct {
+	ParticipantID string `json:"participant_id"`
+	Signal        string `json:"signal"`
+}
+
+// SignalsResponse lists the active signals in a room
+type SignalsResponse struct {
+	Signals []ParticipantSignal `json:"signals"`
+}
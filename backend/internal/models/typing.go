@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// TypingParticipant is a participant currently typing in a room.
+// Entries expire shortly after the last typing update.
+type TypingParticipant struct {
+	ParticipantID string    `json:"participant_id"`
+	Username      string    `json:"username"`
+	ExpiresAt     time.Time `json:"expires_at"`
+}
+
+// TypingRequest is the request body for updating typing state.
+// The username shown is the participant's current one.
+type TypingRequest struct {
+	ParticipantID string `json:"participant_id"`
+	IsTyping      bool   `json:"is_typing"`
+}
+
+// TypingResponse lists the participants currently typing in a room
+type TypingResponse struct {
+	Typing []TypingParticipant `json:"typing"`
+}
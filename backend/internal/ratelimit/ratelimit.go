@@ -0,0 +1,76 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Limiter is a fixed-window rate limiter keyed by an arbitrary string (e.g. client IP).
+// Each key may make up to limit requests per window.
+type Limiter struct {
+	limit  int
+	window time.Duration
+
+	mu        sync.Mutex
+	counters  map[string]*counter
+	nextSweep time.Time
+}
+
+// counter tracks the request count for a single key in the current window.
+type counter struct {
+	count   int
+	resetAt time.Time
+}
+
+// Result describes the outcome of a rate limit check.
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	ResetAt   time.Time
+}
+
+// NewLimiter creates a new Limiter allowing limit requests per window for each key.
+func NewLimiter(limit int, window time.Duration) *Limiter {
+	return &Limiter{
+		limit:    limit,
+		window:   window,
+		counters: make(map[string]*counter),
+	}
+}
+
+// Allow records a request for key and reports whether it is within the limit.
+func (l *Limiter) Allow(key string) Result {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.sweep(now)
+
+	c, ok := l.counters[key]
+	if !ok || !now.Before(c.resetAt) {
+		c = &counter{resetAt: now.Add(l.window)}
+		l.counters[key] = c
+	}
+
+	if c.count >= l.limit {
+		return Result{Allowed: false, Limit: l.limit, Remaining: 0, ResetAt: c.resetAt}
+	}
+
+	c.count++
+	return Result{Allowed: true, Limit: l.limit, Remaining: l.limit - c.count, ResetAt: c.resetAt}
+}
+
+// sweep drops expired counters so the map doesn't grow without bound.
+// Runs at most once per window. Must be called with l.mu held.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Before(l.nextSweep) {
+		return
+	}
+	for key, c := range l.counters {
+		if !now.Before(c.resetAt) {
+			delete(l.counters, key)
+		}
+	}
+	l.nextSweep = now.Add(l.window)
+}
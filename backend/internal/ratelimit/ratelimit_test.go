@@ -0,0 +1,40 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLimiterRemainingDecrementsAndResets(t *testing.T) {
+	const window = 50 * time.Millisecond
+	l := NewLimiter(3, window)
+
+	var first Result
+	for i, want := range []int{2, 1, 0} {
+		res := l.Allow("1.2.3.4")
+		if !res.Allowed || res.Limit != 3 || res.Remaining != want {
+			t.Fatalf("request %d = %+v, want allowed with %d remaining", i+1, res, want)
+		}
+		if i == 0 {
+			first = res
+		} else if !res.ResetAt.Equal(first.ResetAt) {
+			t.Errorf("request %d reset = %v, want %v (same window)", i+1, res.ResetAt, first.ResetAt)
+		}
+	}
+
+	if res := l.Allow("1.2.3.4"); res.Allowed || res.Remaining != 0 {
+		t.Errorf("request over limit = %+v, want denied with 0 remaining", res)
+	}
+	if res := l.Allow("5.6.7.8"); !res.Allowed || res.Remaining != 2 {
+		t.Errorf("other key = %+v, want its own full quota", res)
+	}
+
+	time.Sleep(window + 10*time.Millisecond)
+	res := l.Allow("1.2.3.4")
+	if !res.Allowed || res.Remaining != 2 {
+		t.Errorf("after window = %+v, want allowed with 2 remaining", res)
+	}
+	if !res.ResetAt.After(first.ResetAt) {
+		t.Errorf("reset after window = %v, want later than %v", res.ResetAt, first.ResetAt)
+	}
+}
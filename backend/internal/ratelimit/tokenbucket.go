@@ -0,0 +1,85 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// TokenBucket is a token-bucket rate limiter keyed by an arbitrary string
+// (e.g. participant ID). Each key may burst up to burst requests, after which
+// requests are allowed at the sustained rate of one per refill interval.
+type TokenBucket struct {
+	burst  int
+	refill time.Duration
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	nextSweep time.Time
+}
+
+// bucket tracks the available tokens for a single key.
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewTokenBucket creates a TokenBucket that allows bursts of up to burst
+// requests per key and refills one token every refill interval.
+func NewTokenBucket(burst int, refill time.Duration) *TokenBucket {
+	return &TokenBucket{
+		burst:   burst,
+		refill:  refill,
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// Allow takes a token for key if one is available and reports the outcome.
+// ResetAt is when the next token becomes available.
+func (b *TokenBucket) Allow(key string) Result {
+	return b.AllowReserving(key, 0)
+}
+
+// AllowReserving is like Allow, but only takes a token if at least reserve
+// tokens would remain afterwards. Low-priority traffic uses it so that, as
+// the bucket drains, it is shed first and the reserve is kept for the rest.
+func (b *TokenBucket) AllowReserving(key string, reserve int) Result {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	b.sweep(now)
+
+	bk, ok := b.buckets[key]
+	if !ok {
+		bk = &bucket{tokens: float64(b.burst), last: now}
+		b.buckets[key] = bk
+	}
+
+	// Refill for the time elapsed since the last request, capped at the burst size
+	bk.tokens = min(float64(b.burst), bk.tokens+float64(now.Sub(bk.last))/float64(b.refill))
+	bk.last = now
+
+	if need := float64(1 + reserve); bk.tokens < need {
+		wait := time.Duration((need - bk.tokens) * float64(b.refill))
+		return Result{Allowed: false, Limit: b.burst, Remaining: 0, ResetAt: now.Add(wait)}
+	}
+
+	bk.tokens--
+	return Result{Allowed: true, Limit: b.burst, Remaining: int(bk.tokens), ResetAt: now.Add(b.refill)}
+}
+
+// sweep drops buckets that have refilled completely, since they are
+// indistinguishable from new ones. Runs at most once per full refill.
+// Must be called with b.mu held.
+func (b *TokenBucket) sweep(now time.Time) {
+	if now.Before(b.nextSweep) {
+		return
+	}
+	full := time.Duration(b.burst) * b.refill
+	for key, bk := range b.buckets {
+		if now.Sub(bk.last) >= full {
+			delete(b.buckets, key)
+		}
+	}
+	b.nextSweep = now.Add(full)
+}
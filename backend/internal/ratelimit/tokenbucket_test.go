@@ -0,0 +1,55 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTokenBucketBurstThenSustainedRate(t *testing.T) {
+	const refill = 100 * time.Millisecond
+	b := NewTokenBucket(3, refill)
+
+	for i, want := range []int{2, 1, 0} {
+		res := b.Allow("alice")
+		if !res.Allowed || res.Limit != 3 || res.Remaining != want {
+			t.Fatalf("burst request %d = %+v, want allowed with %d remaining", i+1, res, want)
+		}
+	}
+
+	start := time.Now()
+	res := b.Allow("alice")
+	if res.Allowed || res.Remaining != 0 {
+		t.Fatalf("request past the burst = %+v, want denied", res)
+	}
+	if wait := res.ResetAt.Sub(start); wait <= 0 || wait > refill {
+		t.Errorf("reset in %v, want within one refill interval", wait)
+	}
+	if res := b.Allow("bob"); !res.Allowed || res.Remaining != 2 {
+		t.Errorf("other key = %+v, want its own full burst", res)
+	}
+
+	// One interval refills a single token, not the whole burst
+	time.Sleep(refill + 10*time.Millisecond)
+	if res := b.Allow("alice"); !res.Allowed {
+		t.Errorf("after one refill interval = %+v, want allowed", res)
+	}
+	if res := b.Allow("alice"); res.Allowed {
+		t.Errorf("second request after one refill interval = %+v, want denied", res)
+	}
+}
+
+func TestTokenBucketAllowReservingShedsFirst(t *testing.T) {
+	b := NewTokenBucket(3, time.Hour)
+
+	for i := 0; i < 2; i++ {
+		if res := b.AllowReserving("alice", 1); !res.Allowed {
+			t.Fatalf("reserving request %d = %+v, want allowed", i+1, res)
+		}
+	}
+	if res := b.AllowReserving("alice", 1); res.Allowed {
+		t.Errorf("reserving request into the reserve = %+v, want denied", res)
+	}
+	if res := b.Allow("alice"); !res.Allowed || res.Remaining != 0 {
+		t.Errorf("normal request = %+v, want the reserved token", res)
+	}
+}
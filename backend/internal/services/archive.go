@@ -0,0 +1,77 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/adi-253/Talkie/backend/internal/models"
+)
+
+// ArchiveHook receives a room's stored messages when cleanup deletes the room,
+// e.g. to ship the (still encrypted) transcript elsewhere. Archiving is
+// best-effort: cleanup calls Archive from a single background worker, logs a
+// returned error and deletes the room anyway.
+type ArchiveHook interface {
+	Archive(room *models.Room, messages []models.Message) error
+}
+
+// NoopArchiveHook discards transcripts. It is the default ArchiveHook.
+type NoopArchiveHook struct{}
+
+// Archive implements ArchiveHook.
+func (NoopArchiveHook) Archive(*models.Room, []models.Message) error {
+	return nil
+}
+
+// archivePayload is the JSON body WebhookArchiveHook posts.
+type archivePayload struct {
+	Room       models.Room      `json:"room"`
+	Messages   []models.Message `json:"messages"`
+	ArchivedAt time.Time        `json:"archived_at"`
+}
+
+// WebhookArchiveHook posts each archived room and its messages as JSON to a URL.
+// The room's encryption key is never included.
+type WebhookArchiveHook struct {
+	url        string
+	httpClient *http.Client
+}
+
+// NewWebhookArchiveHook creates an ArchiveHook that POSTs transcripts to url.
+func NewWebhookArchiveHook(url string) *WebhookArchiveHook {
+	return &WebhookArchiveHook{
+		url: url,
+		httpClient: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+	}
+}
+
+// Archive implements ArchiveHook. Non-2xx responses are returned as errors.
+func (h *WebhookArchiveHook) Archive(room *models.Room, messages []models.Message) error {
+	payload := archivePayload{
+		Room:       *room,
+		Messages:   messages,
+		ArchivedAt: time.Now().UTC(),
+	}
+	payload.Room.EncryptionKey = ""
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal archive: %w", err)
+	}
+
+	resp, err := h.httpClient.Post(h.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("archive webhook request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("archive webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/adi-253/Talkie/backend/internal/models"
+)
+
+// fakeArchiveHook reports archived rooms on a channel, optionally waiting for
+// release before returning err.
+type fakeArchiveHook struct {
+	archived chan archiveJob
+	release  chan struct{}
+	err      error
+}
+
+func newFakeArchiveHook(err error) *fakeArchiveHook {
+	return &fakeArchiveHook{archived: make(chan archiveJob, 16), err: err}
+}
+
+func (h *fakeArchiveHook) Archive(room *models.Room, messages []models.Message) error {
+	h.archived <- archiveJob{room: *room, messages: messages}
+	if h.release != nil {
+		<-h.release
+	}
+	return h.err
+}
+
+// newArchivingCleanup starts the archive worker of a cleanup service using hook.
+func newArchivingCleanup(t *testing.T, s *testServices, hook ArchiveHook) *CleanupService {
+	t.Helper()
+	cleanup := NewCleanupService(s.db, s.messages, hook, nil, s.rooms.InvalidateRoom, s.rooms.ForgetRoom, time.Minute,
+		2*time.Minute, 10*time.Minute, 0, false, s.clock)
+	go cleanup.runArchiver()
+	t.Cleanup(cleanup.Stop)
+	return cleanup
+}
+
+// seedStaleRoomWithMessages stores an empty room with count messages that the
+// next cleanup will delete once the clock is advanced past the room timeout.
+func seedStaleRoomWithMessages(t *testing.T, s *testServices, roomID string, count int) {
+	t.Helper()
+	p := seedParticipant(s.srv, roomID, models.RoleParticipant, s.clock.Now())
+	for i := 0; i < count; i++ {
+		if _, err := s.messages.SendMessage(roomID, models.SendMessageRequest{ParticipantID: p.ID, Content: "ciphertext"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.srv.DeleteParticipant(p.ID)
+}
+
+func receiveArchive(t *testing.T, hook *fakeArchiveHook) archiveJob {
+	t.Helper()
+	select {
+	case job := <-hook.archived:
+		return job
+	case <-time.After(2 * time.Second):
+		t.Fatal("room was not archived")
+		return archiveJob{}
+	}
+}
+
+func TestCleanupArchivesDeletedRooms(t *testing.T) {
+	s := newTestServices(t)
+	hook := newFakeArchiveHook(nil)
+	cleanup := newArchivingCleanup(t, s, hook)
+	seedStaleRoomWithMessages(t, s, "stale", 2)
+	s.clock.Advance(11 * time.Minute)
+
+	cleanup.cleanup()
+
+	job := receiveArchive(t, hook)
+	if job.room.ID != "stale" || len(job.messages) != 2 {
+		t.Errorf("archived room %s with %d messages, want stale with 2", job.room.ID, len(job.messages))
+	}
+	if _, ok := s.srv.Room("stale"); ok {
+		t.Error("archived room was not deleted")
+	}
+}
+
+func TestCleanupDeletesRoomWhenArchiveFails(t *testing.T) {
+	s := newTestServices(t)
+	hook := newFakeArchiveHook(errors.New("archive unavailable"))
+	cleanup := newArchivingCleanup(t, s, hook)
+	seedStaleRoomWithMessages(t, s, "stale", 1)
+	s.clock.Advance(11 * time.Minute)
+
+	cleanup.cleanup()
+
+	receiveArchive(t, hook)
+	if _, ok := s.srv.Room("stale"); ok {
+		t.Error("room was kept after its archive failed")
+	}
+}
+
+func TestCleanupArchivesOnlyAfterDelete(t *testing.T) {
+	s := newTestServices(t)
+	cleanup := NewCleanupService(s.db, s.messages, newFakeArchiveHook(nil), nil, s.rooms.InvalidateRoom, s.rooms.ForgetRoom, time.Minute,
+		2*time.Minute, 10*time.Minute, 0, false, s.clock)
+	seedStaleRoomWithMessages(t, s, "stale", 1)
+	s.clock.Advance(11 * time.Minute)
+	threshold := s.clock.Now().Add(-10 * time.Minute)
+
+	s.srv.FailNext("DELETE", "rooms", http.StatusInternalServerError, nil, `{"message":"boom"}`)
+	cleanup.cleanupRooms(threshold)
+	if _, ok := s.srv.Room("stale"); !ok {
+		t.Fatal("room was deleted despite the failed request")
+	}
+	if n := len(cleanup.archiveQueue); n != 0 {
+		t.Fatalf("archive jobs queued for a room that wasn't deleted = %d, want 0", n)
+	}
+
+	// The next pass deletes the room and archives it once
+	cleanup.cleanupRooms(threshold)
+	if n := len(cleanup.archiveQueue); n != 1 {
+		t.Errorf("archive jobs after the delete succeeded = %d, want 1", n)
+	}
+}
+
+func TestSlowArchiveHookDoesNotBlockCleanup(t *testing.T) {
+	s := newTestServices(t)
+	hook := newFakeArchiveHook(nil)
+	hook.release = make(chan struct{})
+	defer close(hook.release)
+	cleanup := newArchivingCleanup(t, s, hook)
+	for _, id := range []string{"stale1", "stale2", "stale3"} {
+		seedStaleRoomWithMessages(t, s, id, 1)
+	}
+	s.clock.Advance(11 * time.Minute)
+
+	done := make(chan struct{})
+	go func() {
+		cleanup.cleanup()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("cleanup blocked on a slow archive hook")
+	}
+
+	if got := len(s.srv.Rooms()); got != 0 {
+		t.Errorf("rooms left = %d, want all deleted while archiving is pending", got)
+	}
+	receiveArchive(t, hook)
+}
+
+func TestWebhookArchiveHook(t *testing.T) {
+	var got archivePayload
+	status := http.StatusOK
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("request = %s with %q, want a JSON POST", r.Method, r.Header.Get("Content-Type"))
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("decode archive: %v", err)
+		}
+		w.WriteHeader(status)
+	}))
+	defer receiver.Close()
+	hook := NewWebhookArchiveHook(receiver.URL)
+
+	room := &models.Room{ID: "room1", Name: "Room", EncryptionKey: "top-secret"}
+	messages := []models.Message{{ID: "m1", RoomID: "room1", Content: "ciphertext", Seq: 1}}
+	if err := hook.Archive(room, messages); err != nil {
+		t.Fatalf("Archive: %v", err)
+	}
+	if got.Room.ID != "room1" || len(got.Messages) != 1 || got.Messages[0].Content != "ciphertext" || got.ArchivedAt.IsZero() {
+		t.Errorf("posted archive = %+v, want room1 with its message", got)
+	}
+	if got.Room.EncryptionKey != "" || room.EncryptionKey != "top-secret" {
+		t.Error("encryption key was posted or cleared on the caller's room")
+	}
+
+	status = http.StatusBadGateway
+	if err := hook.Archive(room, messages); err == nil {
+		t.Error("Archive succeeded on a 502 response")
+	}
+}
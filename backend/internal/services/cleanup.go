@@ -1,37 +1,112 @@
 package services
 
 import (
+	"fmt"
 	"log"
+	"sync/atomic"
 	"time"
 
+	"github.com/adi-253/Talkie/backend/internal/models"
 	"github.com/adi-253/Talkie/backend/internal/supabase"
 )
 
-// CleanupService handles automatic deletion of inactive rooms.
+// CleanupService handles automatic deletion of inactive rooms and participants.
 // It runs as a background goroutine and periodically checks for stale rooms.
+// Participants and rooms have separate timeouts, so a participant can be
+// dropped quickly while their room survives a little longer.
 type CleanupService struct {
-	db       *supabase.Client
-	interval time.Duration
-	timeout  time.Duration
-	stopChan chan struct{}
+	db                 *supabase.Client
+	messages           *MessageService
+	archive            ArchiveHook
+	webhooks           *WebhookNotifier
+	invalidate         func(roomIDs ...string)
+	forget             func(roomID string)
+	interval           time.Duration
+	participantTimeout time.Duration
+	roomTimeout        time.Duration
+	warningWindow      time.Duration
+	cleanOrphans       bool
+	clock              Clock
+	stopChan           chan struct{}
+
+	// archiveQueue holds transcripts of deleted rooms for the archive worker,
+	// so slow archive hooks can't stall the cleanup loop
+	archiveQueue chan archiveJob
+
+	// lastRun is when cleanup last completed (UnixNano), for liveness checks
+	lastRun atomic.Int64
+
+	// warned tracks the last_active_at each participant had when warned,
+	// so a participant is warned only once per idle period.
+	// Only accessed from the cleanup goroutine.
+	warned map[string]time.Time
 }
 
 // NewCleanupService creates a new cleanup service.
+// - messages: message store, archived and purged when rooms are deleted
+// - archive: receives each deleted room's messages (nil means NoopArchiveHook)
+// - webhooks: notified of removed participants and deleted rooms (nil disables)
+// - invalidate: drops cached state for rooms cleanup changes, e.g. RoomService.InvalidateRoom (nil disables)
+// - forget: drops all in-memory state for rooms cleanup deletes, e.g. RoomService.ForgetRoom (nil disables)
 // - interval: how often to check for inactive rooms (e.g., 1 minute)
-// - timeout: how long a room can be inactive before deletion (e.g., 5 minutes)
-func NewCleanupService(db *supabase.Client, interval, timeout time.Duration) *CleanupService {
-	return &CleanupService{
-		db:       db,
-		interval: interval,
-		timeout:  timeout,
-		stopChan: make(chan struct{}),
+// - participantTimeout: how long a participant can be inactive before removal (e.g., 2 minutes)
+// - roomTimeout: how long a room can be inactive before deletion (e.g., 5 minutes)
+// - warningWindow: how long before the participant timeout participants are warned (0 disables)
+// - cleanOrphans: also delete rooms with no participants on every tick
+// - clock: source of the current time for inactivity thresholds
+func NewCleanupService(db *supabase.Client, messages *MessageService, archive ArchiveHook, webhooks *WebhookNotifier, invalidate func(roomIDs ...string), forget func(roomID string), interval, participantTimeout, roomTimeout, warningWindow time.Duration, cleanOrphans bool, clock Clock) *CleanupService {
+	s := &CleanupService{
+		db:                 db,
+		messages:           messages,
+		archive:            archive,
+		webhooks:           webhooks,
+		invalidate:         invalidate,
+		forget:             forget,
+		interval:           interval,
+		participantTimeout: participantTimeout,
+		roomTimeout:        roomTimeout,
+		warningWindow:      warningWindow,
+		cleanOrphans:       cleanOrphans,
+		clock:              clock,
+		stopChan:           make(chan struct{}),
+		archiveQueue:       make(chan archiveJob, archiveQueueSize),
+		warned:             make(map[string]time.Time),
+	}
+	if archive == nil {
+		s.archive = NoopArchiveHook{}
+	}
+	if invalidate == nil {
+		s.invalidate = func(...string) {}
+	}
+	if forget == nil {
+		s.forget = func(string) {}
 	}
+	// Count startup as a run so the worker isn't reported stalled before its first tick
+	s.lastRun.Store(clock.Now().UnixNano())
+	return s
+}
+
+// livenessFactor is how many intervals may pass without a completed cleanup
+// before the worker is considered stalled.
+const livenessFactor = 3
+
+// CheckLiveness returns an error if cleanup hasn't completed within
+// livenessFactor intervals, e.g. because the worker panicked or deadlocked.
+func (s *CleanupService) CheckLiveness() error {
+	lastRun := time.Unix(0, s.lastRun.Load())
+	if since := s.clock.Now().Sub(lastRun); since > livenessFactor*s.interval {
+		return fmt.Errorf("cleanup has not run for %v (interval %v)", since.Round(time.Second), s.interval)
+	}
+	return nil
 }
 
 // Start begins the background cleanup worker.
 // This method runs in its own goroutine and should be called with 'go'.
 func (s *CleanupService) Start() {
-	log.Printf("Cleanup service started (interval: %v, timeout: %v)", s.interval, s.timeout)
+	log.Printf("Cleanup service started (interval: %v, participant timeout: %v, room timeout: %v)",
+		s.interval, s.participantTimeout, s.roomTimeout)
+
+	go s.runArchiver()
 
 	// Run cleanup immediately on startup to purge any stale data from downtime
 	s.cleanup()
@@ -55,15 +130,66 @@ func (s *CleanupService) Stop() {
 	close(s.stopChan)
 }
 
-// cleanup finds and deletes all rooms and participants that have been inactive past the timeout threshold.
+// cleanup finds and deletes all rooms and participants that have been inactive past their timeout thresholds.
 func (s *CleanupService) cleanup() {
-	threshold := time.Now().UTC().Add(-s.timeout)
+	now := s.clock.Now().UTC()
 
 	// Clean up inactive participants first
-	s.cleanupParticipants(threshold)
+	s.cleanupParticipants(now.Add(-s.participantTimeout))
 
 	// Then clean up inactive rooms
-	s.cleanupRooms(threshold)
+	s.cleanupRooms(now.Add(-s.roomTimeout))
+
+	// Optionally remove rooms left empty by join/leave races with cleanup
+	if s.cleanOrphans {
+		if _, err := s.CleanupOrphanRooms(); err != nil {
+			log.Printf("Cleanup error: failed to clean up orphan rooms: %v", err)
+		}
+	}
+
+	// Finally warn participants who are about to be cleaned up
+	s.warnInactiveParticipants()
+
+	s.lastRun.Store(s.clock.Now().UnixNano())
+}
+
+// warnInactiveParticipants broadcasts an inactivity warning to participants whose
+// last activity falls within the warning window before the timeout.
+// A participant is not warned again until they become active and go idle again.
+func (s *CleanupService) warnInactiveParticipants() {
+	if s.warningWindow <= 0 {
+		return
+	}
+
+	warnThreshold := s.clock.Now().UTC().Add(-(s.participantTimeout - s.warningWindow))
+	participants, err := s.db.GetInactiveParticipants(warnThreshold)
+	if err != nil {
+		log.Printf("Cleanup error: failed to get participants to warn: %v", err)
+		return
+	}
+
+	stillIdle := make(map[string]bool, len(participants))
+	for _, p := range participants {
+		stillIdle[p.ID] = true
+
+		if lastActive, ok := s.warned[p.ID]; ok && lastActive.Equal(p.LastActiveAt) {
+			continue
+		}
+
+		expiresAt := p.LastActiveAt.Add(s.participantTimeout)
+		if err := s.db.BroadcastInactivityWarning(&p, expiresAt); err != nil {
+			log.Printf("Failed to broadcast inactivity warning for %s: %v", p.ID, err)
+			continue
+		}
+		s.warned[p.ID] = p.LastActiveAt
+	}
+
+	// Forget participants who were removed or became active again
+	for id := range s.warned {
+		if !stillIdle[id] {
+			delete(s.warned, id)
+		}
+	}
 }
 
 // cleanupParticipants removes participants who haven't sent a heartbeat recently
@@ -81,43 +207,67 @@ func (s *CleanupService) cleanupParticipants(threshold time.Time) {
 
 	log.Printf("Cleaning up %d inactive participants", len(participants))
 
-	// Track rooms that might need to be deleted
-	roomsToCheck := make(map[string]bool)
+	// Removed participants per room; these rooms might also need to be deleted
+	removed := make(map[string][]models.Participant)
 
 	for _, p := range participants {
 		if err := s.db.RemoveParticipant(p.ID); err != nil {
 			log.Printf("Failed to remove participant %s: %v", p.ID, err)
 		} else {
 			log.Printf("Removed inactive participant: %s (%s)", p.ID, p.Username)
-			// Broadcast the leave event so other clients update instantly
-			if err := s.db.BroadcastParticipantEvent(p.RoomID, "leave", &p); err != nil {
-				log.Printf("Failed to broadcast participant leave for %s: %v", p.ID, err)
-			}
-			roomsToCheck[p.RoomID] = true
+			recordLeave(s.clock.Now(), &p, s.webhooks)
+			removed[p.RoomID] = append(removed[p.RoomID], p)
+		}
+	}
+
+	// Broadcast the leave events so other clients update instantly,
+	// batched per room to avoid one Realtime request per participant
+	for roomID, left := range removed {
+		s.invalidate(roomID)
+		if err := s.db.BroadcastParticipantsLeft(roomID, left); err != nil {
+			log.Printf("Failed to broadcast participant leaves for room %s: %v", roomID, err)
 		}
 	}
 
-	// Check each affected room and delete if empty
-	for roomID := range roomsToCheck {
-		count, err := s.db.CountParticipants(roomID)
+	// Fetch affected rooms in one request (needed for deletion broadcasts)
+	roomIDs := make([]string, 0, len(removed))
+	for roomID := range removed {
+		roomIDs = append(roomIDs, roomID)
+	}
+	rooms, err := s.db.GetRooms(roomIDs)
+	if err != nil {
+		log.Printf("Failed to fetch rooms for cleanup: %v", err)
+		return
+	}
+
+	// Delete each affected room if it is now empty
+	for i := range rooms {
+		room := &rooms[i]
+		deleted, err := s.db.DeleteRoomIfEmpty(room.ID)
 		if err != nil {
-			log.Printf("Failed to count participants in room %s: %v", roomID, err)
+			log.Printf("Failed to delete empty room %s: %v", room.ID, err)
 			continue
 		}
-		if count == 0 {
-			// Fetch room info before deleting (needed for broadcast)
-			room, roomErr := s.db.GetRoom(roomID)
-
-			if err := s.db.DeleteRoom(roomID); err != nil {
-				log.Printf("Failed to delete empty room %s: %v", roomID, err)
-			} else {
-				log.Printf("Deleted room %s (last participant removed)", roomID)
-				// Broadcast room deletion so the lobby updates in real-time
-				if roomErr == nil {
-					if err := s.db.BroadcastRoomEvent("deleted", room); err != nil {
-						log.Printf("Failed to broadcast room deleted for %s: %v", roomID, err)
-					}
-				}
+		if deleted {
+			log.Printf("Deleted room %s (last participant removed)", room.ID)
+			s.forget(room.ID)
+			// The delete is conditional, so archive afterwards; messages are
+			// still held in memory at this point
+			s.archiveRoom(room)
+			s.messages.DeleteRoomMessages(room.ID)
+			// Broadcast room deletion so the lobby updates in real-time
+			if err := s.db.BroadcastRoomEvent("deleted", room); err != nil {
+				log.Printf("Failed to broadcast room deleted for %s: %v", room.ID, err)
+			}
+			s.webhooks.RoomDeleted(room)
+			continue
+		}
+
+		// Promote a new host if the host was among those removed
+		for _, p := range removed[room.ID] {
+			if p.ID == room.HostParticipantID {
+				promoteHost(s.db, room)
+				break
 			}
 		}
 	}
@@ -142,10 +292,114 @@ func (s *CleanupService) cleanupRooms(threshold time.Time) {
 			log.Printf("Failed to delete room %s: %v", room.ID, err)
 		} else {
 			log.Printf("Deleted inactive room: %s", room.ID)
+			s.forget(room.ID)
+			// Archive only rooms actually deleted; messages are still held in
+			// memory at this point
+			s.archiveRoom(&room)
+			s.messages.DeleteRoomMessages(room.ID)
 			// Broadcast room deletion so the lobby updates in real-time
 			if err := s.db.BroadcastRoomEvent("deleted", &room); err != nil {
 				log.Printf("Failed to broadcast room deleted for %s: %v", room.ID, err)
 			}
+			s.webhooks.RoomDeleted(&room)
 		}
 	}
 }
+
+// archiveQueueSize bounds transcripts waiting for the archive worker; rooms
+// deleted while it is full are not archived.
+const archiveQueueSize = 64
+
+// archiveJob is a deleted room's transcript waiting to be archived.
+type archiveJob struct {
+	room     models.Room
+	messages []models.Message
+}
+
+// archiveRoom snapshots a room's stored messages and queues them for the
+// archive hook without waiting for it. Failures are logged and don't prevent
+// the room's deletion.
+func (s *CleanupService) archiveRoom(room *models.Room) {
+	job := archiveJob{room: *room, messages: s.messages.GetMessages(room.ID, MessageFilter{})}
+	select {
+	case s.archiveQueue <- job:
+	default:
+		log.Printf("Failed to archive room %s: archive queue full", room.ID)
+	}
+}
+
+// runArchiver passes queued transcripts to the archive hook one at a time
+// until the service is stopped.
+func (s *CleanupService) runArchiver() {
+	for {
+		select {
+		case job := <-s.archiveQueue:
+			if err := s.archive.Archive(&job.room, job.messages); err != nil {
+				log.Printf("Failed to archive room %s: %v", job.room.ID, err)
+			}
+		case <-s.stopChan:
+			return
+		}
+	}
+}
+
+// orphanGracePeriod protects newly created rooms from orphan cleanup,
+// since a room has no participants until its creator joins it.
+const orphanGracePeriod = 1 * time.Minute
+
+// orphanScanPageSize is how many rooms CleanupOrphanRooms reads per request,
+// kept below PostgREST's default max-rows so pages are never truncated.
+const orphanScanPageSize = 500
+
+// CleanupOrphanRooms deletes rooms that have no participants, regardless of
+// last_active_at. Rooms created within orphanGracePeriod are skipped.
+// The room list only picks candidates; each one is deleted with
+// DeleteRoomIfEmpty, so a room someone joined since the list was read
+// (or that a lagging read missed participants for) survives.
+// Returns the number of rooms deleted.
+func (s *CleanupService) CleanupOrphanRooms() (int, error) {
+	graceCutoff := s.clock.Now().UTC().Add(-orphanGracePeriod)
+
+	var candidates []models.Room
+	for offset := 0; ; offset += orphanScanPageSize {
+		page, err := s.db.ListRoomsPage(orphanScanPageSize, offset)
+		if err != nil {
+			return 0, err
+		}
+		for _, room := range page {
+			if !room.CreatedAt.After(graceCutoff) {
+				candidates = append(candidates, room)
+			}
+		}
+		if len(page) < orphanScanPageSize {
+			break
+		}
+	}
+
+	deleted := 0
+	for i := range candidates {
+		room := &candidates[i]
+		ok, err := s.db.DeleteRoomIfEmpty(room.ID)
+		if err != nil {
+			log.Printf("Failed to delete orphan room %s: %v", room.ID, err)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		deleted++
+		log.Printf("Deleted orphan room: %s", room.ID)
+		s.forget(room.ID)
+		// The delete is conditional, so archive afterwards; messages are
+		// still held in memory at this point
+		s.archiveRoom(room)
+		s.messages.DeleteRoomMessages(room.ID)
+		// Broadcast room deletion so the lobby updates in real-time
+		if err := s.db.BroadcastRoomEvent("deleted", room); err != nil {
+			log.Printf("Failed to broadcast room deleted for %s: %v", room.ID, err)
+		}
+		s.webhooks.RoomDeleted(room)
+	}
+
+	return deleted, nil
+}
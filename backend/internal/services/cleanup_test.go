@@ -0,0 +1,332 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/adi-253/Talkie/backend/internal/models"
+	"github.com/adi-253/Talkie/backend/internal/supabase/supabasetest"
+)
+
+// newTestCleanup creates a CleanupService over the test services' database.
+func newTestCleanup(s *testServices, participantTimeout, roomTimeout, warningWindow time.Duration) *CleanupService {
+	return NewCleanupService(s.db, s.messages, nil, nil, s.rooms.InvalidateRoom, s.rooms.ForgetRoom, time.Minute,
+		participantTimeout, roomTimeout, warningWindow, false, s.clock)
+}
+
+func TestInactivityWarningSentOncePerIdlePeriod(t *testing.T) {
+	s := newTestServices(t)
+	cleanup := newTestCleanup(s, 5*time.Minute, 10*time.Minute, 2*time.Minute)
+
+	// Idle for 4 minutes: inside the 2 minute window before the 5 minute timeout
+	idle := seedParticipant(s.srv, "room1", models.RoleParticipant, s.clock.Now().Add(-4*time.Minute))
+	// Idle for 1 minute: not yet in the window
+	seedParticipant(s.srv, "room1", models.RoleParticipant, s.clock.Now().Add(-time.Minute))
+
+	cleanup.warnInactiveParticipants()
+	cleanup.warnInactiveParticipants()
+
+	warnings := s.srv.BroadcastsFor("inactivity_warning")
+	if len(warnings) != 1 {
+		t.Fatalf("inactivity warnings = %d, want 1", len(warnings))
+	}
+	var payload struct {
+		ParticipantID string    `json:"participant_id"`
+		ExpiresAt     time.Time `json:"expires_at"`
+	}
+	if err := json.Unmarshal(warnings[0].Payload, &payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload.ParticipantID != idle.ID {
+		t.Errorf("warned participant = %s, want %s", payload.ParticipantID, idle.ID)
+	}
+	if want := idle.LastActiveAt.Add(5 * time.Minute); !payload.ExpiresAt.Equal(want) {
+		t.Errorf("expires_at = %v, want %v", payload.ExpiresAt, want)
+	}
+}
+
+func TestInactivityWarningRepeatsAfterActivity(t *testing.T) {
+	s := newTestServices(t)
+	cleanup := newTestCleanup(s, 5*time.Minute, 10*time.Minute, 2*time.Minute)
+	idle := seedParticipant(s.srv, "room1", models.RoleParticipant, s.clock.Now().Add(-4*time.Minute))
+
+	cleanup.warnInactiveParticipants()
+
+	// A heartbeat takes the participant out of the window; going idle again re-arms the warning
+	if err := s.db.UpdateParticipantActivity(idle.ID); err != nil {
+		t.Fatal(err)
+	}
+	cleanup.warnInactiveParticipants()
+	s.clock.Advance(4 * time.Minute)
+	cleanup.warnInactiveParticipants()
+
+	if got := len(s.srv.BroadcastsFor("inactivity_warning")); got != 2 {
+		t.Errorf("inactivity warnings = %d, want 2", got)
+	}
+}
+
+func TestInactivityWarningDisabled(t *testing.T) {
+	s := newTestServices(t)
+	cleanup := newTestCleanup(s, 5*time.Minute, 10*time.Minute, 0)
+	seedParticipant(s.srv, "room1", models.RoleParticipant, s.clock.Now().Add(-4*time.Minute))
+
+	cleanup.warnInactiveParticipants()
+
+	if got := len(s.srv.BroadcastsFor("inactivity_warning")); got != 0 {
+		t.Errorf("inactivity warnings = %d, want 0", got)
+	}
+}
+
+// seedOldRoom stores an empty room created long enough ago to be past the
+// orphan grace period.
+func seedOldRoom(s *testServices, id string) {
+	created := s.clock.Now().Add(-time.Hour)
+	s.srv.AddRoom(models.Room{ID: id, Name: "Room " + id, CreatedAt: created, LastActiveAt: s.clock.Now(), Persist: true, KeyVersion: 1})
+}
+
+func TestCleanupOrphanRoomsDeletesOnlyEmptyRooms(t *testing.T) {
+	s := newTestServices(t)
+	cleanup := newTestCleanup(s, 5*time.Minute, 10*time.Minute, 0)
+
+	seedOldRoom(s, "empty1")
+	seedOldRoom(s, "empty2")
+	seedOldRoom(s, "busy")
+	seedParticipant(s.srv, "busy", models.RoleParticipant, s.clock.Now())
+	s.seedRoom("fresh") // empty, but inside the grace period
+	s.messages.PostSystemMessage("empty1", "hello")
+
+	deleted, err := cleanup.CleanupOrphanRooms()
+	if err != nil {
+		t.Fatalf("CleanupOrphanRooms: %v", err)
+	}
+	if deleted != 2 {
+		t.Errorf("deleted = %d, want 2", deleted)
+	}
+
+	var remaining []string
+	for _, room := range s.srv.Rooms() {
+		remaining = append(remaining, room.ID)
+	}
+	slices.Sort(remaining)
+	if !slices.Equal(remaining, []string{"busy", "fresh"}) {
+		t.Errorf("remaining rooms = %v, want [busy fresh]", remaining)
+	}
+	if got := s.messages.GetMessageCount("empty1", 0); got != 0 {
+		t.Errorf("messages left in deleted room = %d, want 0", got)
+	}
+	if got := len(s.srv.BroadcastsFor("room")); got != 2 {
+		t.Errorf("room deleted broadcasts = %d, want 2", got)
+	}
+}
+
+func TestCleanupOrphanRoomsSparesRoomJoinedDuringScan(t *testing.T) {
+	s := newTestServices(t)
+	cleanup := newTestCleanup(s, 5*time.Minute, 10*time.Minute, 0)
+
+	seedOldRoom(s, "racy")
+	seedOldRoom(s, "busy")
+	seedParticipant(s.srv, "busy", models.RoleParticipant, s.clock.Now())
+
+	// Someone joins after the room list was read but before the delete
+	s.srv.OnRequest = func(req supabasetest.Request) {
+		if req.Path == "rpc/delete_room_if_empty" {
+			s.srv.OnRequest = nil
+			seedParticipant(s.srv, "racy", models.RoleParticipant, s.clock.Now())
+		}
+	}
+
+	deleted, err := cleanup.CleanupOrphanRooms()
+	if err != nil {
+		t.Fatalf("CleanupOrphanRooms: %v", err)
+	}
+	if deleted != 0 {
+		t.Errorf("deleted = %d, want 0", deleted)
+	}
+	if _, ok := s.srv.Room("racy"); !ok {
+		t.Error("room joined during the scan was deleted")
+	}
+	if got := len(s.srv.BroadcastsFor("room")); got != 0 {
+		t.Errorf("room deleted broadcasts = %d, want 0", got)
+	}
+}
+
+func TestCleanupOrphanRoomsPagesThroughRooms(t *testing.T) {
+	s := newTestServices(t)
+	cleanup := newTestCleanup(s, 5*time.Minute, 10*time.Minute, 0)
+
+	for i := 0; i < orphanScanPageSize+3; i++ {
+		seedOldRoom(s, fmt.Sprintf("room%04d", i))
+	}
+
+	deleted, err := cleanup.CleanupOrphanRooms()
+	if err != nil {
+		t.Fatalf("CleanupOrphanRooms: %v", err)
+	}
+	if deleted != orphanScanPageSize+3 {
+		t.Errorf("deleted = %d, want %d", deleted, orphanScanPageSize+3)
+	}
+	if got := s.srv.CountRequests("GET", "rooms"); got != 2 {
+		t.Errorf("room list requests = %d, want 2 pages", got)
+	}
+}
+
+// seedRoomActiveAt stores a room last active at the given time.
+func seedRoomActiveAt(s *testServices, id string, lastActive time.Time) {
+	s.srv.AddRoom(models.Room{ID: id, Name: "Room " + id, CreatedAt: lastActive, LastActiveAt: lastActive, Persist: true, KeyVersion: 1})
+}
+
+func TestParticipantsTimeOutBeforeRooms(t *testing.T) {
+	s := newTestServices(t)
+	cleanup := newTestCleanup(s, 2*time.Minute, 10*time.Minute, 0)
+	now := s.clock.Now()
+
+	seedRoomActiveAt(s, "room1", now.Add(-3*time.Minute))
+	host := seedParticipant(s.srv, "room1", models.RoleParticipant, now.Add(-30*time.Second))
+	idle := seedParticipant(s.srv, "room1", models.RoleParticipant, now.Add(-3*time.Minute))
+	seedRoomActiveAt(s, "quiet", now.Add(-5*time.Minute))
+	seedRoomActiveAt(s, "stale", now.Add(-11*time.Minute))
+
+	cleanup.cleanup()
+
+	if _, ok := s.srv.Participant(idle.ID); ok {
+		t.Error("participant idle past the participant timeout was not removed")
+	}
+	if _, ok := s.srv.Participant(host.ID); !ok {
+		t.Error("active participant was removed")
+	}
+	if _, ok := s.srv.Room("room1"); !ok {
+		t.Error("room idle for 3 minutes was deleted before the room timeout")
+	}
+	if _, ok := s.srv.Room("quiet"); !ok {
+		t.Error("room idle for 5 minutes was deleted before the room timeout")
+	}
+	if _, ok := s.srv.Room("stale"); ok {
+		t.Error("room idle past the room timeout was not deleted")
+	}
+
+	// Once the room timeout passes, the quiet room goes too
+	s.clock.Advance(6 * time.Minute)
+	cleanup.cleanup()
+	if _, ok := s.srv.Room("quiet"); ok {
+		t.Error("room still exists after the room timeout")
+	}
+}
+
+func TestCleanupBatchesLeaveBroadcastsPerRoom(t *testing.T) {
+	s := newTestServices(t)
+	cleanup := newTestCleanup(s, 2*time.Minute, time.Hour, 0)
+	now := s.clock.Now()
+
+	seedRoomActiveAt(s, "room1", now)
+	seedParticipant(s.srv, "room1", models.RoleParticipant, now)
+	for i := 0; i < 5; i++ {
+		seedParticipant(s.srv, "room1", models.RoleParticipant, now.Add(-5*time.Minute))
+	}
+	seedRoomActiveAt(s, "room2", now)
+	seedParticipant(s.srv, "room2", models.RoleParticipant, now)
+	for i := 0; i < 2; i++ {
+		seedParticipant(s.srv, "room2", models.RoleParticipant, now.Add(-5*time.Minute))
+	}
+
+	cleanup.cleanupParticipants(now.Add(-2 * time.Minute))
+
+	if got := s.srv.BroadcastCalls(); got != 2 {
+		t.Errorf("broadcast requests = %d, want one per room", got)
+	}
+	leaves := map[string]int{}
+	for _, b := range s.srv.BroadcastsFor("participant") {
+		leaves[b.Topic]++
+	}
+	if leaves["room:room1"] != 5 || leaves["room:room2"] != 2 {
+		t.Errorf("leave events per topic = %v, want 5 in room1 and 2 in room2", leaves)
+	}
+}
+
+func TestCleanupBroadcastsLeavesWithoutRefetchingParticipants(t *testing.T) {
+	s := newTestServices(t)
+	cleanup := newTestCleanup(s, 2*time.Minute, time.Hour, 0)
+	now := s.clock.Now()
+
+	seedRoomActiveAt(s, "room1", now)
+	seedParticipant(s.srv, "room1", models.RoleParticipant, now)
+	var stale []string
+	for i := 0; i < 3; i++ {
+		stale = append(stale, seedParticipant(s.srv, "room1", models.RoleParticipant, now.Add(-5*time.Minute)).ID)
+	}
+	s.srv.ResetRequests()
+
+	cleanup.cleanupParticipants(now.Add(-2 * time.Minute))
+
+	// Only the inactive-participant query; the leave broadcasts reuse its rows
+	if got := s.srv.CountRequests("GET", "participants"); got != 1 {
+		t.Errorf("participant reads = %d, want 1", got)
+	}
+	var left []string
+	for _, b := range s.srv.BroadcastsFor("participant") {
+		var payload struct {
+			Action      string             `json:"action"`
+			Participant models.Participant `json:"participant"`
+		}
+		if err := json.Unmarshal(b.Payload, &payload); err != nil {
+			t.Fatal(err)
+		}
+		if payload.Action != "leave" || payload.Participant.Username != "user-participant" {
+			t.Errorf("broadcast payload = %+v, want a leave with the loaded participant", payload)
+		}
+		left = append(left, payload.Participant.ID)
+	}
+	slices.Sort(stale)
+	slices.Sort(left)
+	if !slices.Equal(left, stale) {
+		t.Errorf("leave broadcasts for %v, want %v", left, stale)
+	}
+}
+
+func TestCleanupLivenessReportsStalledWorker(t *testing.T) {
+	s := newTestServices(t)
+	cleanup := newTestCleanup(s, 2*time.Minute, time.Hour, 0)
+
+	if err := cleanup.CheckLiveness(); err != nil {
+		t.Errorf("right after startup: %v, want healthy", err)
+	}
+
+	// Up to three missed intervals are tolerated
+	s.clock.Advance(3 * time.Minute)
+	if err := cleanup.CheckLiveness(); err != nil {
+		t.Errorf("after three intervals: %v, want healthy", err)
+	}
+
+	s.clock.Advance(time.Second)
+	if err := cleanup.CheckLiveness(); err == nil {
+		t.Error("stalled past three intervals: want an error")
+	}
+
+	cleanup.cleanup()
+	if err := cleanup.CheckLiveness(); err != nil {
+		t.Errorf("after a completed run: %v, want healthy", err)
+	}
+}
+
+func TestCleanupPurgesDeletedRoomMessages(t *testing.T) {
+	s := newTestServices(t)
+	cleanup := newTestCleanup(s, 2*time.Minute, 10*time.Minute, 0)
+
+	p := seedParticipant(s.srv, "stale", models.RoleParticipant, s.clock.Now())
+	if _, err := s.messages.SendMessage("stale", models.SendMessageRequest{ParticipantID: p.ID, Content: "bye"}); err != nil {
+		t.Fatal(err)
+	}
+	s.srv.DeleteParticipant(p.ID)
+	s.clock.Advance(11 * time.Minute)
+
+	cleanup.cleanup()
+
+	if _, ok := s.srv.Room("stale"); ok {
+		t.Fatal("stale room was not deleted")
+	}
+	if got := s.messages.GetMessages("stale", MessageFilter{}); len(got) != 0 {
+		t.Errorf("messages of the deleted room = %d, want purged", len(got))
+	}
+}
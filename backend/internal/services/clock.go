@@ -0,0 +1,18 @@
+package services
+
+import "time"
+
+// Clock is the source of the current time for services, so time-dependent
+// behavior (ordering, TTLs, cleanup) can be driven deterministically or by an
+// offset-corrected time source instead of the local wall clock.
+type Clock interface {
+	Now() time.Time
+}
+
+// RealClock is a Clock backed by time.Now.
+type RealClock struct{}
+
+// Now returns the current local time.
+func (RealClock) Now() time.Time {
+	return time.Now()
+}
@@ -0,0 +1,75 @@
+package services
+
+import "errors"
+
+// Sentinel errors returned by services so handlers can map them to HTTP status codes.
+var (
+	// ErrRoomNotFound is returned when the requested room does not exist
+	ErrRoomNotFound = errors.New("room not found")
+
+	// ErrParticipantNotFound is returned when the requested participant does not exist
+	ErrParticipantNotFound = errors.New("participant not found")
+
+	// ErrInvalidCredential is returned when a request doesn't carry the participant's secret
+	ErrInvalidCredential = errors.New("missing or invalid participant credential")
+
+	// ErrNotHost is returned when a host-only action is attempted by someone else
+	ErrNotHost = errors.New("only the room host can perform this action")
+
+	// ErrInvalidAvatar is returned when a participant picks an avatar outside the allowlist
+	ErrInvalidAvatar = errors.New("invalid avatar")
+
+	// ErrMessageNotFound is returned when the requested message does not exist
+	ErrMessageNotFound = errors.New("message not found")
+
+	// ErrInvalidMessage is returned when a message fails validation
+	ErrInvalidMessage = errors.New("invalid message")
+
+	// ErrRoomNameTaken is returned when a slug room ID derived from a name already exists
+	ErrRoomNameTaken = errors.New("room name is already taken")
+
+	// ErrRoomIDTaken is returned when a caller-requested room ID already exists
+	ErrRoomIDTaken = errors.New("room ID is already taken")
+
+	// ErrReservedRoomName is returned when a room would be created with a reserved ID or name
+	ErrReservedRoomName = errors.New("room name is reserved")
+
+	// ErrInvalidRoomID is returned when a caller-requested room ID has an invalid format
+	ErrInvalidRoomID = errors.New("room ID must be lowercase letters and numbers separated by single hyphens, at most 48 characters")
+
+	// ErrInvalidRoomSlug is returned when a room name can't be turned into a valid slug ID
+	ErrInvalidRoomSlug = errors.New("room name must contain only letters, numbers, spaces and hyphens")
+
+	// ErrInvalidRole is returned when a participant joins with an unknown role
+	ErrInvalidRole = errors.New("role must be 'participant' or 'observer'")
+
+	// ErrInvalidSignature is returned when a room requires signed messages and the signature doesn't verify
+	ErrInvalidSignature = errors.New("invalid message signature")
+
+	// ErrForbidden is returned when a participant's role doesn't allow the action
+	ErrForbidden = errors.New("action not allowed for this participant")
+
+	// ErrSendDenied is returned when the SendAuthorizer refuses a message
+	ErrSendDenied = errors.New("sending is not allowed")
+
+	// ErrInvalidStatus is returned when a participant status is too long
+	ErrInvalidStatus = errors.New("invalid status")
+
+	// ErrInvalidUsername is returned when a participant renames themselves to a blank username
+	ErrInvalidUsername = errors.New("username is required")
+
+	// ErrKeyRotationConflict is returned when the room's key was rotated concurrently
+	ErrKeyRotationConflict = errors.New("room key was rotated concurrently, please retry")
+
+	// ErrTooManyJoins is returned when a client IP already holds the maximum participants in a room
+	ErrTooManyJoins = errors.New("too many participants from this address in the room")
+
+	// ErrInvalidSignal is returned when a participant sends an unknown signal type
+	ErrInvalidSignal = errors.New("invalid signal")
+
+	// ErrInvalidHostTarget is returned when the host role is transferred to someone who can't hold it
+	ErrInvalidHostTarget = errors.New("new host must be a participant in the room")
+
+	// ErrRoomLocked is returned when a new participant tries to join a locked room
+	ErrRoomLocked = errors.New("room is locked")
+)
@@ -0,0 +1,117 @@
+package services
+
+import (
+	"crypto/rand"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/adi-253/Talkie/backend/internal/models"
+	"github.com/adi-253/Talkie/backend/internal/supabase"
+	"github.com/adi-253/Talkie/backend/internal/supabase/supabasetest"
+	"github.com/google/uuid"
+)
+
+// testSigningKey is the MessageSigner key used by test services.
+const testSigningKey = "test-signing-key"
+
+// fakeClock is a Clock that only moves when advanced.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// newFakeClock starts at the current time, since some database writes
+// (e.g. heartbeats) are stamped with the wall clock.
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Now().UTC().Truncate(time.Second)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// newTestDB starts a fake Supabase project and a client for it.
+func newTestDB(t *testing.T) (*supabasetest.Server, *supabase.Client) {
+	t.Helper()
+	srv := supabasetest.NewServer(t)
+	return srv, supabase.NewClient(srv.Config())
+}
+
+// newTestMessageService creates a MessageService with the test signing key.
+func newTestMessageService(db *supabase.Client, limits MessageLimits, retention MessageRetention, clock Clock) *MessageService {
+	return NewMessageService(db, limits, retention, clock, NewMessageSigner([]byte(testSigningKey)), nil)
+}
+
+// seedParticipant stores a participant with the given role in roomID,
+// creating the room if needed.
+func seedParticipant(srv *supabasetest.Server, roomID, role string, joinedAt time.Time) models.Participant {
+	if _, ok := srv.Room(roomID); !ok {
+		srv.AddRoom(models.Room{ID: roomID, Name: "Test Room", CreatedAt: joinedAt, LastActiveAt: joinedAt, Persist: true, KeyVersion: 1})
+	}
+	p := models.Participant{
+		ID:           uuid.New().String(),
+		RoomID:       roomID,
+		Username:     "user-" + role,
+		Avatar:       "avatar1",
+		JoinedAt:     joinedAt,
+		LastActiveAt: joinedAt,
+		Role:         role,
+	}
+	srv.AddParticipant(p)
+	return p
+}
+
+// testServices wires message and room services to a fake Supabase project.
+type testServices struct {
+	srv      *supabasetest.Server
+	db       *supabase.Client
+	clock    *fakeClock
+	messages *MessageService
+	rooms    *RoomService
+}
+
+// newTestServices creates services with default settings; settings may be
+// adjusted with the optional configure callback before the services are built.
+func newTestServices(t *testing.T, configure ...func(*RoomSettings)) *testServices {
+	t.Helper()
+	srv, db := newTestDB(t)
+	clock := newFakeClock()
+	messages := newTestMessageService(db, MessageLimits{}, MessageRetention{}, clock)
+	settings := RoomSettings{
+		Avatars:       []string{"avatar1", "avatar2"},
+		DefaultAvatar: "avatar1",
+		RoomIDBytes:   4,
+	}
+	for _, fn := range configure {
+		fn(&settings)
+	}
+	rooms := NewRoomService(db, messages, nil, settings, clock, rand.Reader)
+	return &testServices{srv: srv, db: db, clock: clock, messages: messages, rooms: rooms}
+}
+
+// seedRoom stores a room directly in the fake database.
+func (s *testServices) seedRoom(id string) models.Room {
+	now := s.clock.Now()
+	room := models.Room{ID: id, Name: "Test Room", CreatedAt: now, LastActiveAt: now, Persist: true, KeyVersion: 1}
+	s.srv.AddRoom(room)
+	return room
+}
+
+// join adds a new participant through RoomService.
+func (s *testServices) join(t *testing.T, roomID, username string) *models.Participant {
+	t.Helper()
+	p, _, _, err := s.rooms.JoinRoom(roomID, username, "", "", "", "", "")
+	if err != nil {
+		t.Fatalf("JoinRoom(%s): %v", username, err)
+	}
+	return p
+}
@@ -0,0 +1,110 @@
+package services
+
+import (
+	"sync"
+
+	"github.com/adi-253/Talkie/backend/internal/models"
+)
+
+// ipJoinTracker remembers which client IP created each participant, per room,
+// to cap how many participants one IP may hold in a room. Counts are released
+// lazily: participants no longer in the room are pruned on the next reserve,
+// so leaves and cleanup on any code path free up the IP's slots.
+//
+// A reservation stays pending until its participant insert returns (confirm),
+// and is only pruned if it was confirmed before the participant list it is
+// checked against was fetched (snapshot). Otherwise a concurrent join working
+// from an older list could prune it and slip past the cap.
+type ipJoinTracker struct {
+	mu sync.Mutex
+	// joins stores reservations per room and IP: roomID -> IP -> participantID -> confirm seq (0 while pending)
+	joins map[string]map[string]map[string]uint64
+	// seq orders confirmations against snapshots
+	seq uint64
+}
+
+func newIPJoinTracker() *ipJoinTracker {
+	return &ipJoinTracker{joins: make(map[string]map[string]map[string]uint64)}
+}
+
+// snapshot marks the point before a participant list is fetched for reserve.
+func (t *ipJoinTracker) snapshot() uint64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.seq++
+	return t.seq
+}
+
+// reserve records participantID as a pending join from ip unless the IP
+// already holds limit reservations in the room. present is the room's
+// participant list fetched after snapshot. Returns false if the cap is reached.
+func (t *ipJoinTracker) reserve(roomID, ip, participantID string, present []models.Participant, snapshot uint64, limit int) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	roomJoins := t.joins[roomID]
+	if roomJoins == nil {
+		roomJoins = make(map[string]map[string]uint64)
+		t.joins[roomID] = roomJoins
+	}
+	ipJoins := roomJoins[ip]
+	if ipJoins == nil {
+		ipJoins = make(map[string]uint64)
+		roomJoins[ip] = ipJoins
+	}
+
+	isPresent := make(map[string]bool, len(present))
+	for _, p := range present {
+		isPresent[p.ID] = true
+	}
+	for id, confirmed := range ipJoins {
+		// Pending or newer than the list: present may simply not show it yet
+		if confirmed == 0 || confirmed > snapshot {
+			continue
+		}
+		if !isPresent[id] {
+			delete(ipJoins, id)
+		}
+	}
+
+	if len(ipJoins) >= limit {
+		return false
+	}
+	ipJoins[participantID] = 0
+	return true
+}
+
+// confirm marks a reservation's participant as stored, so it may be pruned
+// once it no longer appears in the room.
+func (t *ipJoinTracker) confirm(roomID, ip, participantID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if ipJoins := t.joins[roomID][ip]; ipJoins != nil {
+		if _, ok := ipJoins[participantID]; ok {
+			t.seq++
+			ipJoins[participantID] = t.seq
+		}
+	}
+}
+
+// release forgets a reservation, e.g. when the join failed after reserve.
+func (t *ipJoinTracker) release(roomID, ip, participantID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if ipJoins := t.joins[roomID][ip]; ipJoins != nil {
+		delete(ipJoins, participantID)
+		if len(ipJoins) == 0 {
+			delete(t.joins[roomID], ip)
+		}
+	}
+}
+
+// forgetRoom drops all tracking for a deleted room.
+func (t *ipJoinTracker) forgetRoom(roomID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.joins, roomID)
+}
@@ -0,0 +1,38 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/adi-253/Talkie/backend/internal/models"
+)
+
+func TestIPJoinTrackerKeepsPendingAndRecentReservations(t *testing.T) {
+	const ip = "203.0.113.1"
+	tracker := newIPJoinTracker()
+
+	// Both joins fetched an empty list before either insert landed
+	first, second := tracker.snapshot(), tracker.snapshot()
+	if !tracker.reserve("room1", ip, "a", nil, first, 2) {
+		t.Fatal("first reservation refused")
+	}
+	if !tracker.reserve("room1", ip, "b", nil, second, 2) {
+		t.Fatal("second reservation refused")
+	}
+	if tracker.reserve("room1", ip, "c", nil, tracker.snapshot(), 2) {
+		t.Error("pending reservations were pruned, letting a third join past the cap")
+	}
+
+	// A reservation confirmed after a list was fetched isn't pruned by it
+	stale := tracker.snapshot()
+	tracker.confirm("room1", ip, "a")
+	tracker.confirm("room1", ip, "b")
+	if tracker.reserve("room1", ip, "c", nil, stale, 2) {
+		t.Error("reservations confirmed after the snapshot were pruned against an older list")
+	}
+
+	// Confirmed before the list was fetched and missing from it: the participant left
+	present := []models.Participant{{ID: "b"}}
+	if !tracker.reserve("room1", ip, "c", present, tracker.snapshot(), 2) {
+		t.Error("a participant who left still holds a slot")
+	}
+}
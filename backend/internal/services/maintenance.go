@@ -0,0 +1,102 @@
+package services
+
+import (
+	"log"
+	"math/rand/v2"
+	"sync/atomic"
+	"time"
+
+	"github.com/adi-253/Talkie/backend/internal/supabase"
+)
+
+// MaintenanceService tracks whether the server is draining for a deploy.
+// While enabled, new sessions are refused and connected clients are advised
+// to reconnect after a jittered backoff, avoiding a thundering reconnect herd.
+type MaintenanceService struct {
+	db         *supabase.Client
+	maxBackoff time.Duration
+	enabled    atomic.Bool
+}
+
+// NewMaintenanceService creates a new maintenance service.
+// - maxBackoff: upper bound of the reconnect backoff hint sent to clients
+func NewMaintenanceService(db *supabase.Client, maxBackoff time.Duration) *MaintenanceService {
+	return &MaintenanceService{
+		db:         db,
+		maxBackoff: maxBackoff,
+	}
+}
+
+// Enabled reports whether maintenance mode is on.
+func (s *MaintenanceService) Enabled() bool {
+	return s.enabled.Load()
+}
+
+// MaxBackoff returns the upper bound of the reconnect backoff hint.
+func (s *MaintenanceService) MaxBackoff() time.Duration {
+	return s.maxBackoff
+}
+
+// Enable turns on maintenance mode and broadcasts a reconnect advisory to every
+// active room. Each room gets its own random backoff so clients spread out.
+func (s *MaintenanceService) Enable() error {
+	if s.enabled.Swap(true) {
+		return nil
+	}
+	log.Println("Maintenance mode enabled")
+
+	rooms, err := s.db.ListRooms()
+	if err != nil {
+		return err
+	}
+
+	for _, room := range rooms {
+		if err := s.db.BroadcastReconnectAdvisory(room.ID, s.jitteredBackoff()); err != nil {
+			log.Printf("Failed to broadcast reconnect advisory for %s: %v", room.ID, err)
+		}
+	}
+	return nil
+}
+
+// Shutdown refuses new sessions and broadcasts a server_shutdown advisory to
+// every active room and the lobby. Called before the HTTP server is closed.
+func (s *MaintenanceService) Shutdown() error {
+	s.enabled.Store(true)
+	log.Println("Broadcasting shutdown advisories")
+
+	rooms, err := s.db.ListRooms()
+	if err != nil {
+		return err
+	}
+
+	for _, room := range rooms {
+		if err := s.db.BroadcastServerShutdown(room.ID, s.jitteredBackoff()); err != nil {
+			log.Printf("Failed to broadcast shutdown advisory for %s: %v", room.ID, err)
+		}
+	}
+	return s.db.BroadcastLobbyShutdown()
+}
+
+// Disable turns off maintenance mode so new sessions are accepted again.
+func (s *MaintenanceService) Disable() {
+	if s.enabled.Swap(false) {
+		log.Println("Maintenance mode disabled")
+	}
+}
+
+// Toggle flips maintenance mode, e.g. in response to SIGUSR1.
+func (s *MaintenanceService) Toggle() error {
+	if s.Enabled() {
+		s.Disable()
+		return nil
+	}
+	return s.Enable()
+}
+
+// jitteredBackoff returns a random duration in [0, maxBackoff).
+func (s *MaintenanceService) jitteredBackoff() time.Duration {
+	if s.maxBackoff <= 0 {
+		return 0
+	}
+	return rand.N(s.maxBackoff)
+}
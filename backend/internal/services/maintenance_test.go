@@ -0,0 +1,50 @@
+package services
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestShutdownBroadcastsAdvisories(t *testing.T) {
+	s := newTestServices(t)
+	s.seedRoom("room1")
+	s.seedRoom("room2")
+	maintenance := NewMaintenanceService(s.db, 10*time.Second)
+
+	if err := maintenance.Shutdown(); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+
+	var topics []string
+	for _, b := range s.srv.BroadcastsFor("server_shutdown") {
+		topics = append(topics, b.Topic)
+	}
+	slices.Sort(topics)
+	if want := []string{"room:room1", "room:room2", "rooms:lobby"}; !slices.Equal(topics, want) {
+		t.Errorf("server_shutdown topics = %v, want %v", topics, want)
+	}
+	if !maintenance.Enabled() {
+		t.Error("new sessions are still accepted after shutdown")
+	}
+}
+
+func TestEnableBroadcastsReconnectOnce(t *testing.T) {
+	s := newTestServices(t)
+	s.seedRoom("room1")
+	maintenance := NewMaintenanceService(s.db, 10*time.Second)
+
+	if err := maintenance.Enable(); err != nil {
+		t.Fatalf("Enable: %v", err)
+	}
+	if err := maintenance.Enable(); err != nil {
+		t.Fatalf("second Enable: %v", err)
+	}
+	if got := len(s.srv.BroadcastsFor("reconnect")); got != 1 {
+		t.Errorf("reconnect advisories = %d, want 1", got)
+	}
+
+	if err := maintenance.Toggle(); err != nil || maintenance.Enabled() {
+		t.Errorf("Toggle while enabled: err = %v, enabled = %v, want disabled", err, maintenance.Enabled())
+	}
+}
@@ -1,56 +1,601 @@
 package services
 
 import (
+	"encoding/base64"
+	"errors"
+	"fmt"
 	"log"
+	"math"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/adi-253/Talkie/backend/internal/models"
+	"github.com/adi-253/Talkie/backend/internal/supabase"
 	"github.com/google/uuid"
 )
 
 // MessageService handles message storage and retrieval.
 // Uses in-memory storage since messages are ephemeral.
-// Messages are automatically cleaned up when their room is deleted.
+// Messages are automatically cleaned up when their room is deleted,
+// and rooms may additionally expire old messages via a per-room TTL.
 type MessageService struct {
+	db *supabase.Client
+
 	// messages stores messages per room: roomID -> []Message
 	messages map[string][]Message
-	mu       sync.RWMutex
+	// ttls stores the message TTL per room: roomID -> TTL (absent means no expiry)
+	ttls map[string]time.Duration
+	// noPersist marks rooms whose messages are never stored: roomID -> true
+	noPersist map[string]bool
+	// seqs stores the last assigned sequence number per room: roomID -> Seq
+	seqs map[string]int64
+	// cursors stores each participant's read cursor per room: roomID -> participantID -> Seq
+	cursors map[string]map[string]int64
+	// signed marks rooms that only accept signed messages: roomID -> true
+	signed map[string]bool
+	// closed marks rooms being torn down, which accept no more messages: roomID -> close time
+	closed map[string]time.Time
+	// evicted holds IDs of messages dropped by the retention max count that
+	// haven't been broadcast yet; the expiry sweeper sends them in one batch
+	// per room: roomID -> message IDs
+	evicted map[string][]string
+	mu      sync.RWMutex
+
+	limits    MessageLimits
+	retention MessageRetention
+	clock     Clock
+	signer    *MessageSigner
+	auth      SendAuthorizer
+	stopChan  chan struct{}
+}
+
+// MessageRetention bounds how many messages each room keeps in memory.
+// Whichever limit triggers first evicts the oldest messages. Zero values
+// disable the corresponding limit.
+type MessageRetention struct {
+	// MaxCount is the maximum number of messages kept per room
+	MaxCount int
+
+	// MaxAge is the maximum age of a kept message; a room's own TTL applies if shorter
+	MaxAge time.Duration
+}
+
+// MessageLimits bounds what a client may store in a message.
+// Zero values disable the corresponding limit.
+type MessageLimits struct {
+	// MaxContentLength is the maximum length of stored message content in bytes
+	MaxContentLength int
+
+	// MaxReplyPreviewLength caps ReplyTo.Content (in characters); longer previews are truncated
+	MaxReplyPreviewLength int
+
+	// MaxAttachments is the maximum number of attachments per message
+	MaxAttachments int
+
+	// MaxAttachmentSize is the maximum declared size of a single attachment in bytes
+	MaxAttachmentSize int64
 }
 
 // Message is an internal representation matching the model
 type Message = models.Message
 
-// NewMessageService creates a new MessageService instance
-func NewMessageService() *MessageService {
+// minEnvelopeLength is the smallest decoded AES-GCM envelope the client can produce:
+// a 12-byte IV followed by at least the 16-byte authentication tag.
+const minEnvelopeLength = 12 + 16
+
+// NewMessageService creates a new MessageService instance.
+// signer issues participant signing secrets and verifies signed messages.
+// auth decides who may send in each room (nil means AllowAllSendAuthorizer).
+func NewMessageService(db *supabase.Client, limits MessageLimits, retention MessageRetention, clock Clock, signer *MessageSigner, auth SendAuthorizer) *MessageService {
+	if auth == nil {
+		auth = AllowAllSendAuthorizer{}
+	}
 	return &MessageService{
-		messages: make(map[string][]Message),
+		db:        db,
+		messages:  make(map[string][]Message),
+		ttls:      make(map[string]time.Duration),
+		noPersist: make(map[string]bool),
+		seqs:      make(map[string]int64),
+		cursors:   make(map[string]map[string]int64),
+		signed:    make(map[string]bool),
+		closed:    make(map[string]time.Time),
+		evicted:   make(map[string][]string),
+		limits:    limits,
+		retention: retention,
+		clock:     clock,
+		signer:    signer,
+		auth:      auth,
+		stopChan:  make(chan struct{}),
+	}
+}
+
+// SetRoomPersist configures whether messages in a room are stored at all.
+// Messages sent to a non-persistent room are relayed by realtime only and
+// never appear in history.
+func (s *MessageService) SetRoomPersist(roomID string, persist bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if persist {
+		delete(s.noPersist, roomID)
+		return
+	}
+	s.noPersist[roomID] = true
+}
+
+// SetRoomRequireSignatures configures whether a room only accepts messages
+// signed with the sender's signing secret.
+func (s *MessageService) SetRoomRequireSignatures(roomID string, required bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if required {
+		s.signed[roomID] = true
+		return
+	}
+	delete(s.signed, roomID)
+}
+
+// SigningSecret returns the secret a participant signs their messages with.
+func (s *MessageService) SigningSecret(participantID string) string {
+	return s.signer.Secret(participantID)
+}
+
+// Authenticate reports whether secret is the signing secret issued to participantID.
+func (s *MessageService) Authenticate(participantID, secret string) bool {
+	return s.signer.Authenticate(participantID, secret)
+}
+
+// SetRoomTTL configures how long messages in a room are kept.
+// A zero TTL keeps messages until the room is deleted.
+func (s *MessageService) SetRoomTTL(roomID string, ttl time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if ttl <= 0 {
+		delete(s.ttls, roomID)
+		return
+	}
+	s.ttls[roomID] = ttl
+}
+
+// StartExpirySweeper begins the background worker that drops messages older
+// than their room's TTL or the retention max age. This is separate from
+// room-deletion cleanup.
+// A non-positive interval disables the sweeper.
+// This method runs in its own goroutine and should be called with 'go'.
+func (s *MessageService) StartExpirySweeper(interval time.Duration) {
+	if interval <= 0 {
+		log.Printf("Message expiry sweeper disabled (interval: %v)", interval)
+		return
+	}
+	log.Printf("Message expiry sweeper started (interval: %v)", interval)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.expireMessages()
+		case <-s.stopChan:
+			log.Println("Message expiry sweeper stopped")
+			return
+		}
+	}
+}
+
+// Stop shuts down the expiry sweeper.
+func (s *MessageService) Stop() {
+	close(s.stopChan)
+}
+
+// expireMessages drops messages past their room's TTL (or the retention max
+// age, whichever is shorter) and broadcasts a message_expired event per room
+// so clients remove them too, together with any pending max count evictions.
+func (s *MessageService) expireMessages() {
+	now := s.clock.Now().UTC()
+
+	s.mu.Lock()
+	expired := s.evicted
+	s.evicted = make(map[string][]string)
+	for roomID, closedAt := range s.closed {
+		if now.Sub(closedAt) > closedRoomRetention {
+			delete(s.closed, roomID)
+		}
+	}
+	for roomID, roomMessages := range s.messages {
+		ttl := s.maxAgeLocked(roomID)
+		if ttl <= 0 {
+			continue
+		}
+		cutoff := now.Add(-ttl)
+
+		// Messages are stored in send order, so expired ones form a prefix
+		n := 0
+		for n < len(roomMessages) && roomMessages[n].ServerTimestamp.Before(cutoff) {
+			expired[roomID] = append(expired[roomID], roomMessages[n].ID)
+			n++
+		}
+		if n > 0 {
+			s.messages[roomID] = append([]Message(nil), roomMessages[n:]...)
+		}
+	}
+	s.mu.Unlock()
+
+	// Broadcast outside the lock to avoid blocking senders on network calls
+	for roomID, ids := range expired {
+		s.broadcastExpired(roomID, ids)
+	}
+}
+
+// maxAgeLocked returns the effective message max age for a room: the shorter
+// of its TTL and the retention max age. Zero means messages never age out.
+// Must be called with s.mu held.
+func (s *MessageService) maxAgeLocked(roomID string) time.Duration {
+	ttl, maxAge := s.ttls[roomID], s.retention.MaxAge
+	if ttl <= 0 || (maxAge > 0 && maxAge < ttl) {
+		return maxAge
+	}
+	return ttl
+}
+
+// broadcastExpired tells clients in a room to drop the given messages.
+func (s *MessageService) broadcastExpired(roomID string, ids []string) {
+	log.Printf("[Message] Expired %d messages in room %s", len(ids), roomID)
+	if err := s.db.BroadcastMessagesExpired(roomID, ids); err != nil {
+		log.Printf("Failed to broadcast message expiry for %s: %v", roomID, err)
+	}
+}
+
+// SendMessage validates and adds a new message to a room.
+// Returns an error wrapping ErrInvalidMessage if validation fails,
+// ErrForbidden if the sender is not a participant of the room (including
+// observers and missing sender IDs), or ErrSendDenied if the SendAuthorizer
+// refuses it. Server-generated messages without a sender go through
+// PostSystemMessage instead.
+func (s *MessageService) SendMessage(roomID string, req models.SendMessageRequest) (*Message, error) {
+	if err := s.checkCanSend(roomID, req.ParticipantID); err != nil {
+		return nil, err
+	}
+	if err := s.checkSignature(roomID, req); err != nil {
+		return nil, err
+	}
+	if allowed, reason := s.auth.AuthorizeSend(roomID, req.ParticipantID); !allowed {
+		if reason == "" {
+			return nil, ErrSendDenied
+		}
+		return nil, fmt.Errorf("%w: %s", ErrSendDenied, reason)
+	}
+	if s.limits.MaxContentLength > 0 && len(req.Content) > s.limits.MaxContentLength {
+		return nil, fmt.Errorf("%w: content exceeds max length of %d bytes", ErrInvalidMessage, s.limits.MaxContentLength)
+	}
+	if req.Encrypted {
+		if err := validateEnvelope(req.Content); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.validateAttachments(req.Attachments); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Sends that passed validation while the room was closing are refused
+	if _, closed := s.closed[roomID]; closed {
+		return nil, ErrRoomNotFound
+	}
+
+	now := s.clock.Now().UTC()
+	msg := Message{
+		ID:              uuid.New().String(),
+		RoomID:          roomID,
+		ParticipantID:   req.ParticipantID,
+		Content:         req.Content,
+		Encrypted:       req.Encrypted,
+		Username:        req.Username,
+		Avatar:          req.Avatar,
+		Timestamp:       now,
+		ServerTimestamp: now,
+		ReplyTo:         s.truncateReply(req.ReplyTo),
+		Attachments:     req.Attachments,
+		Ephemeral:       req.Ephemeral,
+	}
+	if !req.Timestamp.IsZero() {
+		msg.Timestamp = req.Timestamp.UTC()
+	}
+
+	s.appendLocked(&msg)
+
+	// The sender has seen their own message
+	if req.ParticipantID != "" {
+		s.advanceCursorLocked(roomID, req.ParticipantID, msg.Seq)
+	}
+	return &msg, nil
+}
+
+// MarkRead advances a participant's read cursor to seq, then deletes ephemeral
+// messages that every current participant has read and broadcasts their
+// expiry. Participants who join later never see burned messages.
+// Returns ErrForbidden if participantID is not in the room.
+func (s *MessageService) MarkRead(roomID, participantID string, seq int64) error {
+	participants, err := s.db.GetParticipants(roomID)
+	if err != nil {
+		return fmt.Errorf("failed to get participants: %w", err)
+	}
+	if !slices.ContainsFunc(participants, func(p models.Participant) bool { return p.ID == participantID }) {
+		return ErrForbidden
+	}
+
+	s.mu.Lock()
+	s.advanceCursorLocked(roomID, participantID, seq)
+	burned := s.burnReadLocked(roomID, participants)
+	s.mu.Unlock()
+
+	if len(burned) > 0 {
+		s.broadcastExpired(roomID, burned)
+	}
+	return nil
+}
+
+// advanceCursorLocked moves a participant's read cursor forward (never back).
+// Must be called with s.mu held for writing.
+func (s *MessageService) advanceCursorLocked(roomID, participantID string, seq int64) {
+	roomCursors := s.cursors[roomID]
+	if roomCursors == nil {
+		roomCursors = make(map[string]int64)
+		s.cursors[roomID] = roomCursors
+	}
+	if seq > roomCursors[participantID] {
+		roomCursors[participantID] = seq
+	}
+}
+
+// burnReadLocked removes ephemeral messages read by all of the given
+// participants and returns their IDs. Cursors of participants no longer
+// present are dropped. Must be called with s.mu held for writing.
+func (s *MessageService) burnReadLocked(roomID string, participants []models.Participant) []string {
+	if len(participants) == 0 {
+		return nil
+	}
+
+	roomCursors := s.cursors[roomID]
+	present := make(map[string]bool, len(participants))
+	readUpTo := int64(math.MaxInt64)
+	for _, p := range participants {
+		present[p.ID] = true
+		readUpTo = min(readUpTo, roomCursors[p.ID])
+	}
+	for id := range roomCursors {
+		if !present[id] {
+			delete(roomCursors, id)
+		}
+	}
+
+	var burned []string
+	kept := s.messages[roomID][:0]
+	for _, msg := range s.messages[roomID] {
+		if msg.Ephemeral && msg.Seq <= readUpTo {
+			burned = append(burned, msg.ID)
+			continue
+		}
+		kept = append(kept, msg)
+	}
+	if len(burned) > 0 {
+		s.messages[roomID] = kept
 	}
+	return burned
 }
 
-// SendMessage adds a new message to a room
-func (s *MessageService) SendMessage(roomID string, req models.SendMessageRequest) *Message {
+// checkCanSend rejects senders that aren't a participant of the room with
+// the participant role. There is no anonymous send path: every user message
+// must name an existing sender.
+func (s *MessageService) checkCanSend(roomID, participantID string) error {
+	if participantID == "" {
+		return ErrForbidden
+	}
+
+	participant, err := s.db.GetParticipant(participantID)
+	if err != nil {
+		if errors.Is(err, supabase.ErrNotFound) {
+			return ErrForbidden
+		}
+		return fmt.Errorf("failed to get participant: %w", err)
+	}
+
+	if participant.RoomID != roomID || participant.Role != models.RoleParticipant {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// checkSignature verifies the message signature if the room requires one, so
+// a client can't post as another participant by sending their ID.
+func (s *MessageService) checkSignature(roomID string, req models.SendMessageRequest) error {
+	s.mu.RLock()
+	required := s.signed[roomID]
+	s.mu.RUnlock()
+
+	if !required {
+		return nil
+	}
+	if req.ParticipantID == "" || !s.signer.Verify(roomID, req.ParticipantID, req.Content, req.Signature) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// PostSystemMessage stores a plaintext server-generated message (e.g. an announcement).
+// Unlike user messages, system messages are not encrypted and can be searched.
+func (s *MessageService) PostSystemMessage(roomID, content string) *Message {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	now := s.clock.Now().UTC()
 	msg := Message{
-		ID:            uuid.New().String(),
-		RoomID:        roomID,
-		ParticipantID: req.ParticipantID,
-		Content:       req.Content,
-		Username:      req.Username,
-		Avatar:        req.Avatar,
-		Timestamp:     time.Now().UTC(),
-		ReplyTo:       req.ReplyTo,
+		ID:              uuid.New().String(),
+		RoomID:          roomID,
+		System:          true,
+		Content:         content,
+		Timestamp:       now,
+		ServerTimestamp: now,
 	}
 
-	s.messages[roomID] = append(s.messages[roomID], msg)
+	s.appendLocked(&msg)
 	return &msg
 }
 
-// GetMessages returns all messages for a room after a given timestamp
-// If afterTime is zero, returns all messages
-func (s *MessageService) GetMessages(roomID string, afterTime time.Time) []Message {
+// appendLocked assigns the next sequence number and stores the message,
+// evicting the oldest messages if the room is over the retention max count.
+// Messages in non-persistent rooms are numbered but not stored, and messages
+// in closed rooms are dropped.
+// Must be called with s.mu held for writing.
+func (s *MessageService) appendLocked(msg *Message) {
+	if _, closed := s.closed[msg.RoomID]; closed {
+		return
+	}
+	s.seqs[msg.RoomID]++
+	msg.Seq = s.seqs[msg.RoomID]
+	if s.noPersist[msg.RoomID] {
+		return
+	}
+	roomMessages := append(s.messages[msg.RoomID], *msg)
+
+	if over := len(roomMessages) - s.retention.MaxCount; s.retention.MaxCount > 0 && over > 0 {
+		ids := make([]string, over)
+		for i := range ids {
+			ids[i] = roomMessages[i].ID
+		}
+		roomMessages = append([]Message(nil), roomMessages[over:]...)
+
+		// Broadcast in batches from the sweeper rather than once per send
+		s.evicted[msg.RoomID] = append(s.evicted[msg.RoomID], ids...)
+	}
+	s.messages[msg.RoomID] = roomMessages
+}
+
+// SearchSystemMessages returns the plaintext system messages in a room whose
+// content contains query (case-insensitive). Encrypted user messages are never
+// searched since the server cannot read them.
+func (s *MessageService) SearchSystemMessages(roomID, query string) []Message {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	query = strings.ToLower(query)
+	matches := []Message{}
+	for _, msg := range s.messages[roomID] {
+		if msg.System && strings.Contains(strings.ToLower(msg.Content), query) {
+			matches = append(matches, msg)
+		}
+	}
+	return matches
+}
+
+// validateEnvelope rejects obviously corrupt ciphertext so a malicious client
+// can't relay payloads that crash peers on decrypt. The server cannot decrypt,
+// so it only checks the envelope is valid base64 of a plausible length.
+func validateEnvelope(content string) error {
+	decoded, err := base64.StdEncoding.DecodeString(content)
+	if err != nil {
+		return fmt.Errorf("%w: encrypted content is not valid base64", ErrInvalidMessage)
+	}
+	if len(decoded) < minEnvelopeLength {
+		return fmt.Errorf("%w: encrypted content is too short (min %d bytes)", ErrInvalidMessage, minEnvelopeLength)
+	}
+	return nil
+}
+
+// validateAttachments checks attachment metadata against the configured limits.
+// Only metadata is validated; the server never fetches the referenced files.
+func (s *MessageService) validateAttachments(attachments []models.Attachment) error {
+	if s.limits.MaxAttachments > 0 && len(attachments) > s.limits.MaxAttachments {
+		return fmt.Errorf("%w: too many attachments (max %d)", ErrInvalidMessage, s.limits.MaxAttachments)
+	}
+
+	for _, a := range attachments {
+		if a.Name == "" || a.URL == "" {
+			return fmt.Errorf("%w: attachment name and url are required", ErrInvalidMessage)
+		}
+		if u, err := url.Parse(a.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return fmt.Errorf("%w: attachment url must be http or https", ErrInvalidMessage)
+		}
+		if a.Size < 0 || (s.limits.MaxAttachmentSize > 0 && a.Size > s.limits.MaxAttachmentSize) {
+			return fmt.Errorf("%w: attachment %q exceeds max size of %d bytes", ErrInvalidMessage, a.Name, s.limits.MaxAttachmentSize)
+		}
+	}
+
+	return nil
+}
+
+// truncateReply returns a copy of the reply context with its preview content
+// capped to the configured maximum length, so clients can't store the full
+// parent message in every reply.
+func (s *MessageService) truncateReply(reply *models.ReplyContext) *models.ReplyContext {
+	if reply == nil {
+		return nil
+	}
+
+	truncated := *reply
+	if limit := s.limits.MaxReplyPreviewLength; limit > 0 {
+		runes := []rune(truncated.Content)
+		if len(runes) > limit {
+			truncated.Content = string(runes[:limit])
+		}
+	}
+	return &truncated
+}
+
+// MessageFilter narrows the messages returned by GetMessages.
+// Zero-valued fields do not filter.
+type MessageFilter struct {
+	// After only includes messages stored after this server time
+	After time.Time
+
+	// ParticipantID only includes messages from this sender
+	ParticipantID string
+
+	// Descending returns newest messages first instead of in send order
+	Descending bool
+
+	// Limit caps how many messages are returned (0 means no limit). It applies
+	// after the other filters and the order, so ascending returns the oldest
+	// matching messages and descending the newest.
+	Limit int
+}
+
+// matches reports whether a message passes the filter.
+func (f MessageFilter) matches(msg *Message) bool {
+	if !f.After.IsZero() && !msg.ServerTimestamp.After(f.After) {
+		return false
+	}
+	if f.ParticipantID != "" && msg.ParticipantID != f.ParticipantID {
+		return false
+	}
+	return true
+}
+
+// GetMessage returns a single stored message from a room.
+// Returns ErrMessageNotFound if it doesn't exist (or has expired).
+func (s *MessageService) GetMessage(roomID, messageID string) (*Message, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, msg := range s.messages[roomID] {
+		if msg.ID == messageID {
+			return &msg, nil
+		}
+	}
+	return nil, ErrMessageNotFound
+}
+
+// GetMessages returns the messages for a room that match the given filter.
+// With an empty filter, returns all messages in send order.
+func (s *MessageService) GetMessages(roomID string, filter MessageFilter) []Message {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
@@ -59,25 +604,88 @@ func (s *MessageService) GetMessages(roomID string, afterTime time.Time) []Messa
 		return []Message{}
 	}
 
-	// If no timestamp filter, return all
-	if afterTime.IsZero() {
-		result := make([]Message, len(roomMessages))
+	var result []Message
+	if filter.After.IsZero() && filter.ParticipantID == "" {
+		// No filter, return all
+		result = make([]Message, len(roomMessages))
 		copy(result, roomMessages)
-		return result
+	} else {
+		for i := range roomMessages {
+			if filter.matches(&roomMessages[i]) {
+				result = append(result, roomMessages[i])
+			}
+		}
+		if result == nil {
+			return []Message{}
+		}
 	}
 
-	// Filter messages after the given time
-	var filtered []Message
-	for _, msg := range roomMessages {
-		if msg.Timestamp.After(afterTime) {
-			filtered = append(filtered, msg)
-		}
+	if filter.Descending {
+		slices.Reverse(result)
+	}
+	if filter.Limit > 0 && len(result) > filter.Limit {
+		result = result[:filter.Limit]
 	}
+	return result
+}
 
-	if filtered == nil {
-		return []Message{}
+// MoveRoom transfers a room's messages, settings and sequence counter to a new room ID.
+func (s *MessageService) MoveRoom(oldRoomID, newRoomID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if roomMessages, ok := s.messages[oldRoomID]; ok {
+		for i := range roomMessages {
+			roomMessages[i].RoomID = newRoomID
+		}
+		s.messages[newRoomID] = roomMessages
+		delete(s.messages, oldRoomID)
+	}
+	if ttl, ok := s.ttls[oldRoomID]; ok {
+		s.ttls[newRoomID] = ttl
+		delete(s.ttls, oldRoomID)
+	}
+	if s.noPersist[oldRoomID] {
+		s.noPersist[newRoomID] = true
+		delete(s.noPersist, oldRoomID)
+	}
+	if s.signed[oldRoomID] {
+		s.signed[newRoomID] = true
+		delete(s.signed, oldRoomID)
+	}
+	if seq, ok := s.seqs[oldRoomID]; ok {
+		s.seqs[newRoomID] = seq
+		delete(s.seqs, oldRoomID)
+	}
+	if roomCursors, ok := s.cursors[oldRoomID]; ok {
+		s.cursors[newRoomID] = roomCursors
+		delete(s.cursors, oldRoomID)
+	}
+	if ids, ok := s.evicted[oldRoomID]; ok {
+		s.evicted[newRoomID] = ids
+		delete(s.evicted, oldRoomID)
 	}
-	return filtered
+}
+
+// closedRoomRetention is how long a closed room keeps refusing messages after
+// CloseRoom, covering sends that were in flight when it closed.
+const closedRoomRetention = 1 * time.Minute
+
+// CloseRoom stops a room from accepting messages. Messages already stored are
+// kept until DeleteRoomMessages. The room is forgotten after
+// closedRoomRetention, or immediately by OpenRoom if the ID is reused.
+func (s *MessageService) CloseRoom(roomID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed[roomID] = s.clock.Now()
+}
+
+// OpenRoom lets a room ID that was closed accept messages again, for a new
+// room created with the same ID.
+func (s *MessageService) OpenRoom(roomID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.closed, roomID)
 }
 
 // DeleteRoomMessages removes all messages for a room
@@ -87,14 +695,29 @@ func (s *MessageService) DeleteRoomMessages(roomID string) {
 	defer s.mu.Unlock()
 	count := len(s.messages[roomID])
 	delete(s.messages, roomID)
+	delete(s.ttls, roomID)
+	delete(s.noPersist, roomID)
+	delete(s.signed, roomID)
+	delete(s.seqs, roomID)
+	delete(s.cursors, roomID)
+	delete(s.evicted, roomID)
 	if count > 0 {
 		log.Printf("[Message] Deleted %d messages for room %s", count, roomID)
 	}
 }
 
-// GetMessageCount returns the number of messages in a room (for debugging)
-func (s *MessageService) GetMessageCount(roomID string) int {
+// GetMessageCount returns the number of messages in a room with a sequence
+// number greater than afterSeq. Pass 0 to count all messages.
+func (s *MessageService) GetMessageCount(roomID string, afterSeq int64) int {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
-	return len(s.messages[roomID])
+
+	roomMessages := s.messages[roomID]
+	if afterSeq <= 0 {
+		return len(roomMessages)
+	}
+
+	// Messages are stored in Seq order, so newer ones form a suffix
+	i := sort.Search(len(roomMessages), func(i int) bool { return roomMessages[i].Seq > afterSeq })
+	return len(roomMessages) - i
 }
@@ -0,0 +1,572 @@
+package services
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adi-253/Talkie/backend/internal/models"
+	"github.com/adi-253/Talkie/backend/internal/supabase/supabasetest"
+)
+
+func TestSendMessageTruncatesReplyPreview(t *testing.T) {
+	srv, db := newTestDB(t)
+	clock := newFakeClock()
+	messages := newTestMessageService(db, MessageLimits{MaxReplyPreviewLength: 10}, MessageRetention{}, clock)
+	sender := seedParticipant(srv, "room1", models.RoleParticipant, clock.Now())
+
+	msg, err := messages.SendMessage("room1", models.SendMessageRequest{
+		ParticipantID: sender.ID,
+		Content:       "hello",
+		ReplyTo:       &models.ReplyContext{Username: "bob", Content: strings.Repeat("é", 50)},
+	})
+	if err != nil {
+		t.Fatalf("SendMessage: %v", err)
+	}
+
+	if got, want := msg.ReplyTo.Content, strings.Repeat("é", 10); got != want {
+		t.Errorf("reply preview = %q, want %q", got, want)
+	}
+	if stored := messages.GetMessages("room1", MessageFilter{}); stored[0].ReplyTo.Content != msg.ReplyTo.Content {
+		t.Errorf("stored reply preview = %q, want truncated %q", stored[0].ReplyTo.Content, msg.ReplyTo.Content)
+	}
+}
+
+func TestSendMessageKeepsShortReplyPreview(t *testing.T) {
+	srv, db := newTestDB(t)
+	clock := newFakeClock()
+	messages := newTestMessageService(db, MessageLimits{MaxReplyPreviewLength: 10}, MessageRetention{}, clock)
+	sender := seedParticipant(srv, "room1", models.RoleParticipant, clock.Now())
+
+	reply := &models.ReplyContext{Username: "bob", Content: "short"}
+	msg, err := messages.SendMessage("room1", models.SendMessageRequest{ParticipantID: sender.ID, Content: "hello", ReplyTo: reply})
+	if err != nil {
+		t.Fatalf("SendMessage: %v", err)
+	}
+	if msg.ReplyTo.Content != "short" {
+		t.Errorf("reply preview = %q, want %q", msg.ReplyTo.Content, "short")
+	}
+}
+
+func TestGetMessagesFiltersByParticipant(t *testing.T) {
+	srv, db := newTestDB(t)
+	clock := newFakeClock()
+	messages := newTestMessageService(db, MessageLimits{}, MessageRetention{}, clock)
+	alice := seedParticipant(srv, "room1", models.RoleParticipant, clock.Now())
+	bob := seedParticipant(srv, "room1", models.RoleParticipant, clock.Now())
+	carol := seedParticipant(srv, "room1", models.RoleParticipant, clock.Now())
+
+	var cutoff time.Time
+	for i, sender := range []models.Participant{alice, bob, alice, carol, alice} {
+		if i == 2 {
+			cutoff = clock.Now()
+		}
+		clock.Advance(time.Second)
+		if _, err := messages.SendMessage("room1", models.SendMessageRequest{ParticipantID: sender.ID, Content: "m"}); err != nil {
+			t.Fatalf("SendMessage: %v", err)
+		}
+	}
+
+	got := messages.GetMessages("room1", MessageFilter{ParticipantID: alice.ID})
+	if seqs := messageSeqs(got); !slices.Equal(seqs, []int64{1, 3, 5}) {
+		t.Errorf("alice's messages = %v, want seqs [1 3 5]", seqs)
+	}
+
+	got = messages.GetMessages("room1", MessageFilter{ParticipantID: alice.ID, After: cutoff})
+	if seqs := messageSeqs(got); !slices.Equal(seqs, []int64{3, 5}) {
+		t.Errorf("alice's messages after cutoff = %v, want seqs [3 5]", seqs)
+	}
+
+	if got := messages.GetMessages("room1", MessageFilter{ParticipantID: "nobody"}); got == nil || len(got) != 0 {
+		t.Errorf("messages from unknown sender = %v, want empty non-nil slice", got)
+	}
+}
+
+// messageSeqs returns the sequence numbers of messages in order.
+func messageSeqs(messages []Message) []int64 {
+	seqs := make([]int64, len(messages))
+	for i, msg := range messages {
+		seqs[i] = msg.Seq
+	}
+	return seqs
+}
+
+func TestSendMessageWithAttachments(t *testing.T) {
+	srv, db := newTestDB(t)
+	clock := newFakeClock()
+	messages := newTestMessageService(db, MessageLimits{MaxAttachments: 2, MaxAttachmentSize: 1024}, MessageRetention{}, clock)
+	sender := seedParticipant(srv, "room1", models.RoleParticipant, clock.Now())
+
+	attachments := []models.Attachment{
+		{Name: "a.png", Mime: "image/png", Size: 512, URL: "https://storage.example.com/a.png"},
+		{Name: "b.pdf", Mime: "application/pdf", Size: 1024, URL: "https://storage.example.com/b.pdf"},
+	}
+	msg, err := messages.SendMessage("room1", models.SendMessageRequest{ParticipantID: sender.ID, Content: "files", Attachments: attachments})
+	if err != nil {
+		t.Fatalf("SendMessage: %v", err)
+	}
+	if !slices.Equal(msg.Attachments, attachments) {
+		t.Errorf("attachments = %+v, want %+v", msg.Attachments, attachments)
+	}
+	if stored := messages.GetMessages("room1", MessageFilter{}); len(stored) != 1 || !slices.Equal(stored[0].Attachments, attachments) {
+		t.Errorf("stored messages = %+v, want one with the attachments", stored)
+	}
+}
+
+func TestSendMessageRejectsInvalidAttachments(t *testing.T) {
+	srv, db := newTestDB(t)
+	clock := newFakeClock()
+	messages := newTestMessageService(db, MessageLimits{MaxAttachments: 2, MaxAttachmentSize: 1024}, MessageRetention{}, clock)
+	sender := seedParticipant(srv, "room1", models.RoleParticipant, clock.Now())
+
+	valid := models.Attachment{Name: "a.png", Mime: "image/png", Size: 10, URL: "https://storage.example.com/a.png"}
+	tests := []struct {
+		name        string
+		attachments []models.Attachment
+	}{
+		{"too many", []models.Attachment{valid, valid, valid}},
+		{"too large", []models.Attachment{{Name: "big", Size: 1025, URL: valid.URL}}},
+		{"negative size", []models.Attachment{{Name: "neg", Size: -1, URL: valid.URL}}},
+		{"missing url", []models.Attachment{{Name: "none"}}},
+		{"non-http url", []models.Attachment{{Name: "js", URL: "javascript:alert(1)"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := messages.SendMessage("room1", models.SendMessageRequest{ParticipantID: sender.ID, Content: "files", Attachments: tt.attachments})
+			if !errors.Is(err, ErrInvalidMessage) {
+				t.Errorf("err = %v, want ErrInvalidMessage", err)
+			}
+		})
+	}
+	if stored := messages.GetMessages("room1", MessageFilter{}); len(stored) != 0 {
+		t.Errorf("stored %d messages, want none", len(stored))
+	}
+}
+
+func TestMessageTTLExpiresOldMessages(t *testing.T) {
+	srv, db := newTestDB(t)
+	clock := newFakeClock()
+	messages := newTestMessageService(db, MessageLimits{}, MessageRetention{}, clock)
+	sender := seedParticipant(srv, "room1", models.RoleParticipant, clock.Now())
+	seedParticipant(srv, "room2", models.RoleParticipant, clock.Now())
+	messages.SetRoomTTL("room1", time.Minute)
+
+	old, err := messages.SendMessage("room1", models.SendMessageRequest{ParticipantID: sender.ID, Content: "old"})
+	if err != nil {
+		t.Fatalf("SendMessage: %v", err)
+	}
+	clock.Advance(45 * time.Second)
+	fresh, err := messages.SendMessage("room1", models.SendMessageRequest{ParticipantID: sender.ID, Content: "fresh"})
+	if err != nil {
+		t.Fatalf("SendMessage: %v", err)
+	}
+
+	messages.expireMessages()
+	if got := len(messages.GetMessages("room1", MessageFilter{})); got != 2 {
+		t.Fatalf("messages before TTL = %d, want 2", got)
+	}
+	if got := srv.BroadcastsFor("message_expired"); len(got) != 0 {
+		t.Fatalf("broadcasts before TTL = %d, want 0", len(got))
+	}
+
+	clock.Advance(30 * time.Second)
+	messages.expireMessages()
+
+	got := messages.GetMessages("room1", MessageFilter{})
+	if len(got) != 1 || got[0].ID != fresh.ID {
+		t.Errorf("messages after TTL = %+v, want only %s", got, fresh.ID)
+	}
+	if _, err := messages.GetMessage("room1", old.ID); !errors.Is(err, ErrMessageNotFound) {
+		t.Errorf("GetMessage(expired) err = %v, want ErrMessageNotFound", err)
+	}
+
+	broadcasts := srv.BroadcastsFor("message_expired")
+	if len(broadcasts) != 1 {
+		t.Fatalf("message_expired broadcasts = %d, want 1", len(broadcasts))
+	}
+	if broadcasts[0].Topic != "room:room1" || !strings.Contains(string(broadcasts[0].Payload), old.ID) {
+		t.Errorf("broadcast = %s %s, want room:room1 listing %s", broadcasts[0].Topic, broadcasts[0].Payload, old.ID)
+	}
+}
+
+func TestMessageTTLOnlyAppliesToItsRoom(t *testing.T) {
+	srv, db := newTestDB(t)
+	clock := newFakeClock()
+	messages := newTestMessageService(db, MessageLimits{}, MessageRetention{}, clock)
+	sender := seedParticipant(srv, "room1", models.RoleParticipant, clock.Now())
+	messages.SetRoomTTL("other", time.Minute)
+
+	if _, err := messages.SendMessage("room1", models.SendMessageRequest{ParticipantID: sender.ID, Content: "kept"}); err != nil {
+		t.Fatalf("SendMessage: %v", err)
+	}
+	clock.Advance(time.Hour)
+	messages.expireMessages()
+
+	if got := len(messages.GetMessages("room1", MessageFilter{})); got != 1 {
+		t.Errorf("messages without TTL = %d, want 1", got)
+	}
+	if got := len(srv.BroadcastsFor("message_expired")); got != 0 {
+		t.Errorf("message_expired broadcasts = %d, want 0", got)
+	}
+}
+
+func TestSendMessageRejectsOversizedContent(t *testing.T) {
+	srv, db := newTestDB(t)
+	clock := newFakeClock()
+	messages := newTestMessageService(db, MessageLimits{MaxContentLength: 16}, MessageRetention{}, clock)
+	sender := seedParticipant(srv, "room1", models.RoleParticipant, clock.Now())
+
+	if _, err := messages.SendMessage("room1", models.SendMessageRequest{ParticipantID: sender.ID, Content: strings.Repeat("a", 16)}); err != nil {
+		t.Fatalf("content at the limit: %v", err)
+	}
+	if _, err := messages.SendMessage("room1", models.SendMessageRequest{ParticipantID: sender.ID, Content: strings.Repeat("a", 17)}); !errors.Is(err, ErrInvalidMessage) {
+		t.Errorf("content over the limit: err = %v, want ErrInvalidMessage", err)
+	}
+	if got := len(messages.GetMessages("room1", MessageFilter{})); got != 1 {
+		t.Errorf("stored messages = %d, want 1", got)
+	}
+}
+
+func TestSendMessageValidatesEncryptedEnvelope(t *testing.T) {
+	srv, db := newTestDB(t)
+	clock := newFakeClock()
+	messages := newTestMessageService(db, MessageLimits{}, MessageRetention{}, clock)
+	sender := seedParticipant(srv, "room1", models.RoleParticipant, clock.Now())
+
+	tests := []struct {
+		name      string
+		content   string
+		encrypted bool
+		valid     bool
+	}{
+		{"minimal envelope", base64.StdEncoding.EncodeToString(make([]byte, minEnvelopeLength)), true, true},
+		{"envelope with payload", base64.StdEncoding.EncodeToString(make([]byte, minEnvelopeLength+40)), true, true},
+		{"not base64", "not base64 at all!", true, false},
+		{"truncated envelope", base64.StdEncoding.EncodeToString(make([]byte, minEnvelopeLength-1)), true, false},
+		{"empty after decode", "", true, false},
+		{"plaintext is not checked", "hello", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := messages.SendMessage("room1", models.SendMessageRequest{ParticipantID: sender.ID, Content: tt.content, Encrypted: tt.encrypted})
+			if tt.valid && err != nil {
+				t.Errorf("err = %v, want nil", err)
+			}
+			if !tt.valid && !errors.Is(err, ErrInvalidMessage) {
+				t.Errorf("err = %v, want ErrInvalidMessage", err)
+			}
+		})
+	}
+}
+
+func TestSearchSystemMessagesSkipsUserMessages(t *testing.T) {
+	srv, db := newTestDB(t)
+	clock := newFakeClock()
+	messages := newTestMessageService(db, MessageLimits{}, MessageRetention{}, clock)
+	sender := seedParticipant(srv, "room1", models.RoleParticipant, clock.Now())
+
+	ciphertext := base64.StdEncoding.EncodeToString([]byte("Maintenance tonight, encrypted......"))
+	send := func(content string, encrypted bool) {
+		t.Helper()
+		if _, err := messages.SendMessage("room1", models.SendMessageRequest{ParticipantID: sender.ID, Content: content, Encrypted: encrypted}); err != nil {
+			t.Fatalf("SendMessage: %v", err)
+		}
+	}
+	send(ciphertext, true)
+	first := messages.PostSystemMessage("room1", "Maintenance tonight at 10pm")
+	send("maintenance? what maintenance", false)
+	messages.PostSystemMessage("room1", "Welcome to the room")
+	second := messages.PostSystemMessage("room1", "Reminder: MAINTENANCE window starts soon")
+
+	got := messages.SearchSystemMessages("room1", "maintenance")
+	if len(got) != 2 || got[0].ID != first.ID || got[1].ID != second.ID {
+		t.Fatalf("matches = %+v, want the two maintenance system messages", got)
+	}
+	if got[0].Seq != 2 || got[1].Seq != 5 {
+		t.Errorf("match seqs = %d, %d, want 2, 5", got[0].Seq, got[1].Seq)
+	}
+
+	if got := messages.SearchSystemMessages("room1", ciphertext[:8]); len(got) != 0 {
+		t.Errorf("search for ciphertext matched %+v, want nothing", got)
+	}
+	if got := messages.SearchSystemMessages("room1", "nothing like this"); got == nil || len(got) != 0 {
+		t.Errorf("search without matches = %v, want empty non-nil slice", got)
+	}
+}
+
+// expiredIDs returns the message IDs listed in a room's message_expired broadcasts.
+func expiredIDs(t *testing.T, srv *supabasetest.Server, roomID string) []string {
+	t.Helper()
+	var ids []string
+	for _, b := range srv.BroadcastsFor("message_expired") {
+		if b.Topic != "room:"+roomID {
+			continue
+		}
+		var payload struct {
+			MessageIDs []string `json:"message_ids"`
+		}
+		if err := json.Unmarshal(b.Payload, &payload); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, payload.MessageIDs...)
+	}
+	return ids
+}
+
+func TestRetentionEvictsOverMaxCount(t *testing.T) {
+	srv, db := newTestDB(t)
+	clock := newFakeClock()
+	messages := newTestMessageService(db, MessageLimits{}, MessageRetention{MaxCount: 3}, clock)
+	sender := seedParticipant(srv, "room1", models.RoleParticipant, clock.Now())
+
+	var sent []string
+	for i := 0; i < 5; i++ {
+		msg, err := messages.SendMessage("room1", models.SendMessageRequest{ParticipantID: sender.ID, Content: "m"})
+		if err != nil {
+			t.Fatalf("SendMessage: %v", err)
+		}
+		sent = append(sent, msg.ID)
+	}
+
+	if seqs := messageSeqs(messages.GetMessages("room1", MessageFilter{})); !slices.Equal(seqs, []int64{3, 4, 5}) {
+		t.Errorf("kept seqs = %v, want [3 4 5]", seqs)
+	}
+	if got := len(srv.BroadcastsFor("message_expired")); got != 0 {
+		t.Errorf("broadcasts before the sweeper tick = %d, want 0", got)
+	}
+
+	// Evictions are broadcast in one batch on the next sweep
+	messages.expireMessages()
+	if got := len(srv.BroadcastsFor("message_expired")); got != 1 {
+		t.Errorf("message_expired broadcasts = %d, want 1", got)
+	}
+	if ids := expiredIDs(t, srv, "room1"); !slices.Equal(ids, sent[:2]) {
+		t.Errorf("expired IDs = %v, want %v", ids, sent[:2])
+	}
+
+	messages.expireMessages()
+	if got := len(srv.BroadcastsFor("message_expired")); got != 1 {
+		t.Errorf("message_expired broadcasts after an idle sweep = %d, want 1", got)
+	}
+}
+
+func TestRetentionEvictsOverMaxAge(t *testing.T) {
+	srv, db := newTestDB(t)
+	clock := newFakeClock()
+	messages := newTestMessageService(db, MessageLimits{}, MessageRetention{MaxAge: time.Minute}, clock)
+	sender := seedParticipant(srv, "room1", models.RoleParticipant, clock.Now())
+
+	old, _ := messages.SendMessage("room1", models.SendMessageRequest{ParticipantID: sender.ID, Content: "old"})
+	clock.Advance(50 * time.Second)
+	messages.SendMessage("room1", models.SendMessageRequest{ParticipantID: sender.ID, Content: "new"})
+	clock.Advance(20 * time.Second)
+	messages.expireMessages()
+
+	if seqs := messageSeqs(messages.GetMessages("room1", MessageFilter{})); !slices.Equal(seqs, []int64{2}) {
+		t.Errorf("kept seqs = %v, want [2]", seqs)
+	}
+	if ids := expiredIDs(t, srv, "room1"); !slices.Equal(ids, []string{old.ID}) {
+		t.Errorf("expired IDs = %v, want [%s]", ids, old.ID)
+	}
+}
+
+func TestRetentionCountAndAgeTogether(t *testing.T) {
+	srv, db := newTestDB(t)
+	clock := newFakeClock()
+	messages := newTestMessageService(db, MessageLimits{}, MessageRetention{MaxCount: 2, MaxAge: time.Hour}, clock)
+	sender := seedParticipant(srv, "room1", models.RoleParticipant, clock.Now())
+	// The room's own TTL is shorter than the retention max age, so it wins
+	messages.SetRoomTTL("room1", 10*time.Minute)
+
+	for i := 0; i < 3; i++ {
+		messages.SendMessage("room1", models.SendMessageRequest{ParticipantID: sender.ID, Content: "m"})
+		clock.Advance(4 * time.Minute)
+	}
+	// Count evicted seq 1; seq 2 was sent 8 minutes ago and seq 3 4 minutes ago
+	if seqs := messageSeqs(messages.GetMessages("room1", MessageFilter{})); !slices.Equal(seqs, []int64{2, 3}) {
+		t.Fatalf("kept seqs = %v, want [2 3]", seqs)
+	}
+
+	clock.Advance(3 * time.Minute)
+	messages.expireMessages()
+	if seqs := messageSeqs(messages.GetMessages("room1", MessageFilter{})); !slices.Equal(seqs, []int64{3}) {
+		t.Errorf("kept seqs after the TTL passed seq 2 = %v, want [3]", seqs)
+	}
+	if got := len(expiredIDs(t, srv, "room1")); got != 2 {
+		t.Errorf("expired IDs = %d, want 2 (one by count, one by age)", got)
+	}
+}
+
+func TestSendMessageRequiresParticipantOfRoom(t *testing.T) {
+	srv, db := newTestDB(t)
+	clock := newFakeClock()
+	messages := newTestMessageService(db, MessageLimits{}, MessageRetention{}, clock)
+	member := seedParticipant(srv, "room1", models.RoleParticipant, clock.Now())
+	observer := seedParticipant(srv, "room1", models.RoleObserver, clock.Now())
+	outsider := seedParticipant(srv, "room2", models.RoleParticipant, clock.Now())
+
+	tests := []struct {
+		name          string
+		participantID string
+		wantErr       error
+	}{
+		{"participant", member.ID, nil},
+		{"observer", observer.ID, ErrForbidden},
+		{"missing sender", "", ErrForbidden},
+		{"unknown sender", "8a7c1d2e-0000-4000-8000-000000000000", ErrForbidden},
+		{"participant of another room", outsider.ID, ErrForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := messages.SendMessage("room1", models.SendMessageRequest{ParticipantID: tt.participantID, Content: "m"})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+	if got := len(messages.GetMessages("room1", MessageFilter{})); got != 1 {
+		t.Errorf("stored messages = %d, want 1", got)
+	}
+}
+
+func TestMessageTimestampsComeFromClock(t *testing.T) {
+	srv, db := newTestDB(t)
+	clock := newFakeClock()
+	messages := newTestMessageService(db, MessageLimits{}, MessageRetention{}, clock)
+	sender := seedParticipant(srv, "room1", models.RoleParticipant, clock.Now())
+
+	start := clock.Now()
+	// A client with a skewed clock can't influence server ordering
+	skewed := start.Add(-time.Hour)
+	first, _ := messages.SendMessage("room1", models.SendMessageRequest{ParticipantID: sender.ID, Content: "a", Timestamp: skewed})
+	clock.Advance(time.Second)
+	second, _ := messages.SendMessage("room1", models.SendMessageRequest{ParticipantID: sender.ID, Content: "b"})
+
+	if !first.ServerTimestamp.Equal(start) || !second.ServerTimestamp.Equal(start.Add(time.Second)) {
+		t.Errorf("server timestamps = %v, %v, want %v and one second later", first.ServerTimestamp, second.ServerTimestamp, start)
+	}
+	if !first.Timestamp.Equal(skewed) {
+		t.Errorf("client timestamp = %v, want %v kept for display", first.Timestamp, skewed)
+	}
+
+	// after is exclusive and compares server timestamps only
+	if seqs := messageSeqs(messages.GetMessages("room1", MessageFilter{After: start})); !slices.Equal(seqs, []int64{2}) {
+		t.Errorf("messages after the first = %v, want [2]", seqs)
+	}
+	if seqs := messageSeqs(messages.GetMessages("room1", MessageFilter{After: skewed})); !slices.Equal(seqs, []int64{1, 2}) {
+		t.Errorf("messages after the skewed client time = %v, want [1 2]", seqs)
+	}
+}
+
+func TestMessageOrderingIgnoresScrambledClientTimestamps(t *testing.T) {
+	srv, db := newTestDB(t)
+	clock := newFakeClock()
+	messages := newTestMessageService(db, MessageLimits{}, MessageRetention{}, clock)
+	sender := seedParticipant(srv, "room1", models.RoleParticipant, clock.Now())
+
+	start := clock.Now()
+	clientTimes := []time.Time{start.Add(time.Hour), start.Add(-24 * time.Hour), {}, start.Add(time.Minute)}
+	for i, ts := range clientTimes {
+		if _, err := messages.SendMessage("room1", models.SendMessageRequest{ParticipantID: sender.ID, Content: fmt.Sprint(i), Timestamp: ts}); err != nil {
+			t.Fatal(err)
+		}
+		clock.Advance(time.Second)
+	}
+
+	got := messages.GetMessages("room1", MessageFilter{})
+	if seqs := messageSeqs(got); !slices.Equal(seqs, []int64{1, 2, 3, 4}) {
+		t.Fatalf("order = %v, want send order", seqs)
+	}
+	for i := 1; i < len(got); i++ {
+		if !got[i].ServerTimestamp.After(got[i-1].ServerTimestamp) {
+			t.Errorf("server timestamp of seq %d not after seq %d", got[i].Seq, got[i-1].Seq)
+		}
+	}
+
+	// Polling after the second message returns the later ones, even though the
+	// first client claimed to be an hour ahead
+	after := got[1].ServerTimestamp
+	if seqs := messageSeqs(messages.GetMessages("room1", MessageFilter{After: after})); !slices.Equal(seqs, []int64{3, 4}) {
+		t.Errorf("messages after seq 2 = %v, want [3 4]", seqs)
+	}
+	if seqs := messageSeqs(messages.GetMessages("room1", MessageFilter{After: clientTimes[0]})); len(seqs) != 0 {
+		t.Errorf("messages after the future client time = %v, want none", seqs)
+	}
+}
+
+func TestEphemeralMessageBurnsOnceEveryoneRead(t *testing.T) {
+	srv, db := newTestDB(t)
+	clock := newFakeClock()
+	messages := newTestMessageService(db, MessageLimits{}, MessageRetention{}, clock)
+	alice := seedParticipant(srv, "room1", models.RoleParticipant, clock.Now())
+	bob := seedParticipant(srv, "room1", models.RoleParticipant, clock.Now())
+
+	burn, err := messages.SendMessage("room1", models.SendMessageRequest{ParticipantID: alice.ID, Content: "secret", Ephemeral: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := messages.SendMessage("room1", models.SendMessageRequest{ParticipantID: alice.ID, Content: "kept"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := messages.MarkRead("room1", alice.ID, 2); err != nil {
+		t.Fatal(err)
+	}
+	if seqs := messageSeqs(messages.GetMessages("room1", MessageFilter{})); !slices.Equal(seqs, []int64{1, 2}) {
+		t.Fatalf("after one reader = %v, want both messages", seqs)
+	}
+
+	if err := messages.MarkRead("room1", bob.ID, 1); err != nil {
+		t.Fatal(err)
+	}
+	if seqs := messageSeqs(messages.GetMessages("room1", MessageFilter{})); !slices.Equal(seqs, []int64{2}) {
+		t.Errorf("after everyone read = %v, want only the regular message", seqs)
+	}
+	if ids := expiredIDs(t, srv, "room1"); !slices.Equal(ids, []string{burn.ID}) {
+		t.Errorf("message_expired IDs = %v, want [%s]", ids, burn.ID)
+	}
+
+	// A later joiner never sees the burned message
+	seedParticipant(srv, "room1", models.RoleParticipant, clock.Now())
+	if _, err := messages.GetMessage("room1", burn.ID); !errors.Is(err, ErrMessageNotFound) {
+		t.Errorf("burned message lookup: err = %v, want ErrMessageNotFound", err)
+	}
+}
+
+func TestMarkReadRequiresMembership(t *testing.T) {
+	srv, db := newTestDB(t)
+	clock := newFakeClock()
+	messages := newTestMessageService(db, MessageLimits{}, MessageRetention{}, clock)
+	seedParticipant(srv, "room1", models.RoleParticipant, clock.Now())
+	outsider := seedParticipant(srv, "room2", models.RoleParticipant, clock.Now())
+
+	if err := messages.MarkRead("room1", outsider.ID, 1); !errors.Is(err, ErrForbidden) {
+		t.Errorf("MarkRead by a non-member: err = %v, want ErrForbidden", err)
+	}
+}
+
+func TestClosedRoomRefusesMessagesUntilReopened(t *testing.T) {
+	srv, db := newTestDB(t)
+	clock := newFakeClock()
+	messages := newTestMessageService(db, MessageLimits{}, MessageRetention{}, clock)
+	sender := seedParticipant(srv, "room1", models.RoleParticipant, clock.Now())
+
+	messages.CloseRoom("room1")
+	if _, err := messages.SendMessage("room1", models.SendMessageRequest{ParticipantID: sender.ID, Content: "late"}); !errors.Is(err, ErrRoomNotFound) {
+		t.Errorf("send to a closed room: error = %v, want ErrRoomNotFound", err)
+	}
+	if n := len(messages.GetMessages("room1", MessageFilter{})); n != 0 {
+		t.Errorf("closed room stored %d messages", n)
+	}
+
+	// A new room reusing the ID accepts messages again
+	messages.OpenRoom("room1")
+	if _, err := messages.SendMessage("room1", models.SendMessageRequest{ParticipantID: sender.ID, Content: "fresh"}); err != nil {
+		t.Errorf("send after reopening: %v", err)
+	}
+}
@@ -3,9 +3,18 @@ package services
 import (
 	"crypto/rand"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/adi-253/Talkie/backend/internal/models"
 	"github.com/adi-253/Talkie/backend/internal/supabase"
@@ -15,87 +24,479 @@ import (
 // RoomService handles all room-related business logic.
 // It acts as an intermediary between HTTP handlers and the database.
 type RoomService struct {
-	db *supabase.Client
+	db       *supabase.Client
+	messages *MessageService
+	webhooks *WebhookNotifier
+	settings RoomSettings
+	clock    Clock
+
+	// random is the source of room IDs, encryption keys and salts
+	random io.Reader
+
+	// nameCounter numbers rooms named from settings.NameTemplate
+	nameCounter atomic.Int64
+
+	// ipJoins enforces settings.MaxJoinsPerIP
+	ipJoins *ipJoinTracker
+
+	// reserved holds settings.ReservedNames lowercased
+	reserved map[string]bool
+
+	// cache serves GetRoom for settings.CacheTTL
+	cache *roomCache
+
+	// deleteHooks drop state other services hold for deleted rooms
+	deleteHooks []func(roomID string)
+}
+
+// RoomSettings holds deployment configuration for room behavior.
+type RoomSettings struct {
+	// Avatars is the allowlist of avatar identifiers participants may choose
+	Avatars []string
+
+	// DefaultAvatar replaces an empty avatar on join (empty leaves it to the client)
+	DefaultAvatar string
+
+	// NameTemplate names rooms created without a name (empty uses "Untitled Room").
+	// Supports {date} (YYYY-MM-DD) and {n}, a counter of rooms named this way
+	// since startup, e.g. "Room {n} - {date}".
+	NameTemplate string
+
+	// WelcomeMessage is posted as a plaintext system message in every new room (empty disables)
+	WelcomeMessage string
+
+	// SlugRoomIDs uses a slug of the room name as its ID (like a channel name)
+	// instead of a random ID. Rooms created without a name still get a random ID.
+	SlugRoomIDs bool
+
+	// RoomIDBytes is the entropy of random room IDs in bytes, clamped to
+	// [minRoomIDBytes, maxRoomIDBytes]; IDs are hex so twice as long
+	RoomIDBytes int
+
+	// MaxJoinsPerIP caps the participants one client IP may hold in a room (0 disables)
+	MaxJoinsPerIP int
+
+	// CacheTTL is how long GetRoom results are cached (0 disables)
+	CacheTTL time.Duration
+
+	// ReservedNames are room IDs and names that can't be used for new rooms,
+	// compared case-insensitively
+	ReservedNames []string
+}
+
+// maxSlugLength bounds room IDs derived from room names.
+const maxSlugLength = 48
+
+// Bounds for the entropy of random room IDs. The maximum keeps hex IDs
+// within maxSlugLength so every room ID passes ValidRoomID.
+const (
+	minRoomIDBytes = 4
+	maxRoomIDBytes = maxSlugLength / 2
+)
+
+// Bounds for the idle threshold of a host's kick-inactive action. The minimum
+// keeps hosts from removing participants who are merely between heartbeats.
+const (
+	minKickIdle = 1 * time.Minute
+	maxKickIdle = 1 * time.Hour
+)
+
+// maxStatusLength bounds a participant's custom status, in characters.
+const maxStatusLength = 64
+
+// slugPattern is the allowed charset for slug room IDs:
+// lowercase alphanumeric words separated by single hyphens.
+var slugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// ValidRoomID reports whether id is well-formed: a random hex ID or a slug,
+// at most maxSlugLength characters. It doesn't check that the room exists.
+func ValidRoomID(id string) bool {
+	return len(id) <= maxSlugLength && slugPattern.MatchString(id)
 }
 
 // NewRoomService creates a new RoomService instance.
-func NewRoomService(db *supabase.Client) *RoomService {
-	return &RoomService{db: db}
+// A nil random source defaults to crypto/rand.Reader; nil webhooks disables
+// lifecycle webhooks.
+func NewRoomService(db *supabase.Client, messages *MessageService, webhooks *WebhookNotifier, settings RoomSettings, clock Clock, random io.Reader) *RoomService {
+	if random == nil {
+		random = rand.Reader
+	}
+	settings.RoomIDBytes = min(max(settings.RoomIDBytes, minRoomIDBytes), maxRoomIDBytes)
+	reserved := make(map[string]bool, len(settings.ReservedNames))
+	for _, name := range settings.ReservedNames {
+		reserved[strings.ToLower(strings.TrimSpace(name))] = true
+	}
+	return &RoomService{db: db, messages: messages, webhooks: webhooks, settings: settings, clock: clock, random: random, ipJoins: newIPJoinTracker(), reserved: reserved, cache: newRoomCache(settings.CacheTTL, clock)}
+}
+
+// isReserved reports whether a room ID or name is on the reserved list.
+func (s *RoomService) isReserved(name string) bool {
+	return s.reserved[strings.ToLower(strings.TrimSpace(name))]
+}
+
+// Avatars returns the avatar identifiers participants may choose from.
+func (s *RoomService) Avatars() []string {
+	return s.settings.Avatars
+}
+
+// validateAvatar checks an avatar against the configured allowlist.
+// An empty avatar is allowed and left for the client to render a fallback.
+func (s *RoomService) validateAvatar(avatar string) error {
+	if avatar == "" {
+		return nil
+	}
+	for _, allowed := range s.settings.Avatars {
+		if avatar == allowed {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %q", ErrInvalidAvatar, avatar)
 }
 
 // CreateRoom generates a new room with a unique ID and inserts it into the database.
 // The room ID is a short, URL-friendly string that users can easily share.
 // An encryption key is generated for message encryption.
-func (s *RoomService) CreateRoom(name string) (*models.Room, error) {
-	// Generate a short, memorable room ID (8 characters)
-	roomID, err := generateRoomID()
+// If requestedID is set, it is used as the room ID instead (see newRoomID).
+// If messageTTL is positive, messages in the room expire after that duration.
+// If persist is false, the room's messages are never stored.
+// If requireSignatures is true, the room only accepts signed messages.
+func (s *RoomService) CreateRoom(requestedID, name string, messageTTL time.Duration, persist, requireSignatures bool) (*models.Room, error) {
+	if s.isReserved(name) {
+		return nil, fmt.Errorf("%w: %q", ErrReservedRoomName, name)
+	}
+
+	roomID, err := s.newRoomID(requestedID, name)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate room ID: %w", err)
+		return nil, err
 	}
 
 	// Generate encryption key (32 bytes = 256 bits for AES-256)
-	encryptionKey, err := generateEncryptionKey()
+	encryptionKey, err := s.generateEncryptionKey()
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate encryption key: %w", err)
 	}
 
+	keySalt, err := s.generateKeySalt()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate key salt: %w", err)
+	}
+
 	// Default name if not provided
 	if name == "" {
-		name = "Untitled Room"
+		name = s.defaultRoomName()
 	}
 
-	now := time.Now().UTC()
+	now := s.clock.Now().UTC()
 	room := &models.Room{
-		ID:            roomID,
-		Name:          name,
-		EncryptionKey: encryptionKey,
-		CreatedAt:     now,
-		LastActiveAt:  now,
+		ID:                roomID,
+		Name:              name,
+		EncryptionKey:     encryptionKey,
+		KeyVersion:        1,
+		KeySalt:           keySalt,
+		CreatedAt:         now,
+		LastActiveAt:      now,
+		MessageTTLSeconds: int(messageTTL / time.Second),
+		Persist:           persist,
+		RequireSignatures: requireSignatures,
 	}
 
 	if err := s.db.CreateRoom(room); err != nil {
+		// A concurrent create may have taken the ID since newRoomID checked it
+		if supabase.IsStatus(err, http.StatusConflict) {
+			if requestedID != "" {
+				return nil, ErrRoomIDTaken
+			}
+			if s.settings.SlugRoomIDs {
+				return nil, ErrRoomNameTaken
+			}
+		}
 		return nil, fmt.Errorf("failed to create room: %w", err)
 	}
 
+	// The ID may belong to a room deleted moments ago; drop any history, seq and
+	// read cursors it left behind before accepting messages again
+	s.messages.DeleteRoomMessages(room.ID)
+	s.messages.OpenRoom(room.ID)
+	s.applyMessageSettings(room)
+
+	// Seed the history with the operator's welcome message so the first joiner sees it
+	if s.settings.WelcomeMessage != "" {
+		s.messages.PostSystemMessage(room.ID, s.settings.WelcomeMessage)
+	}
+
 	// Broadcast room creation so the lobby updates in real-time
 	if err := s.db.BroadcastRoomEvent("created", room); err != nil {
 		log.Printf("Failed to broadcast room created for %s: %v", room.ID, err)
 	}
+	s.webhooks.RoomCreated(room)
 
 	return room, nil
 }
 
+// defaultRoomName expands the configured name template for an unnamed room.
+func (s *RoomService) defaultRoomName() string {
+	if s.settings.NameTemplate == "" {
+		return "Untitled Room"
+	}
+
+	n := s.nameCounter.Add(1)
+	return strings.NewReplacer(
+		"{date}", s.clock.Now().UTC().Format("2006-01-02"),
+		"{n}", strconv.FormatInt(n, 10),
+	).Replace(s.settings.NameTemplate)
+}
+
+// applyMessageSettings passes a room's message TTL, persistence and signing
+// requirement to the message store.
+func (s *RoomService) applyMessageSettings(room *models.Room) {
+	s.messages.SetRoomTTL(room.ID, room.MessageTTL())
+	s.messages.SetRoomPersist(room.ID, room.Persist)
+	s.messages.SetRoomRequireSignatures(room.ID, room.RequireSignatures)
+}
+
+// SigningSecret returns the message signing secret issued to a participant.
+func (s *RoomService) SigningSecret(participantID string) string {
+	return s.messages.SigningSecret(participantID)
+}
+
+// InvalidateRoom drops cached state for rooms changed outside RoomService,
+// e.g. by cleanup.
+func (s *RoomService) InvalidateRoom(roomIDs ...string) {
+	s.cache.invalidate(roomIDs...)
+}
+
+// ForgetRoom drops all in-memory state held for a deleted room. Cleanup calls
+// it for rooms it deletes outside RoomService.
+func (s *RoomService) ForgetRoom(roomID string) {
+	s.cache.invalidate(roomID)
+	s.ipJoins.forgetRoom(roomID)
+	for _, hook := range s.deleteHooks {
+		hook(roomID)
+	}
+}
+
+// OnRoomDeleted registers a hook called with the ID of every room deleted
+// through RoomService or ForgetRoom. Register hooks before serving requests.
+func (s *RoomService) OnRoomDeleted(hook func(roomID string)) {
+	s.deleteHooks = append(s.deleteHooks, hook)
+}
+
+// Authenticate reports whether secret is the credential issued to participantID at join.
+func (s *RoomService) Authenticate(participantID, secret string) bool {
+	return s.messages.Authenticate(participantID, secret)
+}
+
+// newRoomID picks the ID for a new room. A caller-requested ID is used as-is
+// if valid, failing with ErrRoomIDTaken if it is already in use. In slug mode
+// a named room uses a slug of its name and fails with ErrRoomNameTaken if that
+// slug is already in use; otherwise a short random ID is generated.
+func (s *RoomService) newRoomID(requestedID, name string) (string, error) {
+	if requestedID != "" {
+		if !ValidRoomID(requestedID) {
+			return "", fmt.Errorf("%w: %q", ErrInvalidRoomID, requestedID)
+		}
+		if s.isReserved(requestedID) {
+			return "", fmt.Errorf("%w: %q", ErrReservedRoomName, requestedID)
+		}
+		exists, err := s.roomExists(requestedID)
+		if err != nil {
+			return "", fmt.Errorf("failed to check room ID: %w", err)
+		}
+		if exists {
+			return "", ErrRoomIDTaken
+		}
+		return requestedID, nil
+	}
+
+	if !s.settings.SlugRoomIDs || name == "" {
+		// Generate a short, memorable room ID (8 characters)
+		roomID, err := s.generateRoomID()
+		if err != nil {
+			return "", fmt.Errorf("failed to generate room ID: %w", err)
+		}
+		return roomID, nil
+	}
+
+	slug, err := slugify(name)
+	if err != nil {
+		return "", err
+	}
+	if s.isReserved(slug) {
+		return "", fmt.Errorf("%w: %q", ErrReservedRoomName, slug)
+	}
+
+	exists, err := s.roomExists(slug)
+	if err != nil {
+		return "", fmt.Errorf("failed to check room name: %w", err)
+	}
+	if exists {
+		return "", ErrRoomNameTaken
+	}
+	return slug, nil
+}
+
+// roomExists reports whether a room with the given ID exists.
+func (s *RoomService) roomExists(roomID string) (bool, error) {
+	_, err := s.db.GetRoom(roomID)
+	if err == nil {
+		return true, nil
+	}
+	if errors.Is(err, supabase.ErrNotFound) {
+		return false, nil
+	}
+	return false, err
+}
+
+// slugify converts a room name into a URL-friendly room ID, e.g. "Book Club" -> "book-club".
+// Returns ErrInvalidRoomSlug if the name contains characters outside [a-z0-9 _-].
+func slugify(name string) (string, error) {
+	words := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return unicode.IsSpace(r) || r == '_' || r == '-'
+	})
+	slug := strings.Join(words, "-")
+
+	if slug == "" || len(slug) > maxSlugLength || !slugPattern.MatchString(slug) {
+		return "", fmt.Errorf("%w: %q", ErrInvalidRoomSlug, name)
+	}
+	return slug, nil
+}
+
 // GetRoom retrieves a room by its ID along with the current participants.
 func (s *RoomService) GetRoom(roomID string) (*models.Room, []models.Participant, error) {
-	room, err := s.db.GetRoom(roomID)
+	if room, participants, ok := s.cache.get(roomID); ok {
+		setDurations(s.clock.Now(), participants)
+		return room, participants, nil
+	}
+
+	room, participants, err := s.db.GetRoomWithParticipants(roomID)
 	if err != nil {
 		return nil, nil, err
 	}
+	s.cache.put(room, participants)
+	setDurations(s.clock.Now(), participants)
+	return room, participants, nil
+}
 
-	participants, err := s.db.GetParticipants(roomID)
+// setDurations fills in each participant's DurationSeconds as of now.
+func setDurations(now time.Time, participants []models.Participant) {
+	for i := range participants {
+		participants[i].SetDuration(now)
+	}
+}
+
+// recordLeave fills in how long a departing participant was in the room, logs
+// the session length and emits the participant.left webhook event, whose
+// payload carries it as duration_seconds.
+func recordLeave(now time.Time, participant *models.Participant, webhooks *WebhookNotifier) {
+	participant.SetDuration(now)
+	log.Printf("[Room] Participant %s (%s) left room %s after %ds",
+		participant.ID, participant.Username, participant.RoomID, *participant.DurationSeconds)
+	webhooks.ParticipantLeft(participant)
+}
+
+// GetParticipant retrieves a participant by ID.
+// Reconnecting clients use this to restore their session.
+func (s *RoomService) GetParticipant(participantID string) (*models.Participant, error) {
+	participant, err := s.db.GetParticipant(participantID)
 	if err != nil {
-		return nil, nil, err
+		if errors.Is(err, supabase.ErrNotFound) {
+			return nil, ErrParticipantNotFound
+		}
+		return nil, fmt.Errorf("failed to get participant: %w", err)
 	}
+	participant.SetDuration(s.clock.Now())
+	return participant, nil
+}
 
-	return room, participants, nil
+// ListRooms retrieves one page of active rooms, newest first.
+func (s *RoomService) ListRooms(limit, offset int) ([]models.Room, error) {
+	return s.db.ListRoomsPage(limit, offset)
 }
 
-// ListRooms retrieves all active rooms.
-func (s *RoomService) ListRooms() ([]models.Room, error) {
-	return s.db.ListRooms()
+// ListAllParticipants returns one page of participants across all rooms.
+func (s *RoomService) ListAllParticipants(limit, offset int) ([]models.Participant, error) {
+	participants, err := s.db.ListParticipantsPage(limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	setDurations(s.clock.Now(), participants)
+	return participants, nil
+}
+
+// CountActive returns the number of active rooms and participants.
+// Used by the admin stats endpoints for capacity planning; only counts are
+// fetched, never the rows themselves.
+func (s *RoomService) CountActive() (int, int, error) {
+	rooms, err := s.db.CountRooms()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	participants, err := s.db.CountAllParticipants()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return rooms, participants, nil
 }
 
 // JoinRoom adds a new participant to an existing room.
-// Returns the participant ID and current room state.
-func (s *RoomService) JoinRoom(roomID, username, avatar string) (*models.Participant, *models.Room, []models.Participant, error) {
+// If participantID refers to an existing participant of this room, that session is
+// resumed instead, which is allowed even when the room is locked. Resuming requires
+// secret to be the participant's signing secret; otherwise ErrInvalidCredential
+// is returned.
+// An empty avatar defaults to the configured default avatar and an empty role
+// to models.RoleParticipant.
+// clientIP is counted against settings.MaxJoinsPerIP; new joins beyond the cap
+// fail with ErrTooManyJoins (rejoins are not counted).
+// Returns the participant and current room state.
+func (s *RoomService) JoinRoom(roomID, username, avatar, role, participantID, secret, clientIP string) (*models.Participant, *models.Room, []models.Participant, error) {
+	if err := s.validateAvatar(avatar); err != nil {
+		return nil, nil, nil, err
+	}
+	if avatar == "" {
+		avatar = s.settings.DefaultAvatar
+	}
+
+	switch role {
+	case "":
+		role = models.RoleParticipant
+	case models.RoleParticipant, models.RoleObserver:
+	default:
+		return nil, nil, nil, ErrInvalidRole
+	}
+
 	// Verify room exists
 	room, err := s.db.GetRoom(roomID)
 	if err != nil {
-		return nil, nil, nil, fmt.Errorf("room not found: %w", err)
+		if errors.Is(err, supabase.ErrNotFound) {
+			return nil, nil, nil, ErrRoomNotFound
+		}
+		return nil, nil, nil, fmt.Errorf("failed to get room: %w", err)
+	}
+
+	// Message settings are held in memory, so re-apply the room's settings in
+	// case the server restarted since the room was created
+	s.applyMessageSettings(room)
+
+	// Resume an existing session if the client still holds a valid participant ID
+	// and proves it with the secret it was issued at join
+	if participantID != "" {
+		existing, err := s.db.GetParticipant(participantID)
+		if err == nil && existing.RoomID == roomID {
+			if !s.Authenticate(participantID, secret) {
+				return nil, nil, nil, ErrInvalidCredential
+			}
+			return s.rejoinRoom(room, existing)
+		}
+	}
+
+	if room.Locked {
+		return nil, nil, nil, ErrRoomLocked
 	}
 
 	// Create new participant
-	now := time.Now().UTC()
+	now := s.clock.Now().UTC()
 	participant := &models.Participant{
 		ID:           uuid.New().String(),
 		RoomID:       roomID,
@@ -103,16 +504,54 @@ func (s *RoomService) JoinRoom(roomID, username, avatar string) (*models.Partici
 		Avatar:       avatar,
 		JoinedAt:     now,
 		LastActiveAt: now,
+		Role:         role,
 	}
 
+	if err := s.reserveIPJoin(roomID, clientIP, participant.ID); err != nil {
+		return nil, nil, nil, err
+	}
+
+	// Invalidate once the insert and host claim are done, so a GetRoom that
+	// runs meanwhile can't cache the room without the new participant
+	defer s.cache.invalidate(roomID)
 	if err := s.db.AddParticipant(participant); err != nil {
+		s.ipJoins.release(roomID, clientIP, participant.ID)
+		// The participants.room_id foreign key rejects the insert if the room
+		// was deleted (e.g. by cleanup) after it was fetched above
+		if supabase.IsStatus(err, http.StatusConflict) {
+			if exists, existsErr := s.roomExists(roomID); existsErr == nil && !exists {
+				return nil, nil, nil, ErrRoomNotFound
+			}
+		}
 		return nil, nil, nil, fmt.Errorf("failed to join room: %w", err)
 	}
+	s.ipJoins.confirm(roomID, clientIP, participant.ID)
+
+	// A concurrent delete may have removed the room (cascading to the new
+	// participant) right after the insert; don't report a successful join then
+	if exists, err := s.roomExists(roomID); err == nil && !exists {
+		if err := s.db.RemoveParticipant(participant.ID); err != nil {
+			log.Printf("[Room] Warning: failed to roll back participant %s: %v", participant.ID, err)
+		}
+		s.ipJoins.forgetRoom(roomID)
+		return nil, nil, nil, ErrRoomNotFound
+	}
+
+	// The first participant to join becomes the host; observers never host
+	if room.HostParticipantID == "" && role == models.RoleParticipant {
+		claimed, err := s.db.ClaimRoomHost(roomID, participant.ID)
+		if err != nil {
+			log.Printf("[Room] Warning: failed to claim host for %s: %v", roomID, err)
+		} else if claimed {
+			room.HostParticipantID = participant.ID
+		}
+	}
 
 	// Broadcast join event so other clients update instantly
 	if err := s.db.BroadcastParticipantEvent(roomID, "join", participant); err != nil {
 		log.Printf("Failed to broadcast participant join for %s: %v", participant.ID, err)
 	}
+	s.webhooks.ParticipantJoined(participant)
 
 	// Update room activity
 	if err := s.db.UpdateRoomActivity(roomID); err != nil {
@@ -126,51 +565,486 @@ func (s *RoomService) JoinRoom(roomID, username, avatar string) (*models.Partici
 		return nil, nil, nil, err
 	}
 
+	participant.SetDuration(now)
+	setDurations(now, participants)
 	return participant, room, participants, nil
 }
 
+// reserveIPJoin counts a new participant against the per-IP cap for the room
+// as a pending join; JoinRoom confirms it once the participant is stored.
+// Participants who have since left are no longer counted.
+func (s *RoomService) reserveIPJoin(roomID, clientIP, participantID string) error {
+	if s.settings.MaxJoinsPerIP <= 0 || clientIP == "" {
+		return nil
+	}
+
+	snapshot := s.ipJoins.snapshot()
+	present, err := s.db.GetParticipants(roomID)
+	if err != nil {
+		return fmt.Errorf("failed to get participants: %w", err)
+	}
+	if !s.ipJoins.reserve(roomID, clientIP, participantID, present, snapshot, s.settings.MaxJoinsPerIP) {
+		return ErrTooManyJoins
+	}
+	return nil
+}
+
+// rejoinRoom resumes an existing participant session without creating a new participant.
+func (s *RoomService) rejoinRoom(room *models.Room, participant *models.Participant) (*models.Participant, *models.Room, []models.Participant, error) {
+	if err := s.UpdateHeartbeat(room.ID, participant.ID); err != nil {
+		// Non-fatal error, log but continue
+		log.Printf("[Room] Warning: failed to refresh activity for rejoining %s: %v", participant.ID, err)
+	}
+
+	participants, err := s.db.GetParticipants(room.ID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	now := s.clock.Now()
+	participant.SetDuration(now)
+	setDurations(now, participants)
+	return participant, room, participants, nil
+}
+
+// UpdateParticipant applies a participant's changes to their own details and
+// broadcasts a participant_update event.
+func (s *RoomService) UpdateParticipant(roomID, participantID string, req models.UpdateParticipantRequest) (*models.Participant, error) {
+	participant, err := s.GetParticipant(participantID)
+	if err != nil {
+		return nil, err
+	}
+	if participant.RoomID != roomID {
+		return nil, ErrParticipantNotFound
+	}
+
+	if req.Status != nil {
+		status := strings.TrimSpace(*req.Status)
+		if utf8.RuneCountInString(status) > maxStatusLength {
+			return nil, fmt.Errorf("%w: status exceeds %d characters", ErrInvalidStatus, maxStatusLength)
+		}
+		if err := s.db.UpdateParticipantStatus(participantID, status); err != nil {
+			return nil, fmt.Errorf("failed to update status: %w", err)
+		}
+		participant.Status = status
+	}
+
+	if req.ChangesIdentity() {
+		username, avatar := participant.Username, participant.Avatar
+		if req.Username != nil {
+			username = strings.TrimSpace(*req.Username)
+			if username == "" {
+				return nil, ErrInvalidUsername
+			}
+		}
+		if req.Avatar != nil {
+			if err := s.validateAvatar(*req.Avatar); err != nil {
+				return nil, err
+			}
+			avatar = *req.Avatar
+			if avatar == "" {
+				avatar = s.settings.DefaultAvatar
+			}
+		}
+		if err := s.db.UpdateParticipantIdentity(participantID, username, avatar); err != nil {
+			return nil, fmt.Errorf("failed to update participant: %w", err)
+		}
+		participant.Username, participant.Avatar = username, avatar
+	}
+	s.cache.invalidate(roomID)
+	participant.SetDuration(s.clock.Now())
+
+	if err := s.db.BroadcastParticipantUpdate(participant); err != nil {
+		log.Printf("Failed to broadcast participant update for %s: %v", participantID, err)
+	}
+	return participant, nil
+}
+
+// SetRoomLocked locks or unlocks a room. Only the room host may do this.
+// Connected clients are notified via lock_changed and room_settings broadcasts.
+func (s *RoomService) SetRoomLocked(roomID, participantID string, locked bool) (*models.Room, error) {
+	room, err := s.db.GetRoom(roomID)
+	if err != nil {
+		if errors.Is(err, supabase.ErrNotFound) {
+			return nil, ErrRoomNotFound
+		}
+		return nil, fmt.Errorf("failed to get room: %w", err)
+	}
+
+	if room.HostParticipantID == "" || room.HostParticipantID != participantID {
+		return nil, ErrNotHost
+	}
+
+	if err := s.db.SetRoomLocked(roomID, locked); err != nil {
+		return nil, fmt.Errorf("failed to update room lock: %w", err)
+	}
+	s.cache.invalidate(roomID)
+	room.Locked = locked
+
+	if err := s.db.BroadcastLockChanged(roomID, locked); err != nil {
+		log.Printf("Failed to broadcast lock change for %s: %v", roomID, err)
+	}
+	s.broadcastSettings(room)
+
+	return room, nil
+}
+
+// broadcastSettings emits the room_settings snapshot after a setting changed.
+// Failures are logged; the change itself already succeeded.
+func (s *RoomService) broadcastSettings(room *models.Room) {
+	if err := s.db.BroadcastRoomSettings(room); err != nil {
+		log.Printf("Failed to broadcast settings for %s: %v", room.ID, err)
+	}
+}
+
+// TransferHost hands the host role from the current host to another
+// participant of the room. Only the room host may do this; observers can't
+// become host. Connected clients are notified via host_changed.
+func (s *RoomService) TransferHost(roomID, participantID, newHostID string) (*models.Room, error) {
+	room, err := s.db.GetRoom(roomID)
+	if err != nil {
+		if errors.Is(err, supabase.ErrNotFound) {
+			return nil, ErrRoomNotFound
+		}
+		return nil, fmt.Errorf("failed to get room: %w", err)
+	}
+
+	if room.HostParticipantID == "" || room.HostParticipantID != participantID {
+		return nil, ErrNotHost
+	}
+
+	target, err := s.db.GetParticipant(newHostID)
+	if err != nil {
+		if errors.Is(err, supabase.ErrNotFound) {
+			return nil, ErrInvalidHostTarget
+		}
+		return nil, fmt.Errorf("failed to get participant: %w", err)
+	}
+	if target.RoomID != roomID || target.Role == models.RoleObserver {
+		return nil, ErrInvalidHostTarget
+	}
+
+	// Conditional on the caller still being host, in case of a concurrent transfer
+	transferred, err := s.db.TransferRoomHost(roomID, participantID, newHostID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to transfer host: %w", err)
+	}
+	s.cache.invalidate(roomID)
+	if !transferred {
+		return nil, ErrNotHost
+	}
+	room.HostParticipantID = newHostID
+
+	if err := s.db.BroadcastHostChanged(roomID, participantID, newHostID); err != nil {
+		log.Printf("Failed to broadcast host change for %s: %v", roomID, err)
+	}
+	s.broadcastSettings(room)
+
+	return room, nil
+}
+
+// promoteHost hands the host role of a room whose host left to the
+// longest-present remaining participant. If only observers remain, the role is
+// cleared so the next participant to join claims it. Failures are logged.
+func promoteHost(db *supabase.Client, room *models.Room) {
+	participants, err := db.GetParticipants(room.ID)
+	if err != nil {
+		log.Printf("[Room] Failed to get participants to promote host in %s: %v", room.ID, err)
+		return
+	}
+
+	var newHost *models.Participant
+	for i := range participants {
+		p := &participants[i]
+		if p.Role == models.RoleObserver || p.ID == room.HostParticipantID {
+			continue
+		}
+		if newHost == nil || p.JoinedAt.Before(newHost.JoinedAt) {
+			newHost = p
+		}
+	}
+
+	newHostID := ""
+	if newHost != nil {
+		newHostID = newHost.ID
+	}
+	oldHostID := room.HostParticipantID
+	promoted, err := db.TransferRoomHost(room.ID, oldHostID, newHostID)
+	if err != nil {
+		log.Printf("[Room] Failed to promote host in %s: %v", room.ID, err)
+		return
+	}
+	if !promoted {
+		return
+	}
+	room.HostParticipantID = newHostID
+	log.Printf("[Room] Host %s left room %s, promoted %q", oldHostID, room.ID, newHostID)
+
+	if err := db.BroadcastHostChanged(room.ID, oldHostID, newHostID); err != nil {
+		log.Printf("Failed to broadcast host change for %s: %v", room.ID, err)
+	}
+	if err := db.BroadcastRoomSettings(room); err != nil {
+		log.Printf("Failed to broadcast settings for %s: %v", room.ID, err)
+	}
+}
+
+// KickInactive removes every participant of a room who has been inactive for
+// longer than idle (clamped to [minKickIdle, maxKickIdle]) and broadcasts their
+// leaves. Only the room host may do this; the host is never removed.
+// Returns the number of participants removed.
+func (s *RoomService) KickInactive(roomID, participantID string, idle time.Duration) (int, error) {
+	room, err := s.db.GetRoom(roomID)
+	if err != nil {
+		if errors.Is(err, supabase.ErrNotFound) {
+			return 0, ErrRoomNotFound
+		}
+		return 0, fmt.Errorf("failed to get room: %w", err)
+	}
+
+	if room.HostParticipantID == "" || room.HostParticipantID != participantID {
+		return 0, ErrNotHost
+	}
+
+	idle = min(max(idle, minKickIdle), maxKickIdle)
+	cutoff := s.clock.Now().UTC().Add(-idle)
+
+	participants, err := s.db.GetParticipants(roomID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get participants: %w", err)
+	}
+
+	var removed []models.Participant
+	for _, p := range participants {
+		if p.ID == room.HostParticipantID || !p.LastActiveAt.Before(cutoff) {
+			continue
+		}
+		if err := s.db.RemoveParticipant(p.ID); err != nil {
+			log.Printf("[Room] Failed to kick inactive participant %s: %v", p.ID, err)
+			continue
+		}
+		log.Printf("[Room] Kicked inactive participant %s (%s) from room %s", p.ID, p.Username, roomID)
+		recordLeave(s.clock.Now(), &p, s.webhooks)
+		removed = append(removed, p)
+	}
+
+	if len(removed) > 0 {
+		s.cache.invalidate(roomID)
+		if err := s.db.BroadcastParticipantsLeft(roomID, removed); err != nil {
+			log.Printf("Failed to broadcast participant leaves for room %s: %v", roomID, err)
+		}
+	}
+
+	return len(removed), nil
+}
+
+// CloseRoom deletes a room on the host's request and tears it down before
+// returning, in this order:
+//  1. the room stops accepting messages, so none is stored after step 2
+//  2. room_closed is broadcast so clients leave the room's channel (the
+//     realtime connections themselves belong to Supabase, not this server)
+//  3. the room row is deleted, removing its participants with it
+//  4. the room's messages are purged from memory
+func (s *RoomService) CloseRoom(roomID, participantID string) error {
+	room, err := s.db.GetRoom(roomID)
+	if err != nil {
+		if errors.Is(err, supabase.ErrNotFound) {
+			return ErrRoomNotFound
+		}
+		return fmt.Errorf("failed to get room: %w", err)
+	}
+
+	if room.HostParticipantID == "" || room.HostParticipantID != participantID {
+		return ErrNotHost
+	}
+
+	s.messages.CloseRoom(roomID)
+
+	if err := s.db.BroadcastRoomClosed(roomID); err != nil {
+		log.Printf("Failed to broadcast room closed for %s: %v", roomID, err)
+	}
+
+	if err := s.db.DeleteRoom(roomID); err != nil {
+		// Leave the room usable rather than half torn down
+		s.messages.OpenRoom(roomID)
+		return fmt.Errorf("failed to delete room: %w", err)
+	}
+	s.ForgetRoom(roomID)
+
+	s.messages.DeleteRoomMessages(roomID)
+
+	// Broadcast room deletion so the lobby updates in real-time
+	if err := s.db.BroadcastRoomEvent("deleted", room); err != nil {
+		log.Printf("Failed to broadcast room deleted for %s: %v", roomID, err)
+	}
+	s.webhooks.RoomDeleted(room)
+
+	return nil
+}
+
+// RotateRoomKey moves a room to the next key epoch with a fresh salt, so
+// clients derive new message keys. Only the room host may do this. The server
+// never sees the derived keys. Connected clients are notified via key_rotated.
+func (s *RoomService) RotateRoomKey(roomID, participantID string) (*models.Room, error) {
+	room, err := s.db.GetRoom(roomID)
+	if err != nil {
+		if errors.Is(err, supabase.ErrNotFound) {
+			return nil, ErrRoomNotFound
+		}
+		return nil, fmt.Errorf("failed to get room: %w", err)
+	}
+
+	if room.HostParticipantID == "" || room.HostParticipantID != participantID {
+		return nil, ErrNotHost
+	}
+
+	salt, err := s.generateKeySalt()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate key salt: %w", err)
+	}
+
+	rotated, err := s.db.RotateRoomKey(roomID, room.KeyVersion, salt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to rotate room key: %w", err)
+	}
+	s.cache.invalidate(roomID)
+	if !rotated {
+		return nil, ErrKeyRotationConflict
+	}
+	room.KeyVersion++
+	room.KeySalt = salt
+
+	if err := s.db.BroadcastKeyRotated(room); err != nil {
+		log.Printf("Failed to broadcast key rotation for %s: %v", roomID, err)
+	}
+
+	return room, nil
+}
+
+// RegenerateRoomID moves a room to a fresh random ID, e.g. after its link leaked.
+// Only the room host may do this. Participants and messages move with the room
+// and the old ID stops resolving. PostgREST has no multi-statement transactions,
+// so the new room row is removed again if moving the participants fails.
+func (s *RoomService) RegenerateRoomID(roomID, participantID string) (*models.Room, error) {
+	room, err := s.db.GetRoom(roomID)
+	if err != nil {
+		if errors.Is(err, supabase.ErrNotFound) {
+			return nil, ErrRoomNotFound
+		}
+		return nil, fmt.Errorf("failed to get room: %w", err)
+	}
+
+	if room.HostParticipantID == "" || room.HostParticipantID != participantID {
+		return nil, ErrNotHost
+	}
+
+	newID, err := s.generateRoomID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate room ID: %w", err)
+	}
+
+	oldRoom := *room
+	room.ID = newID
+	room.LastActiveAt = s.clock.Now().UTC()
+	if err := s.db.CreateRoom(room); err != nil {
+		return nil, fmt.Errorf("failed to create room: %w", err)
+	}
+
+	if err := s.db.MoveParticipants(roomID, newID); err != nil {
+		if delErr := s.db.DeleteRoom(newID); delErr != nil {
+			log.Printf("[Room] Warning: failed to roll back room %s: %v", newID, delErr)
+		}
+		return nil, fmt.Errorf("failed to move participants: %w", err)
+	}
+
+	if err := s.db.DeleteRoom(roomID); err != nil {
+		log.Printf("[Room] Warning: failed to delete old room %s: %v", roomID, err)
+	}
+	s.cache.invalidate(roomID, newID)
+	s.messages.MoveRoom(roomID, newID)
+
+	// Tell clients on the old topic where to go, and update the lobby
+	if err := s.db.BroadcastRoomIDChanged(roomID, newID); err != nil {
+		log.Printf("Failed to broadcast room ID change for %s: %v", roomID, err)
+	}
+	if err := s.db.BroadcastRoomEvent("deleted", &oldRoom); err != nil {
+		log.Printf("Failed to broadcast room deleted for %s: %v", roomID, err)
+	}
+	if err := s.db.BroadcastRoomEvent("created", room); err != nil {
+		log.Printf("Failed to broadcast room created for %s: %v", newID, err)
+	}
+	s.webhooks.RoomDeleted(&oldRoom)
+	s.webhooks.RoomCreated(room)
+
+	return room, nil
+}
+
 // LeaveRoom removes a participant from a room.
 // If this was the last participant, the room is automatically deleted.
+// Leaving is idempotent: if the participant is already gone (e.g. a double-click
+// or a prior cleanup), this is a no-op and returns nil.
 func (s *RoomService) LeaveRoom(roomID, participantID string) error {
 	// Fetch participant info before removing (needed for broadcast)
 	participant, err := s.db.GetParticipant(participantID)
 	if err != nil {
+		if errors.Is(err, supabase.ErrNotFound) {
+			log.Printf("[Room] Participant %s already left room %s", participantID, roomID)
+			return nil
+		}
 		log.Printf("Could not fetch participant %s for broadcast: %v", participantID, err)
+	} else if participant.RoomID != roomID {
+		log.Printf("[Room] Participant %s is not in room %s, nothing to leave", participantID, roomID)
+		return nil
 	}
 
-	// Remove the participant
+	return s.removeParticipant(roomID, participantID, participant)
+}
+
+// removeParticipant removes a participant, broadcasts the leave if participant
+// is known, and deletes the room if it is now empty.
+func (s *RoomService) removeParticipant(roomID, participantID string, participant *models.Participant) error {
 	if err := s.db.RemoveParticipant(participantID); err != nil {
 		return fmt.Errorf("failed to leave room: %w", err)
 	}
+	defer s.cache.invalidate(roomID)
 
 	// Broadcast leave event so other clients update instantly
 	if participant != nil {
+		recordLeave(s.clock.Now(), participant, s.webhooks)
 		if err := s.db.BroadcastParticipantEvent(roomID, "leave", participant); err != nil {
 			log.Printf("Failed to broadcast participant leave for %s: %v", participantID, err)
 		}
+	} else {
+		s.webhooks.ParticipantLeft(&models.Participant{ID: participantID, RoomID: roomID})
 	}
 
-	// Check if room is now empty
-	count, err := s.db.CountParticipants(roomID)
+	// Fetch room info before deleting (needed for broadcast)
+	room, roomErr := s.db.GetRoom(roomID)
+
+	// Delete the room if it is now empty; the emptiness check happens in the
+	// same statement so a concurrent join can't be orphaned
+	deleted, err := s.db.DeleteRoomIfEmpty(roomID)
 	if err != nil {
-		return fmt.Errorf("failed to check participant count: %w", err)
+		return fmt.Errorf("failed to delete empty room: %w", err)
 	}
 
-	// If room is empty, delete it immediately
-	if count == 0 {
-		// Fetch room info before deleting (needed for broadcast)
-		room, roomErr := s.db.GetRoom(roomID)
-
-		if err := s.db.DeleteRoom(roomID); err != nil {
-			return fmt.Errorf("failed to delete empty room: %w", err)
+	// Broadcast room deletion so the lobby updates in real-time
+	if deleted && roomErr == nil {
+		if err := s.db.BroadcastRoomEvent("deleted", room); err != nil {
+			log.Printf("Failed to broadcast room deleted for %s: %v", roomID, err)
 		}
+	}
+	// Don't leave the room headless if the host left without transferring
+	if !deleted && roomErr == nil && room.HostParticipantID == participantID {
+		promoteHost(s.db, room)
+	}
 
-		// Broadcast room deletion so the lobby updates in real-time
-		if roomErr == nil {
-			if err := s.db.BroadcastRoomEvent("deleted", room); err != nil {
-				log.Printf("Failed to broadcast room deleted for %s: %v", roomID, err)
-			}
+	if deleted {
+		s.ForgetRoom(roomID)
+		s.messages.DeleteRoomMessages(roomID)
+		if roomErr != nil {
+			room = &models.Room{ID: roomID}
 		}
+		s.webhooks.RoomDeleted(room)
 	}
 
 	return nil
@@ -193,10 +1067,10 @@ func (s *RoomService) UpdateHeartbeat(roomID, participantID string) error {
 }
 
 // generateRoomID creates a short, URL-friendly room identifier.
-// Uses cryptographically secure random bytes encoded as hex.
-func generateRoomID() (string, error) {
-	bytes := make([]byte, 4) // 4 bytes = 8 hex characters
-	if _, err := rand.Read(bytes); err != nil {
+// Uses random bytes from s.random (cryptographically secure by default) encoded as hex.
+func (s *RoomService) generateRoomID() (string, error) {
+	bytes := make([]byte, s.settings.RoomIDBytes) // e.g. 4 bytes = 8 hex characters
+	if _, err := io.ReadFull(s.random, bytes); err != nil {
 		return "", err
 	}
 	return hex.EncodeToString(bytes), nil
@@ -204,9 +1078,19 @@ func generateRoomID() (string, error) {
 
 // generateEncryptionKey creates a 256-bit encryption key for AES-GCM.
 // Returns base64-encoded key string.
-func generateEncryptionKey() (string, error) {
+func (s *RoomService) generateEncryptionKey() (string, error) {
 	bytes := make([]byte, 32) // 32 bytes = 256 bits
-	if _, err := rand.Read(bytes); err != nil {
+	if _, err := io.ReadFull(s.random, bytes); err != nil {
+		return "", err
+	}
+	return base64Encode(bytes), nil
+}
+
+// generateKeySalt creates a 128-bit random salt for key derivation.
+// Returns base64-encoded salt string.
+func (s *RoomService) generateKeySalt() (string, error) {
+	bytes := make([]byte, 16) // 16 bytes = 128 bits
+	if _, err := io.ReadFull(s.random, bytes); err != nil {
 		return "", err
 	}
 	return base64Encode(bytes), nil
@@ -0,0 +1,1203 @@
+package services
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adi-253/Talkie/backend/internal/models"
+	"github.com/adi-253/Talkie/backend/internal/supabase"
+	"github.com/adi-253/Talkie/backend/internal/supabase/supabasetest"
+	"github.com/google/uuid"
+)
+
+func TestLockRoomBlocksNewJoins(t *testing.T) {
+	s := newTestServices(t)
+	s.seedRoom("room1")
+	host := s.join(t, "room1", "host")
+
+	room, err := s.rooms.SetRoomLocked("room1", host.ID, true)
+	if err != nil {
+		t.Fatalf("SetRoomLocked: %v", err)
+	}
+	if !room.Locked {
+		t.Error("returned room is not locked")
+	}
+	if stored, _ := s.srv.Room("room1"); !stored.Locked {
+		t.Error("stored room is not locked")
+	}
+	if got := s.srv.BroadcastsFor("lock_changed"); len(got) != 1 {
+		t.Errorf("lock_changed broadcasts = %d, want 1", len(got))
+	}
+
+	if _, _, _, err := s.rooms.JoinRoom("room1", "late", "", "", "", "", ""); !errors.Is(err, ErrRoomLocked) {
+		t.Errorf("join of locked room: err = %v, want ErrRoomLocked", err)
+	}
+
+	if _, err := s.rooms.SetRoomLocked("room1", host.ID, false); err != nil {
+		t.Fatalf("unlock: %v", err)
+	}
+	s.join(t, "room1", "late")
+}
+
+func TestLockedRoomAllowsRejoin(t *testing.T) {
+	s := newTestServices(t)
+	s.seedRoom("room1")
+	host := s.join(t, "room1", "host")
+	guest := s.join(t, "room1", "guest")
+
+	if _, err := s.rooms.SetRoomLocked("room1", host.ID, true); err != nil {
+		t.Fatalf("SetRoomLocked: %v", err)
+	}
+
+	p, _, _, err := s.rooms.JoinRoom("room1", "guest", "", "", guest.ID, s.rooms.SigningSecret(guest.ID), "")
+	if err != nil {
+		t.Fatalf("rejoin of locked room: %v", err)
+	}
+	if p.ID != guest.ID {
+		t.Errorf("rejoin created participant %s, want existing %s", p.ID, guest.ID)
+	}
+}
+
+func TestSetRoomLockedRequiresHost(t *testing.T) {
+	s := newTestServices(t)
+	s.seedRoom("room1")
+	s.join(t, "room1", "host")
+	guest := s.join(t, "room1", "guest")
+
+	if _, err := s.rooms.SetRoomLocked("room1", guest.ID, true); !errors.Is(err, ErrNotHost) {
+		t.Errorf("lock by guest: err = %v, want ErrNotHost", err)
+	}
+	if _, err := s.rooms.SetRoomLocked("missing", guest.ID, true); !errors.Is(err, ErrRoomNotFound) {
+		t.Errorf("lock of missing room: err = %v, want ErrRoomNotFound", err)
+	}
+}
+
+func TestLeaveRoomTwice(t *testing.T) {
+	s := newTestServices(t)
+	s.seedRoom("room1")
+	alice := s.join(t, "room1", "alice")
+	s.join(t, "room1", "bob")
+
+	if err := s.rooms.LeaveRoom("room1", alice.ID); err != nil {
+		t.Fatalf("first leave: %v", err)
+	}
+	if err := s.rooms.LeaveRoom("room1", alice.ID); err != nil {
+		t.Errorf("second leave: %v, want nil", err)
+	}
+
+	if got := countParticipantEvents(s.srv, "leave"); got != 1 {
+		t.Errorf("leave broadcasts = %d, want 1", got)
+	}
+	if _, ok := s.srv.Room("room1"); !ok {
+		t.Error("room deleted while bob is still in it")
+	}
+	if got := len(s.srv.Participants("room1")); got != 1 {
+		t.Errorf("participants = %d, want 1", got)
+	}
+}
+
+func TestLeaveRoomUnknownParticipant(t *testing.T) {
+	s := newTestServices(t)
+	s.seedRoom("room1")
+	s.join(t, "room1", "alice")
+	s.srv.ResetRequests()
+
+	if err := s.rooms.LeaveRoom("room1", uuid.New().String()); err != nil {
+		t.Errorf("leave of unknown participant: %v, want nil", err)
+	}
+
+	if got := countParticipantEvents(s.srv, "leave"); got != 0 {
+		t.Errorf("leave broadcasts = %d, want 0", got)
+	}
+	if got := s.srv.CountRequests("POST", "rpc/delete_room_if_empty"); got != 0 {
+		t.Errorf("delete_room_if_empty calls = %d, want 0", got)
+	}
+	if _, ok := s.srv.Room("room1"); !ok {
+		t.Error("room deleted by a leave of an unknown participant")
+	}
+}
+
+// countParticipantEvents counts participant broadcasts with the given action.
+func countParticipantEvents(srv *supabasetest.Server, action string) int {
+	n := 0
+	for _, b := range srv.BroadcastsFor("participant") {
+		if strings.Contains(string(b.Payload), `"action":"`+action+`"`) {
+			n++
+		}
+	}
+	return n
+}
+
+func slugRoomIDs(settings *RoomSettings) { settings.SlugRoomIDs = true }
+
+func TestCreateRoomWithSlugID(t *testing.T) {
+	s := newTestServices(t, slugRoomIDs)
+
+	room, err := s.rooms.CreateRoom("", "  Book Club_2024 ", 0, true, false)
+	if err != nil {
+		t.Fatalf("CreateRoom: %v", err)
+	}
+	if room.ID != "book-club-2024" {
+		t.Errorf("room ID = %q, want book-club-2024", room.ID)
+	}
+	if _, ok := s.srv.Room("book-club-2024"); !ok {
+		t.Error("slug room was not stored")
+	}
+
+	unnamed, err := s.rooms.CreateRoom("", "", 0, true, false)
+	if err != nil {
+		t.Fatalf("CreateRoom without a name: %v", err)
+	}
+	if len(unnamed.ID) != 8 {
+		t.Errorf("unnamed room ID = %q, want a random 8-character ID", unnamed.ID)
+	}
+}
+
+func TestCreateRoomSlugCollision(t *testing.T) {
+	s := newTestServices(t, slugRoomIDs)
+
+	if _, err := s.rooms.CreateRoom("", "Book Club", 0, true, false); err != nil {
+		t.Fatalf("CreateRoom: %v", err)
+	}
+	if _, err := s.rooms.CreateRoom("", "book  CLUB", 0, true, false); !errors.Is(err, ErrRoomNameTaken) {
+		t.Errorf("second room with the same slug: err = %v, want ErrRoomNameTaken", err)
+	}
+	if got := len(s.srv.Rooms()); got != 1 {
+		t.Errorf("stored rooms = %d, want 1", got)
+	}
+}
+
+func TestCreateRoomInvalidSlug(t *testing.T) {
+	s := newTestServices(t, slugRoomIDs)
+
+	for _, name := range []string{"café", "rock & roll", "a/b", "---", strings.Repeat("a", maxSlugLength+1)} {
+		if _, err := s.rooms.CreateRoom("", name, 0, true, false); !errors.Is(err, ErrInvalidRoomSlug) {
+			t.Errorf("CreateRoom(%q): err = %v, want ErrInvalidRoomSlug", name, err)
+		}
+	}
+	if got := len(s.srv.Rooms()); got != 0 {
+		t.Errorf("stored rooms = %d, want 0", got)
+	}
+}
+
+func TestCreateRoomIgnoresSlugsWhenDisabled(t *testing.T) {
+	s := newTestServices(t)
+
+	first, err := s.rooms.CreateRoom("", "Book Club", 0, true, false)
+	if err != nil {
+		t.Fatalf("CreateRoom: %v", err)
+	}
+	second, err := s.rooms.CreateRoom("", "Book Club", 0, true, false)
+	if err != nil {
+		t.Fatalf("CreateRoom with a duplicate name: %v", err)
+	}
+	if first.ID == "book-club" || first.ID == second.ID {
+		t.Errorf("room IDs = %q, %q, want distinct random IDs", first.ID, second.ID)
+	}
+}
+
+func TestCreateRoomSlugTakenConcurrently(t *testing.T) {
+	s := newTestServices(t, slugRoomIDs)
+	s.srv.OnRequest = func(req supabasetest.Request) {
+		if req.Method == "POST" && req.Path == "rooms" {
+			s.srv.OnRequest = nil
+			s.seedRoom("book-club")
+		}
+	}
+
+	if _, err := s.rooms.CreateRoom("", "Book Club", 0, true, false); !errors.Is(err, ErrRoomNameTaken) {
+		t.Errorf("slug taken between check and insert: err = %v, want ErrRoomNameTaken", err)
+	}
+}
+
+func TestCreateRoomPostsWelcomeMessage(t *testing.T) {
+	const welcome = "Messages are end-to-end encrypted and ephemeral"
+	s := newTestServices(t, func(settings *RoomSettings) { settings.WelcomeMessage = welcome })
+
+	room, err := s.rooms.CreateRoom("", "Book Club", 0, true, false)
+	if err != nil {
+		t.Fatalf("CreateRoom: %v", err)
+	}
+	s.join(t, room.ID, "first")
+
+	history := s.messages.GetMessages(room.ID, MessageFilter{})
+	if len(history) != 1 {
+		t.Fatalf("history = %+v, want only the welcome message", history)
+	}
+	if msg := history[0]; !msg.System || msg.Encrypted || msg.Content != welcome || msg.Seq != 1 {
+		t.Errorf("welcome message = %+v, want plaintext system message %q with seq 1", msg, welcome)
+	}
+}
+
+func TestCreateRoomWithoutWelcomeMessage(t *testing.T) {
+	s := newTestServices(t)
+
+	room, err := s.rooms.CreateRoom("", "Book Club", 0, true, false)
+	if err != nil {
+		t.Fatalf("CreateRoom: %v", err)
+	}
+	if history := s.messages.GetMessages(room.ID, MessageFilter{}); len(history) != 0 {
+		t.Errorf("history = %+v, want empty", history)
+	}
+}
+
+func TestObserverAppearsInPresence(t *testing.T) {
+	s := newTestServices(t)
+	s.seedRoom("room1")
+	presenter := s.join(t, "room1", "presenter")
+
+	observer, _, participants, err := s.rooms.JoinRoom("room1", "lurker", "", models.RoleObserver, "", "", "")
+	if err != nil {
+		t.Fatalf("JoinRoom as observer: %v", err)
+	}
+	if observer.Role != models.RoleObserver {
+		t.Errorf("role = %q, want observer", observer.Role)
+	}
+	if len(participants) != 2 {
+		t.Errorf("participants = %d, want presenter and observer", len(participants))
+	}
+	if stored, _ := s.srv.Participant(observer.ID); stored.Role != models.RoleObserver {
+		t.Errorf("stored role = %q, want observer", stored.Role)
+	}
+	if got := countParticipantEvents(s.srv, "join"); got != 2 {
+		t.Errorf("join broadcasts = %d, want 2", got)
+	}
+
+	// Observers still receive what others send
+	s.messages.SendMessage("room1", models.SendMessageRequest{ParticipantID: presenter.ID, Content: "slide 1"})
+	if got := len(s.messages.GetMessages("room1", MessageFilter{})); got != 1 {
+		t.Errorf("messages visible to the room = %d, want 1", got)
+	}
+}
+
+func TestJoinRejectsUnknownRole(t *testing.T) {
+	s := newTestServices(t)
+	s.seedRoom("room1")
+
+	if _, _, _, err := s.rooms.JoinRoom("room1", "x", "", "admin", "", "", ""); !errors.Is(err, ErrInvalidRole) {
+		t.Errorf("err = %v, want ErrInvalidRole", err)
+	}
+}
+
+// strPtr returns a pointer to s, for optional request fields.
+func strPtr(s string) *string { return &s }
+
+func TestUpdateParticipantStatus(t *testing.T) {
+	s := newTestServices(t)
+	s.seedRoom("room1")
+	alice := s.join(t, "room1", "alice")
+
+	p, err := s.rooms.UpdateParticipant("room1", alice.ID, models.UpdateParticipantRequest{Status: strPtr("  brb ☕ ")})
+	if err != nil {
+		t.Fatalf("set status: %v", err)
+	}
+	if p.Status != "brb ☕" {
+		t.Errorf("status = %q, want trimmed %q", p.Status, "brb ☕")
+	}
+	if _, participants, _ := s.rooms.GetRoom("room1"); len(participants) != 1 || participants[0].Status != "brb ☕" {
+		t.Errorf("presence snapshot = %+v, want alice with status", participants)
+	}
+	updates := s.srv.BroadcastsFor("participant_update")
+	if len(updates) != 1 || !strings.Contains(string(updates[0].Payload), "brb ☕") {
+		t.Errorf("participant_update broadcasts = %+v, want one carrying the status", updates)
+	}
+
+	if p, err = s.rooms.UpdateParticipant("room1", alice.ID, models.UpdateParticipantRequest{Status: strPtr("")}); err != nil {
+		t.Fatalf("clear status: %v", err)
+	}
+	if stored, _ := s.srv.Participant(alice.ID); p.Status != "" || stored.Status != "" {
+		t.Errorf("status after clearing = %q (stored %q), want empty", p.Status, stored.Status)
+	}
+}
+
+func TestUpdateParticipantStatusTooLong(t *testing.T) {
+	s := newTestServices(t)
+	s.seedRoom("room1")
+	alice := s.join(t, "room1", "alice")
+
+	// The limit counts characters, not bytes
+	if _, err := s.rooms.UpdateParticipant("room1", alice.ID, models.UpdateParticipantRequest{Status: strPtr(strings.Repeat("é", maxStatusLength))}); err != nil {
+		t.Errorf("status at the limit: %v", err)
+	}
+	_, err := s.rooms.UpdateParticipant("room1", alice.ID, models.UpdateParticipantRequest{Status: strPtr(strings.Repeat("a", maxStatusLength+1))})
+	if !errors.Is(err, ErrInvalidStatus) {
+		t.Errorf("status over the limit: err = %v, want ErrInvalidStatus", err)
+	}
+	if stored, _ := s.srv.Participant(alice.ID); stored.Status != strings.Repeat("é", maxStatusLength) {
+		t.Errorf("stored status = %q, want the previous status kept", stored.Status)
+	}
+}
+
+func TestUpdateParticipantInAnotherRoom(t *testing.T) {
+	s := newTestServices(t)
+	s.seedRoom("room1")
+	s.seedRoom("room2")
+	alice := s.join(t, "room1", "alice")
+
+	if _, err := s.rooms.UpdateParticipant("room2", alice.ID, models.UpdateParticipantRequest{Status: strPtr("away")}); !errors.Is(err, ErrParticipantNotFound) {
+		t.Errorf("err = %v, want ErrParticipantNotFound", err)
+	}
+}
+
+func TestRegenerateRoomID(t *testing.T) {
+	s := newTestServices(t)
+	s.seedRoom("room1")
+	host := s.join(t, "room1", "host")
+	guest := s.join(t, "room1", "guest")
+	s.messages.SendMessage("room1", models.SendMessageRequest{ParticipantID: guest.ID, Content: "before the move"})
+
+	room, err := s.rooms.RegenerateRoomID("room1", host.ID)
+	if err != nil {
+		t.Fatalf("RegenerateRoomID: %v", err)
+	}
+	if room.ID == "room1" || !ValidRoomID(room.ID) {
+		t.Fatalf("new room ID = %q, want a fresh valid ID", room.ID)
+	}
+
+	if _, _, err := s.rooms.GetRoom("room1"); !errors.Is(err, supabase.ErrNotFound) {
+		t.Errorf("old room ID: err = %v, want not found", err)
+	}
+	_, participants, err := s.rooms.GetRoom(room.ID)
+	if err != nil {
+		t.Fatalf("GetRoom(new): %v", err)
+	}
+	if len(participants) != 2 {
+		t.Errorf("participants in the new room = %d, want 2", len(participants))
+	}
+	if room.HostParticipantID != host.ID {
+		t.Errorf("host = %q, want %q", room.HostParticipantID, host.ID)
+	}
+
+	history := s.messages.GetMessages(room.ID, MessageFilter{})
+	if len(history) != 1 || history[0].Content != "before the move" || history[0].RoomID != room.ID {
+		t.Errorf("history in the new room = %+v, want the moved message", history)
+	}
+	if got := len(s.messages.GetMessages("room1", MessageFilter{})); got != 0 {
+		t.Errorf("messages left under the old ID = %d, want 0", got)
+	}
+	// Sequence numbers continue where the old room left off
+	msg, err := s.messages.SendMessage(room.ID, models.SendMessageRequest{ParticipantID: guest.ID, Content: "after"})
+	if err != nil || msg.Seq != 2 {
+		t.Errorf("send after the move: seq = %v, err = %v, want seq 2", msg, err)
+	}
+
+	changes := s.srv.BroadcastsFor("room_id_changed")
+	if len(changes) != 1 || changes[0].Topic != "room:room1" || !strings.Contains(string(changes[0].Payload), room.ID) {
+		t.Errorf("room_id_changed broadcasts = %+v, want one on the old topic naming %s", changes, room.ID)
+	}
+}
+
+func TestRegenerateRoomIDRequiresHost(t *testing.T) {
+	s := newTestServices(t)
+	s.seedRoom("room1")
+	s.join(t, "room1", "host")
+	guest := s.join(t, "room1", "guest")
+
+	if _, err := s.rooms.RegenerateRoomID("room1", guest.ID); !errors.Is(err, ErrNotHost) {
+		t.Errorf("err = %v, want ErrNotHost", err)
+	}
+	if rooms := s.srv.Rooms(); len(rooms) != 1 || rooms[0].ID != "room1" {
+		t.Errorf("rooms = %+v, want only room1", rooms)
+	}
+	if got := len(s.srv.Participants("room1")); got != 2 {
+		t.Errorf("participants in room1 = %d, want 2", got)
+	}
+}
+
+func TestRegenerateRoomIDRollsBackOnFailedMove(t *testing.T) {
+	s := newTestServices(t)
+	s.seedRoom("room1")
+	host := s.join(t, "room1", "host")
+	s.srv.FailNext("PATCH", "participants", http.StatusInternalServerError, nil, `{"message":"boom"}`)
+
+	if _, err := s.rooms.RegenerateRoomID("room1", host.ID); err == nil {
+		t.Fatal("RegenerateRoomID succeeded despite the failed move")
+	}
+	if rooms := s.srv.Rooms(); len(rooms) != 1 || rooms[0].ID != "room1" {
+		t.Errorf("rooms = %+v, want only room1 after rollback", rooms)
+	}
+	if got := len(s.srv.Participants("room1")); got != 1 {
+		t.Errorf("participants in room1 = %d, want 1", got)
+	}
+}
+
+func TestJoinRoomDefaultAvatar(t *testing.T) {
+	s := newTestServices(t, func(settings *RoomSettings) { settings.DefaultAvatar = "avatar2" })
+	s.seedRoom("room1")
+
+	tests := []struct {
+		name   string
+		avatar string
+		want   string
+	}{
+		{"empty uses default", "", "avatar2"},
+		{"provided is preserved", "avatar1", "avatar1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, _, _, err := s.rooms.JoinRoom("room1", "alice", tt.avatar, "", "", "", "")
+			if err != nil {
+				t.Fatalf("JoinRoom: %v", err)
+			}
+			if p.Avatar != tt.want {
+				t.Errorf("avatar = %q, want %q", p.Avatar, tt.want)
+			}
+			if stored, ok := s.srv.Participant(p.ID); !ok || stored.Avatar != tt.want {
+				t.Errorf("stored avatar = %q, want %q", stored.Avatar, tt.want)
+			}
+		})
+	}
+
+	if _, _, _, err := s.rooms.JoinRoom("room1", "mallory", "elsewhere.png", "", "", "", ""); !errors.Is(err, ErrInvalidAvatar) {
+		t.Errorf("disallowed avatar: err = %v, want ErrInvalidAvatar", err)
+	}
+}
+
+func TestJoinersSeeCurrentKeyEpoch(t *testing.T) {
+	s := newTestServices(t)
+	room, err := s.rooms.CreateRoom("", "Keys", 0, true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if room.KeyVersion != 1 || room.KeySalt == "" {
+		t.Fatalf("new room key_version = %d, key_salt = %q, want version 1 with a salt", room.KeyVersion, room.KeySalt)
+	}
+
+	host := s.join(t, room.ID, "host")
+	_, first, _, err := s.rooms.JoinRoom(room.ID, "alice", "", "", "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, second, _, err := s.rooms.JoinRoom(room.ID, "bob", "", "", "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.KeyVersion != 1 || second.KeyVersion != 1 || first.KeySalt != room.KeySalt || second.KeySalt != room.KeySalt {
+		t.Errorf("joiners got (%d, %q) and (%d, %q), want (1, %q)",
+			first.KeyVersion, first.KeySalt, second.KeyVersion, second.KeySalt, room.KeySalt)
+	}
+
+	rotated, err := s.rooms.RotateRoomKey(room.ID, host.ID)
+	if err != nil {
+		t.Fatalf("RotateRoomKey: %v", err)
+	}
+	if rotated.KeyVersion != 2 || rotated.KeySalt == "" || rotated.KeySalt == room.KeySalt {
+		t.Errorf("rotated key_version = %d, key_salt = %q, want version 2 with a fresh salt", rotated.KeyVersion, rotated.KeySalt)
+	}
+	if got := len(s.srv.BroadcastsFor("key_rotated")); got != 1 {
+		t.Errorf("key_rotated broadcasts = %d, want 1", got)
+	}
+
+	_, later, _, err := s.rooms.JoinRoom(room.ID, "carol", "", "", "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if later.KeyVersion != rotated.KeyVersion || later.KeySalt != rotated.KeySalt {
+		t.Errorf("joiner after rotation got (%d, %q), want (%d, %q)", later.KeyVersion, later.KeySalt, rotated.KeyVersion, rotated.KeySalt)
+	}
+}
+
+func TestRotateRoomKeyRequiresHost(t *testing.T) {
+	s := newTestServices(t)
+	s.seedRoom("room1")
+	s.join(t, "room1", "host")
+	guest := s.join(t, "room1", "guest")
+
+	if _, err := s.rooms.RotateRoomKey("room1", guest.ID); !errors.Is(err, ErrNotHost) {
+		t.Errorf("guest rotation: err = %v, want ErrNotHost", err)
+	}
+	if room, _ := s.srv.Room("room1"); room.KeyVersion != 1 {
+		t.Errorf("key_version = %d after refused rotation, want 1", room.KeyVersion)
+	}
+}
+
+// deterministicRooms returns a RoomService over s's database that draws its
+// randomness from the given bytes.
+func deterministicRooms(s *testServices, random []byte) *RoomService {
+	settings := RoomSettings{Avatars: []string{"avatar1", "avatar2"}, DefaultAvatar: "avatar1", RoomIDBytes: 4}
+	return NewRoomService(s.db, s.messages, nil, settings, s.clock, bytes.NewReader(random))
+}
+
+// roomRandomness returns the bytes CreateRoom reads for one room: the room ID,
+// then the encryption key, then the key salt.
+func roomRandomness(id []byte, fill byte) []byte {
+	return append(append([]byte{}, id...), bytes.Repeat([]byte{fill}, 32+16)...)
+}
+
+func TestCreateRoomUsesInjectedRandomness(t *testing.T) {
+	s := newTestServices(t)
+	rooms := deterministicRooms(s, roomRandomness([]byte{0xde, 0xad, 0xbe, 0xef}, 0x01))
+
+	room, err := rooms.CreateRoom("", "Deterministic", 0, true, false)
+	if err != nil {
+		t.Fatalf("CreateRoom: %v", err)
+	}
+	if room.ID != "deadbeef" {
+		t.Errorf("room ID = %q, want deadbeef", room.ID)
+	}
+	wantKey := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{0x01}, 32))
+	if room.EncryptionKey != wantKey {
+		t.Errorf("encryption key = %q, want %q", room.EncryptionKey, wantKey)
+	}
+	if wantSalt := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{0x01}, 16)); room.KeySalt != wantSalt {
+		t.Errorf("key salt = %q, want %q", room.KeySalt, wantSalt)
+	}
+
+	// An exhausted source fails the create instead of producing a weak ID
+	if _, err := rooms.CreateRoom("", "Exhausted", 0, true, false); err == nil {
+		t.Error("CreateRoom with no randomness left succeeded")
+	}
+}
+
+func TestCreateRoomGeneratedIDCollision(t *testing.T) {
+	s := newTestServices(t)
+	existing := s.seedRoom("deadbeef")
+	rooms := deterministicRooms(s, roomRandomness([]byte{0xde, 0xad, 0xbe, 0xef}, 0x02))
+
+	if _, err := rooms.CreateRoom("", "Collides", 0, true, false); !supabase.IsStatus(err, http.StatusConflict) {
+		t.Errorf("colliding ID: err = %v, want the insert conflict", err)
+	}
+	if room, _ := s.srv.Room("deadbeef"); room.Name != existing.Name {
+		t.Errorf("existing room was overwritten: name = %q", room.Name)
+	}
+}
+
+func TestCreateRoomInsertConflictMapsToSentinel(t *testing.T) {
+	s := newTestServices(t)
+
+	s.srv.FailNext("POST", "rooms", http.StatusConflict, nil, `{"code":"23505"}`)
+	if _, err := s.rooms.CreateRoom("standup", "Standup", 0, true, false); !errors.Is(err, ErrRoomIDTaken) {
+		t.Errorf("requested ID conflict: err = %v, want ErrRoomIDTaken", err)
+	}
+
+	// Other statuses keep their code for callers to inspect
+	s.srv.FailNext("POST", "rooms", http.StatusServiceUnavailable, nil, "")
+	if _, err := s.rooms.CreateRoom("standup", "Standup", 0, true, false); !supabase.IsStatus(err, http.StatusServiceUnavailable) {
+		t.Errorf("upstream failure: err = %v, want status 503 preserved", err)
+	}
+}
+
+func TestReusedRoomIDStartsWithEmptyHistory(t *testing.T) {
+	s := newTestServices(t)
+	if _, err := s.rooms.CreateRoom("standup", "Standup", 0, true, false); err != nil {
+		t.Fatal(err)
+	}
+	alice := s.join(t, "standup", "alice")
+	for i := 0; i < 3; i++ {
+		if _, err := s.messages.SendMessage("standup", models.SendMessageRequest{ParticipantID: alice.ID, Content: "old"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The room is deleted behind the service's back, e.g. by another instance
+	s.srv.DeleteParticipant(alice.ID)
+	s.srv.DeleteRoom("standup")
+
+	if _, err := s.rooms.CreateRoom("standup", "Standup again", 0, true, false); err != nil {
+		t.Fatalf("CreateRoom with a freed ID: %v", err)
+	}
+	if got := s.messages.GetMessages("standup", MessageFilter{}); len(got) != 0 {
+		t.Fatalf("reused room history = %d messages, want none", len(got))
+	}
+
+	bob := s.join(t, "standup", "bob")
+	msg, err := s.messages.SendMessage("standup", models.SendMessageRequest{ParticipantID: bob.ID, Content: "new"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Seq != 1 {
+		t.Errorf("first seq in the reused room = %d, want 1", msg.Seq)
+	}
+}
+
+func TestRoomPersistToggle(t *testing.T) {
+	s := newTestServices(t)
+
+	for _, persist := range []bool{false, true} {
+		room, err := s.rooms.CreateRoom("", "Room", 0, persist, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p := s.join(t, room.ID, "alice")
+		for i := 0; i < 2; i++ {
+			msg, err := s.messages.SendMessage(room.ID, models.SendMessageRequest{ParticipantID: p.ID, Content: "hi"})
+			if err != nil {
+				t.Fatalf("persist=%v: SendMessage: %v", persist, err)
+			}
+			// Relayed messages are still numbered so clients can order them
+			if msg.Seq != int64(i+1) {
+				t.Errorf("persist=%v: seq = %d, want %d", persist, msg.Seq, i+1)
+			}
+		}
+
+		got := s.messages.GetMessages(room.ID, MessageFilter{})
+		if want := map[bool]int{false: 0, true: 2}[persist]; len(got) != want {
+			t.Errorf("persist=%v: history = %d messages, want %d", persist, len(got), want)
+		}
+	}
+}
+
+func TestCreateRoomNameTemplate(t *testing.T) {
+	s := newTestServices(t, func(settings *RoomSettings) { settings.NameTemplate = "Room {n} - {date}" })
+	date := s.clock.Now().UTC().Format("2006-01-02")
+
+	for i, want := range []string{"Room 1 - " + date, "Room 2 - " + date} {
+		room, err := s.rooms.CreateRoom("", "", 0, true, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if room.Name != want {
+			t.Errorf("room %d name = %q, want %q", i+1, room.Name, want)
+		}
+	}
+
+	// An explicit name bypasses the template and doesn't advance the counter
+	named, err := s.rooms.CreateRoom("", "Book Club", 0, true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if named.Name != "Book Club" {
+		t.Errorf("named room = %q, want Book Club", named.Name)
+	}
+	next, err := s.rooms.CreateRoom("", "", 0, true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Room 3 - " + date; next.Name != want {
+		t.Errorf("next unnamed room = %q, want %q", next.Name, want)
+	}
+}
+
+func TestCreateRoomDefaultNameWithoutTemplate(t *testing.T) {
+	s := newTestServices(t)
+	room, err := s.rooms.CreateRoom("", "", 0, true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if room.Name != "Untitled Room" {
+		t.Errorf("name = %q, want Untitled Room", room.Name)
+	}
+}
+
+// roomSettingsSnapshots returns the room_settings payloads broadcast so far.
+func roomSettingsSnapshots(t *testing.T, srv *supabasetest.Server) []map[string]interface{} {
+	t.Helper()
+	var snapshots []map[string]interface{}
+	for _, b := range srv.BroadcastsFor("room_settings") {
+		var payload map[string]interface{}
+		if err := json.Unmarshal(b.Payload, &payload); err != nil {
+			t.Fatal(err)
+		}
+		snapshots = append(snapshots, payload)
+	}
+	return snapshots
+}
+
+func TestRoomSettingsBroadcastOnChange(t *testing.T) {
+	s := newTestServices(t)
+	s.seedRoom("room1")
+	host := s.join(t, "room1", "host")
+	guest := s.join(t, "room1", "guest")
+
+	if _, err := s.rooms.SetRoomLocked("room1", host.ID, true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.rooms.TransferHost("room1", host.ID, guest.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	snapshots := roomSettingsSnapshots(t, s.srv)
+	if len(snapshots) != 2 {
+		t.Fatalf("room_settings broadcasts = %d, want 2", len(snapshots))
+	}
+	lock, transfer := snapshots[0], snapshots[1]
+	if lock["room_id"] != "room1" || lock["name"] != "Test Room" || lock["locked"] != true || lock["host_participant_id"] != host.ID {
+		t.Errorf("snapshot after lock = %v, want the locked room hosted by %s", lock, host.ID)
+	}
+	if transfer["locked"] != true || transfer["host_participant_id"] != guest.ID {
+		t.Errorf("snapshot after host transfer = %v, want the locked room hosted by %s", transfer, guest.ID)
+	}
+	for _, field := range []string{"message_ttl_seconds", "persist"} {
+		if _, ok := lock[field]; !ok {
+			t.Errorf("snapshot is missing %q", field)
+		}
+	}
+
+	// A refused change broadcasts nothing
+	if _, err := s.rooms.SetRoomLocked("room1", host.ID, false); !errors.Is(err, ErrNotHost) {
+		t.Fatalf("former host unlock: err = %v, want ErrNotHost", err)
+	}
+	if got := len(roomSettingsSnapshots(t, s.srv)); got != 2 {
+		t.Errorf("room_settings broadcasts after a refused change = %d, want 2", got)
+	}
+}
+
+func TestJoinRacingRoomDeletionIsRolledBack(t *testing.T) {
+	s := newTestServices(t)
+	s.seedRoom("room1")
+
+	// Cleanup deletes the room between JoinRoom's room lookup and its insert
+	s.srv.OnRequest = func(req supabasetest.Request) {
+		if req.Method == "POST" && req.Path == "participants" {
+			s.srv.OnRequest = nil
+			s.srv.DeleteRoom("room1")
+		}
+	}
+
+	if _, _, _, err := s.rooms.JoinRoom("room1", "alice", "", "", "", "", ""); !errors.Is(err, ErrRoomNotFound) {
+		t.Fatalf("join during deletion: err = %v, want ErrRoomNotFound", err)
+	}
+	if got := s.srv.Participants("room1"); len(got) != 0 {
+		t.Errorf("participants left behind = %d, want the join rolled back", len(got))
+	}
+}
+
+func TestJoinRejectedByRoomForeignKey(t *testing.T) {
+	s := newTestServices(t)
+	s.seedRoom("room1")
+
+	s.srv.OnRequest = func(req supabasetest.Request) {
+		if req.Method == "POST" && req.Path == "participants" {
+			s.srv.OnRequest = nil
+			s.srv.DeleteRoom("room1")
+		}
+	}
+	s.srv.FailNext("POST", "participants", http.StatusConflict, nil, `{"code":"23503"}`)
+
+	if _, _, _, err := s.rooms.JoinRoom("room1", "alice", "", "", "", "", ""); !errors.Is(err, ErrRoomNotFound) {
+		t.Errorf("insert rejected after deletion: err = %v, want ErrRoomNotFound", err)
+	}
+
+	// A conflict while the room still exists is a real failure
+	s.seedRoom("room2")
+	s.srv.FailNext("POST", "participants", http.StatusConflict, nil, `{"code":"23505"}`)
+	if _, _, _, err := s.rooms.JoinRoom("room2", "bob", "", "", "", "", ""); err == nil || errors.Is(err, ErrRoomNotFound) {
+		t.Errorf("conflict in a live room: err = %v, want a join failure other than ErrRoomNotFound", err)
+	}
+}
+
+func TestRoomIDBytesIsClamped(t *testing.T) {
+	tests := []struct {
+		bytes   int
+		wantLen int
+	}{
+		{0, 2 * minRoomIDBytes},
+		{8, 16},
+		{100, maxSlugLength},
+	}
+	for _, tt := range tests {
+		s := newTestServices(t, func(settings *RoomSettings) { settings.RoomIDBytes = tt.bytes })
+		room, err := s.rooms.CreateRoom("", "", 0, true, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(room.ID) != tt.wantLen || !ValidRoomID(room.ID) {
+			t.Errorf("RoomIDBytes %d: ID %q, want a valid %d-character ID", tt.bytes, room.ID, tt.wantLen)
+		}
+	}
+}
+
+func TestJoinsPerIPAreCapped(t *testing.T) {
+	s := newTestServices(t, func(settings *RoomSettings) { settings.MaxJoinsPerIP = 2 })
+	s.seedRoom("room1")
+	s.seedRoom("room2")
+	joinFrom := func(roomID, ip string) (*models.Participant, error) {
+		p, _, _, err := s.rooms.JoinRoom(roomID, "user", "", "", "", "", ip)
+		return p, err
+	}
+
+	first, err := joinFrom("room1", "203.0.113.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := joinFrom("room1", "203.0.113.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := joinFrom("room1", "203.0.113.1"); !errors.Is(err, ErrTooManyJoins) {
+		t.Errorf("join over the cap: err = %v, want ErrTooManyJoins", err)
+	}
+	if _, err := joinFrom("room1", "198.51.100.7"); err != nil {
+		t.Errorf("join from another IP: %v", err)
+	}
+	if _, err := joinFrom("room2", "203.0.113.1"); err != nil {
+		t.Errorf("join of another room from the capped IP: %v", err)
+	}
+
+	// Rejoining an existing session doesn't take another slot
+	if _, _, _, err := s.rooms.JoinRoom("room1", "user", "", "", first.ID, s.rooms.SigningSecret(first.ID), "203.0.113.1"); err != nil {
+		t.Errorf("rejoin at the cap: %v", err)
+	}
+
+	// A leave frees a slot, as does removal by cleanup
+	if err := s.rooms.LeaveRoom("room1", first.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := joinFrom("room1", "203.0.113.1"); err != nil {
+		t.Errorf("join after a leave: %v", err)
+	}
+	s.srv.DeleteParticipant(second.ID)
+	if _, err := joinFrom("room1", "203.0.113.1"); err != nil {
+		t.Errorf("join after cleanup removed a participant: %v", err)
+	}
+}
+
+// seedHostedRoom stores a room hosted by the first of its participants, who
+// joined a minute apart in the given roles.
+func seedHostedRoom(ts *testServices, roomID string, roles ...string) []models.Participant {
+	start := ts.clock.Now().Add(-time.Hour)
+	participants := make([]models.Participant, len(roles))
+	for i, role := range roles {
+		participants[i] = models.Participant{
+			ID:           uuid.New().String(),
+			RoomID:       roomID,
+			Username:     fmt.Sprintf("user%d", i),
+			Avatar:       "avatar1",
+			JoinedAt:     start.Add(time.Duration(i) * time.Minute),
+			LastActiveAt: start,
+			Role:         role,
+		}
+	}
+	ts.srv.AddRoom(models.Room{ID: roomID, Name: "Test Room", CreatedAt: start, LastActiveAt: start, Persist: true, KeyVersion: 1,
+		HostParticipantID: participants[0].ID})
+	for _, p := range participants {
+		ts.srv.AddParticipant(p)
+	}
+	return participants
+}
+
+// hostChanges returns the host_changed broadcasts as old -> new host pairs.
+func hostChanges(t *testing.T, ts *testServices) [][2]string {
+	t.Helper()
+	var changes [][2]string
+	for _, b := range ts.srv.BroadcastsFor("host_changed") {
+		var payload struct {
+			OldHostID string `json:"old_host_id"`
+			NewHostID string `json:"host_participant_id"`
+		}
+		if err := json.Unmarshal(b.Payload, &payload); err != nil {
+			t.Fatal(err)
+		}
+		changes = append(changes, [2]string{payload.OldHostID, payload.NewHostID})
+	}
+	return changes
+}
+
+func TestTransferHost(t *testing.T) {
+	ts := newTestServices(t)
+	p := seedHostedRoom(ts, "room1", "", "")
+	host, guest := p[0], p[1]
+
+	if _, err := ts.rooms.TransferHost("room1", guest.ID, host.ID); !errors.Is(err, ErrNotHost) {
+		t.Errorf("transfer by non-host: error = %v, want ErrNotHost", err)
+	}
+
+	room, err := ts.rooms.TransferHost("room1", host.ID, guest.ID)
+	if err != nil {
+		t.Fatalf("TransferHost: %v", err)
+	}
+	if room.HostParticipantID != guest.ID {
+		t.Errorf("returned host = %s, want %s", room.HostParticipantID, guest.ID)
+	}
+	if stored, _ := ts.srv.Room("room1"); stored.HostParticipantID != guest.ID {
+		t.Errorf("stored host = %s, want %s", stored.HostParticipantID, guest.ID)
+	}
+	if got := hostChanges(t, ts); len(got) != 1 || got[0] != [2]string{host.ID, guest.ID} {
+		t.Errorf("host_changed broadcasts = %v, want one from %s to %s", got, host.ID, guest.ID)
+	}
+
+	if _, err := ts.rooms.TransferHost("room1", host.ID, guest.ID); !errors.Is(err, ErrNotHost) {
+		t.Errorf("transfer by the former host: error = %v, want ErrNotHost", err)
+	}
+}
+
+func TestTransferHostRejectsTargetsOutsideTheRoom(t *testing.T) {
+	ts := newTestServices(t)
+	p := seedHostedRoom(ts, "room1", "", models.RoleObserver)
+	host, observer := p[0], p[1]
+	outsider := seedParticipant(ts.srv, "room2", "", ts.clock.Now())
+
+	tests := []struct {
+		name   string
+		target string
+	}{
+		{"participant of another room", outsider.ID},
+		{"unknown participant", uuid.New().String()},
+		{"observer", observer.ID},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ts.rooms.TransferHost("room1", host.ID, tt.target); !errors.Is(err, ErrInvalidHostTarget) {
+				t.Errorf("error = %v, want ErrInvalidHostTarget", err)
+			}
+		})
+	}
+
+	if stored, _ := ts.srv.Room("room1"); stored.HostParticipantID != host.ID {
+		t.Errorf("host changed to %s after rejected transfers", stored.HostParticipantID)
+	}
+	if got := hostChanges(t, ts); len(got) != 0 {
+		t.Errorf("rejected transfers broadcast host_changed: %v", got)
+	}
+}
+
+func TestHostLeavingPromotesOldestParticipant(t *testing.T) {
+	ts := newTestServices(t)
+	// The observer joined before the guests but can't become host
+	p := seedHostedRoom(ts, "room1", "", models.RoleObserver, "", "")
+	host, oldestGuest := p[0], p[2]
+
+	if err := ts.rooms.LeaveRoom("room1", host.ID); err != nil {
+		t.Fatalf("LeaveRoom: %v", err)
+	}
+	if stored, _ := ts.srv.Room("room1"); stored.HostParticipantID != oldestGuest.ID {
+		t.Errorf("host after leave = %s, want oldest guest %s", stored.HostParticipantID, oldestGuest.ID)
+	}
+	if got := hostChanges(t, ts); len(got) != 1 || got[0] != [2]string{host.ID, oldestGuest.ID} {
+		t.Errorf("host_changed broadcasts = %v, want one from %s to %s", got, host.ID, oldestGuest.ID)
+	}
+}
+
+func TestHostLeavingWithOnlyObserversClearsHost(t *testing.T) {
+	ts := newTestServices(t)
+	p := seedHostedRoom(ts, "room1", "", models.RoleObserver)
+
+	if err := ts.rooms.LeaveRoom("room1", p[0].ID); err != nil {
+		t.Fatalf("LeaveRoom: %v", err)
+	}
+	if stored, _ := ts.srv.Room("room1"); stored.HostParticipantID != "" {
+		t.Errorf("host after leave = %s, want none", stored.HostParticipantID)
+	}
+}
+
+func TestCreateRoomRejectsReservedNames(t *testing.T) {
+	tests := []struct {
+		name        string
+		slugIDs     bool
+		requestedID string
+		roomName    string
+		wantErr     error
+	}{
+		{"reserved name", false, "", "admin", ErrReservedRoomName},
+		{"reserved name in another case", false, "", " ADMIN ", ErrReservedRoomName},
+		{"reserved requested ID", false, "api", "Chat", ErrReservedRoomName},
+		{"configured reserved ID", false, "official", "Chat", ErrReservedRoomName},
+		{"name slugged to a reserved ID", true, "", "_Health_", ErrReservedRoomName},
+		{"name containing a reserved word", false, "", "Admin lounge", nil},
+		{"ordinary requested ID", false, "api-design", "Chat", nil},
+		{"ordinary slugged name", true, "", "Health checks", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServices(t, func(s *RoomSettings) {
+				s.ReservedNames = []string{"admin", "API", "health", " Official "}
+				s.SlugRoomIDs = tt.slugIDs
+			})
+			room, err := ts.rooms.CreateRoom(tt.requestedID, tt.roomName, 0, true, false)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CreateRoom error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				if rooms := ts.srv.Rooms(); len(rooms) != 0 {
+					t.Errorf("reserved room was stored: %+v", rooms)
+				}
+				return
+			}
+			if _, ok := ts.srv.Room(room.ID); !ok {
+				t.Errorf("room %s was not stored", room.ID)
+			}
+		})
+	}
+}
+
+func TestParticipantDurationsAreComputed(t *testing.T) {
+	ts := newTestServices(t)
+	p := seedHostedRoom(ts, "room1", "", "")
+
+	_, participants, err := ts.rooms.GetRoom("room1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]int64{p[0].ID: 3600, p[1].ID: 59 * 60}
+	for _, participant := range participants {
+		if participant.DurationSeconds == nil || *participant.DurationSeconds != want[participant.ID] {
+			t.Errorf("%s duration = %v, want %d", participant.ID, participant.DurationSeconds, want[participant.ID])
+		}
+	}
+
+	ts.clock.Advance(90 * time.Second)
+	participant, err := ts.rooms.GetParticipant(p[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if participant.DurationSeconds == nil || *participant.DurationSeconds != 3690 {
+		t.Errorf("duration after 90s = %v, want 3690", participant.DurationSeconds)
+	}
+
+	joined := ts.join(t, "room1", "newcomer")
+	if joined.DurationSeconds == nil || *joined.DurationSeconds != 0 {
+		t.Errorf("new participant duration = %v, want 0", joined.DurationSeconds)
+	}
+}
+
+func TestCloseRoomTeardownOrder(t *testing.T) {
+	ts := newTestServices(t)
+	p := seedHostedRoom(ts, "room1", models.RoleParticipant, models.RoleParticipant)
+	host, guest := p[0], p[1]
+	if _, err := ts.messages.SendMessage("room1", models.SendMessageRequest{ParticipantID: guest.ID, Content: "before close"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Snapshot the state when the room row is deleted
+	var (
+		deletes          int
+		closedAtDelete   int
+		messagesAtDelete int
+	)
+	ts.srv.OnRequest = func(req supabasetest.Request) {
+		if req.Method != "DELETE" || req.Path != "rooms" {
+			return
+		}
+		deletes++
+		closedAtDelete = len(ts.srv.BroadcastsFor("room_closed"))
+		// A message racing the close must not be stored
+		ts.messages.PostSystemMessage("room1", "racing the close")
+		messagesAtDelete = len(ts.messages.GetMessages("room1", MessageFilter{}))
+	}
+
+	if err := ts.rooms.CloseRoom("room1", host.ID); err != nil {
+		t.Fatalf("CloseRoom: %v", err)
+	}
+	ts.srv.OnRequest = nil
+
+	if deletes != 1 {
+		t.Fatalf("room row deleted %d times, want 1", deletes)
+	}
+	if closedAtDelete != 1 {
+		t.Errorf("room_closed broadcasts before the row was deleted = %d, want 1", closedAtDelete)
+	}
+	if messagesAtDelete != 1 {
+		t.Errorf("messages when the row was deleted = %d, want only the one sent before close (purged last, nothing added)", messagesAtDelete)
+	}
+
+	// Final state: no row, no participants, no messages, and sends are refused
+	if _, ok := ts.srv.Room("room1"); ok {
+		t.Error("room row still exists")
+	}
+	if n := len(ts.srv.Participants("room1")); n != 0 {
+		t.Errorf("%d participants remain", n)
+	}
+	if n := len(ts.messages.GetMessages("room1", MessageFilter{})); n != 0 {
+		t.Errorf("%d messages remain after close", n)
+	}
+	ts.messages.PostSystemMessage("room1", "after close")
+	if n := len(ts.messages.GetMessages("room1", MessageFilter{})); n != 0 {
+		t.Errorf("message stored after close")
+	}
+
+	// The lobby hears about the deletion only after the room_closed broadcast
+	closed, deleted := -1, -1
+	for i, b := range ts.srv.Broadcasts() {
+		switch {
+		case b.Event == "room_closed":
+			closed = i
+		case b.Topic == "rooms:lobby" && strings.Contains(string(b.Payload), `"deleted"`):
+			deleted = i
+		}
+	}
+	if closed < 0 || deleted < closed {
+		t.Errorf("room_closed at broadcast %d, lobby deletion at %d; want both, closed first", closed, deleted)
+	}
+}
+
+func TestCloseRoomRequiresHost(t *testing.T) {
+	ts := newTestServices(t)
+	p := seedHostedRoom(ts, "room1", models.RoleParticipant, models.RoleParticipant)
+
+	if err := ts.rooms.CloseRoom("room1", p[1].ID); !errors.Is(err, ErrNotHost) {
+		t.Errorf("close by guest: error = %v, want ErrNotHost", err)
+	}
+	if err := ts.rooms.CloseRoom("missing", p[0].ID); !errors.Is(err, ErrRoomNotFound) {
+		t.Errorf("close of a missing room: error = %v, want ErrRoomNotFound", err)
+	}
+	if _, ok := ts.srv.Room("room1"); !ok {
+		t.Error("room was deleted by a rejected close")
+	}
+	if _, err := ts.messages.SendMessage("room1", models.SendMessageRequest{ParticipantID: p[1].ID, Content: "still open"}); err != nil {
+		t.Errorf("send after a rejected close: %v", err)
+	}
+}
+
+func TestCloseRoomFailedDeleteReopensRoom(t *testing.T) {
+	ts := newTestServices(t)
+	p := seedHostedRoom(ts, "room1", models.RoleParticipant, models.RoleParticipant)
+
+	ts.srv.FailNext("DELETE", "rooms", http.StatusInternalServerError, nil, "boom")
+	if err := ts.rooms.CloseRoom("room1", p[0].ID); err == nil {
+		t.Fatal("CloseRoom succeeded despite the failed delete")
+	}
+	if _, err := ts.messages.SendMessage("room1", models.SendMessageRequest{ParticipantID: p[1].ID, Content: "still open"}); err != nil {
+		t.Errorf("send after a failed close: %v, want the room usable", err)
+	}
+}
+
+func TestConcurrentJoinsFromOneIPRespectCap(t *testing.T) {
+	s := newTestServices(t, func(settings *RoomSettings) { settings.MaxJoinsPerIP = 1 })
+	s.seedRoom("room1")
+	const ip = "203.0.113.1"
+
+	// A second join runs while the first join's insert is in flight
+	var racingErr error
+	raced := false
+	s.srv.OnRequest = func(req supabasetest.Request) {
+		if raced || req.Method != "POST" || req.Path != "participants" {
+			return
+		}
+		raced = true
+		_, _, _, racingErr = s.rooms.JoinRoom("room1", "racer", "", "", "", "", ip)
+	}
+	if _, _, _, err := s.rooms.JoinRoom("room1", "first", "", "", "", "", ip); err != nil {
+		t.Fatalf("first join: %v", err)
+	}
+	s.srv.OnRequest = nil
+
+	if !raced {
+		t.Fatal("the racing join never ran")
+	}
+	if !errors.Is(racingErr, ErrTooManyJoins) {
+		t.Errorf("racing join: err = %v, want ErrTooManyJoins", racingErr)
+	}
+	if n := len(s.srv.Participants("room1")); n != 1 {
+		t.Errorf("participants from one IP = %d, want 1", n)
+	}
+}
+
+func TestCleanupForgetsIPJoinsOfDeletedRooms(t *testing.T) {
+	s := newTestServices(t, func(settings *RoomSettings) { settings.MaxJoinsPerIP = 1 })
+	s.seedRoom("stale")
+	p, _, _, err := s.rooms.JoinRoom("stale", "user", "", "", "", "", "203.0.113.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cleanup := newTestCleanup(s, 2*time.Minute, 10*time.Minute, 0)
+
+	s.srv.DeleteParticipant(p.ID)
+	s.clock.Advance(11 * time.Minute)
+	cleanup.cleanupRooms(s.clock.Now().Add(-10 * time.Minute))
+
+	if _, ok := s.srv.Room("stale"); ok {
+		t.Fatal("stale room was not deleted")
+	}
+	s.rooms.ipJoins.mu.Lock()
+	defer s.rooms.ipJoins.mu.Unlock()
+	if joins, ok := s.rooms.ipJoins.joins["stale"]; ok {
+		t.Errorf("IP joins of the deleted room are still tracked: %v", joins)
+	}
+}
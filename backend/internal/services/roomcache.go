@@ -0,0 +1,81 @@
+package services
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/adi-253/Talkie/backend/internal/models"
+)
+
+// roomCache holds recently fetched rooms and their participants for a short
+// TTL, so popular rooms don't hit Supabase on every GET. RoomService
+// invalidates a room whenever it mutates it, and cleanup does so through
+// RoomService.InvalidateRoom and RoomService.ForgetRoom.
+type roomCache struct {
+	ttl   time.Duration
+	clock Clock
+
+	mu      sync.Mutex
+	entries map[string]roomCacheEntry
+}
+
+type roomCacheEntry struct {
+	room         models.Room
+	participants []models.Participant
+	expiresAt    time.Time
+}
+
+// newRoomCache creates a cache with the given TTL (0 disables caching).
+func newRoomCache(ttl time.Duration, clock Clock) *roomCache {
+	return &roomCache{ttl: ttl, clock: clock, entries: make(map[string]roomCacheEntry)}
+}
+
+// get returns copies of a cached room and its participants if present and unexpired.
+func (c *roomCache) get(roomID string) (*models.Room, []models.Participant, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[roomID]
+	if !ok {
+		return nil, nil, false
+	}
+	if !c.clock.Now().Before(entry.expiresAt) {
+		delete(c.entries, roomID)
+		return nil, nil, false
+	}
+	room := entry.room
+	return &room, slices.Clone(entry.participants), true
+}
+
+// put caches copies of a room and its participants.
+func (c *roomCache) put(room *models.Room, participants []models.Participant) {
+	if c.ttl <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.clock.Now()
+	c.entries[room.ID] = roomCacheEntry{
+		room:         *room,
+		participants: slices.Clone(participants),
+		expiresAt:    now.Add(c.ttl),
+	}
+
+	// Drop expired entries so rooms that are no longer read don't accumulate
+	for id, entry := range c.entries {
+		if !now.Before(entry.expiresAt) {
+			delete(c.entries, id)
+		}
+	}
+}
+
+// invalidate drops the cached entries for the given rooms.
+func (c *roomCache) invalidate(roomIDs ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, id := range roomIDs {
+		delete(c.entries, id)
+	}
+}
@@ -0,0 +1,167 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/adi-253/Talkie/backend/internal/models"
+	"github.com/adi-253/Talkie/backend/internal/supabase"
+	"github.com/adi-253/Talkie/backend/internal/supabase/supabasetest"
+)
+
+// newCachedServices creates test services whose GetRoom cache lives for ttl.
+func newCachedServices(t *testing.T, ttl time.Duration) *testServices {
+	t.Helper()
+	return newTestServices(t, func(s *RoomSettings) { s.CacheTTL = ttl })
+}
+
+// getRoomFetches calls GetRoom and returns the participants it reports and
+// how many requests it made to the database.
+func getRoomFetches(t *testing.T, ts *testServices, roomID string) ([]models.Participant, int) {
+	t.Helper()
+	ts.srv.ResetRequests()
+	_, participants, err := ts.rooms.GetRoom(roomID)
+	if err != nil {
+		t.Fatalf("GetRoom(%s): %v", roomID, err)
+	}
+	return participants, len(ts.srv.Requests())
+}
+
+func TestGetRoomCacheHitWithinTTL(t *testing.T) {
+	ts := newCachedServices(t, 2*time.Second)
+	seedHostedRoom(ts, "room1", "", "")
+
+	if _, n := getRoomFetches(t, ts, "room1"); n == 0 {
+		t.Fatal("first GetRoom did not query the database")
+	}
+	ts.clock.Advance(time.Second)
+	participants, n := getRoomFetches(t, ts, "room1")
+	if n != 0 {
+		t.Errorf("GetRoom within the TTL made %d requests, want a cache hit", n)
+	}
+	if len(participants) != 2 {
+		t.Errorf("cached participants = %d, want 2", len(participants))
+	}
+
+	// Cached copies are not shared with callers
+	participants[0].Username = "mallory"
+	if again, _ := getRoomFetches(t, ts, "room1"); again[0].Username == "mallory" {
+		t.Error("mutating a returned participant changed the cached entry")
+	}
+}
+
+func TestGetRoomCacheExpiresAfterTTL(t *testing.T) {
+	ts := newCachedServices(t, 2*time.Second)
+	seedHostedRoom(ts, "room1", "")
+
+	getRoomFetches(t, ts, "room1")
+	// A participant added behind the service's back is only seen after expiry
+	seedParticipant(ts.srv, "room1", "", ts.clock.Now())
+
+	ts.clock.Advance(time.Second)
+	if participants, n := getRoomFetches(t, ts, "room1"); n != 0 || len(participants) != 1 {
+		t.Errorf("before expiry: %d participants with %d requests, want 1 from the cache", len(participants), n)
+	}
+	ts.clock.Advance(time.Second)
+	if participants, n := getRoomFetches(t, ts, "room1"); n == 0 || len(participants) != 2 {
+		t.Errorf("at expiry: %d participants with %d requests, want 2 refetched", len(participants), n)
+	}
+}
+
+func TestGetRoomCacheDisabledWithoutTTL(t *testing.T) {
+	ts := newCachedServices(t, 0)
+	seedHostedRoom(ts, "room1", "")
+
+	for i := 0; i < 2; i++ {
+		if _, n := getRoomFetches(t, ts, "room1"); n == 0 {
+			t.Errorf("GetRoom %d served from a disabled cache", i)
+		}
+	}
+}
+
+func TestGetRoomCacheInvalidatedOnMutation(t *testing.T) {
+	ts := newCachedServices(t, time.Minute)
+	p := seedHostedRoom(ts, "room1", "", "")
+	host, guest := p[0], p[1]
+
+	getRoomFetches(t, ts, "room1")
+	if _, err := ts.rooms.TransferHost("room1", host.ID, guest.ID); err != nil {
+		t.Fatal(err)
+	}
+	room, _, err := ts.rooms.GetRoom("room1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if room.HostParticipantID != guest.ID {
+		t.Errorf("host after transfer = %s, want %s (stale cache)", room.HostParticipantID, guest.ID)
+	}
+
+	newcomer := ts.join(t, "room1", "newcomer")
+	participants, _ := getRoomFetches(t, ts, "room1")
+	found := false
+	for _, p := range participants {
+		found = found || p.ID == newcomer.ID
+	}
+	if !found {
+		t.Error("participant who joined is missing from a cached GetRoom")
+	}
+}
+
+func TestGetRoomCachedDuringJoinIsInvalidated(t *testing.T) {
+	ts := newCachedServices(t, time.Minute)
+	ts.seedRoom("room1")
+
+	// GetRoom runs while the insert and the host claim are in flight
+	ts.srv.OnRequest = func(req supabasetest.Request) {
+		if (req.Method == "POST" && req.Path == "participants") || (req.Method == "PATCH" && req.Path == "rooms") {
+			if _, _, err := ts.rooms.GetRoom("room1"); err != nil {
+				t.Errorf("concurrent GetRoom: %v", err)
+			}
+		}
+	}
+	newcomer := ts.join(t, "room1", "newcomer")
+	ts.srv.OnRequest = nil
+
+	room, participants, err := ts.rooms.GetRoom("room1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(participants) != 1 || participants[0].ID != newcomer.ID {
+		t.Errorf("participants after join = %+v, want the newcomer (stale cache)", participants)
+	}
+	if room.HostParticipantID != newcomer.ID {
+		t.Errorf("host after join = %q, want %s (stale cache)", room.HostParticipantID, newcomer.ID)
+	}
+}
+
+func TestGetRoomCacheInvalidatedOnDelete(t *testing.T) {
+	ts := newCachedServices(t, time.Minute)
+	p := seedHostedRoom(ts, "room1", "")
+
+	getRoomFetches(t, ts, "room1")
+	if err := ts.rooms.CloseRoom("room1", p[0].ID); err != nil {
+		t.Fatalf("CloseRoom: %v", err)
+	}
+	if _, _, err := ts.rooms.GetRoom("room1"); !errors.Is(err, supabase.ErrNotFound) {
+		t.Errorf("GetRoom after delete: error = %v, want not found", err)
+	}
+}
+
+func TestGetRoomCacheInvalidatedByCleanup(t *testing.T) {
+	ts := newCachedServices(t, time.Minute)
+	seedHostedRoom(ts, "room1", "", "")
+	active := seedParticipant(ts.srv, "room1", "", ts.clock.Now())
+	cleanup := newTestCleanup(ts, 5*time.Minute, 10*time.Minute, 0)
+
+	if participants, _ := getRoomFetches(t, ts, "room1"); len(participants) != 3 {
+		t.Fatalf("participants before cleanup = %d, want 3", len(participants))
+	}
+	// The seeded host and guest have been idle for an hour
+	cleanup.cleanupParticipants(ts.clock.Now().Add(-5 * time.Minute))
+
+	participants, _ := getRoomFetches(t, ts, "room1")
+	if len(participants) != 1 || participants[0].ID != active.ID {
+		t.Errorf("GetRoom after cleanup lists %d participants, want only the active one (stale cache)", len(participants))
+	}
+}
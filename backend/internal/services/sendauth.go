@@ -0,0 +1,17 @@
+package services
+
+// SendAuthorizer decides who may send messages in a room, e.g. to allow
+// sends only during business hours or only from allowlisted participants.
+// It is consulted after the built-in role and signature checks and before a
+// message is stored. A denial's reason is returned to the sender.
+type SendAuthorizer interface {
+	AuthorizeSend(roomID, participantID string) (allowed bool, reason string)
+}
+
+// AllowAllSendAuthorizer lets everyone send. It is the default SendAuthorizer.
+type AllowAllSendAuthorizer struct{}
+
+// AuthorizeSend implements SendAuthorizer.
+func (AllowAllSendAuthorizer) AuthorizeSend(string, string) (bool, string) {
+	return true, ""
+}
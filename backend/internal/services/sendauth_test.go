@@ -0,0 +1,85 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/adi-253/Talkie/backend/internal/models"
+)
+
+// denyParticipant is a SendAuthorizer that refuses one participant and
+// records every participant it was asked about.
+type denyParticipant struct {
+	denied string
+	reason string
+	asked  []string
+}
+
+func (a *denyParticipant) AuthorizeSend(roomID, participantID string) (bool, string) {
+	a.asked = append(a.asked, participantID)
+	if participantID == a.denied {
+		return false, a.reason
+	}
+	return true, ""
+}
+
+func TestSendAuthorizerDeniesOneParticipant(t *testing.T) {
+	srv, db := newTestDB(t)
+	clock := newFakeClock()
+	alice := seedParticipant(srv, "room1", models.RoleParticipant, clock.Now())
+	bob := seedParticipant(srv, "room1", models.RoleParticipant, clock.Now())
+	auth := &denyParticipant{denied: bob.ID, reason: "outside business hours"}
+	messages := NewMessageService(db, MessageLimits{}, MessageRetention{}, clock, NewMessageSigner([]byte(testSigningKey)), auth)
+
+	if _, err := messages.SendMessage("room1", models.SendMessageRequest{ParticipantID: alice.ID, Content: "hi"}); err != nil {
+		t.Errorf("allowed participant: %v", err)
+	}
+	_, err := messages.SendMessage("room1", models.SendMessageRequest{ParticipantID: bob.ID, Content: "hi"})
+	if !errors.Is(err, ErrSendDenied) || !strings.Contains(err.Error(), "outside business hours") {
+		t.Errorf("denied participant: error = %v, want ErrSendDenied with the reason", err)
+	}
+
+	stored := messages.GetMessages("room1", MessageFilter{})
+	if len(stored) != 1 || stored[0].ParticipantID != alice.ID {
+		t.Errorf("stored messages = %+v, want only alice's", stored)
+	}
+}
+
+func TestSendAuthorizerDenialWithoutReason(t *testing.T) {
+	srv, db := newTestDB(t)
+	clock := newFakeClock()
+	bob := seedParticipant(srv, "room1", models.RoleParticipant, clock.Now())
+	messages := NewMessageService(db, MessageLimits{}, MessageRetention{}, clock, NewMessageSigner([]byte(testSigningKey)),
+		&denyParticipant{denied: bob.ID})
+
+	if _, err := messages.SendMessage("room1", models.SendMessageRequest{ParticipantID: bob.ID, Content: "hi"}); err != ErrSendDenied {
+		t.Errorf("error = %v, want bare ErrSendDenied", err)
+	}
+}
+
+func TestSendAuthorizerRunsAfterBuiltInChecks(t *testing.T) {
+	srv, db := newTestDB(t)
+	clock := newFakeClock()
+	observer := seedParticipant(srv, "room1", models.RoleObserver, clock.Now())
+	auth := &denyParticipant{}
+	messages := NewMessageService(db, MessageLimits{}, MessageRetention{}, clock, NewMessageSigner([]byte(testSigningKey)), auth)
+
+	if _, err := messages.SendMessage("room1", models.SendMessageRequest{ParticipantID: observer.ID, Content: "hi"}); !errors.Is(err, ErrForbidden) {
+		t.Errorf("observer: error = %v, want ErrForbidden", err)
+	}
+	if len(auth.asked) != 0 {
+		t.Errorf("authorizer consulted for a send the role check refused: %v", auth.asked)
+	}
+}
+
+func TestNilSendAuthorizerAllowsAll(t *testing.T) {
+	srv, db := newTestDB(t)
+	clock := newFakeClock()
+	alice := seedParticipant(srv, "room1", models.RoleParticipant, clock.Now())
+	messages := NewMessageService(db, MessageLimits{}, MessageRetention{}, clock, NewMessageSigner([]byte(testSigningKey)), nil)
+
+	if _, err := messages.SendMessage("room1", models.SendMessageRequest{ParticipantID: alice.ID, Content: "hi"}); err != nil {
+		t.Errorf("default authorizer: %v", err)
+	}
+}
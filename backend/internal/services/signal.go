@@ -0,0 +1,118 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/adi-253/Talkie/backend/internal/models"
+	"github.com/adi-253/Talkie/backend/internal/supabase"
+)
+
+// validSignals is the bounded set of signal types participants may send.
+var validSignals = map[string]bool{
+	models.SignalRaiseHand: true,
+	models.SignalLowerHand: true,
+	models.SignalThumbsUp:  true,
+	models.SignalClap:      true,
+	models.SignalHeart:     true,
+	models.SignalLaugh:     true,
+}
+
+// signalKey identifies one participant's signal of one type.
+type signalKey struct {
+	participantID string
+	signal        string
+}
+
+// SignalService tracks transient participant signals (raised hands and
+// reactions) per room in memory and broadcasts each one as a signal event.
+// Signals clear automatically after a TTL; clients use expires_at to hide them.
+type SignalService struct {
+	db *supabase.Client
+
+	// signals stores active signals per room: roomID -> key -> signal
+	signals map[string]map[signalKey]models.ParticipantSignal
+	ttl     time.Duration
+	clock   Clock
+	mu      sync.Mutex
+}
+
+// NewSignalService creates a new SignalService instance.
+// - ttl: how long a signal stays active unless cleared
+func NewSignalService(db *supabase.Client, ttl time.Duration, clock Clock) *SignalService {
+	return &SignalService{
+		db:      db,
+		signals: make(map[string]map[signalKey]models.ParticipantSignal),
+		ttl:     ttl,
+		clock:   clock,
+	}
+}
+
+// SendSignal records a participant's signal and broadcasts it to the room.
+// lower_hand clears a raised hand. Returns ErrInvalidSignal for unknown types
+// and ErrParticipantNotFound if the participant isn't in the room.
+func (s *SignalService) SendSignal(roomID, participantID, signal string) (*models.ParticipantSignal, error) {
+	if !validSignals[signal] {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidSignal, signal)
+	}
+
+	participant, err := s.db.GetParticipant(participantID)
+	if err != nil {
+		if errors.Is(err, supabase.ErrNotFound) {
+			return nil, ErrParticipantNotFound
+		}
+		return nil, fmt.Errorf("failed to get participant: %w", err)
+	}
+	if participant.RoomID != roomID {
+		return nil, ErrParticipantNotFound
+	}
+
+	entry := models.ParticipantSignal{
+		ParticipantID: participantID,
+		Signal:        signal,
+		ExpiresAt:     s.clock.Now().UTC().Add(s.ttl),
+	}
+
+	s.mu.Lock()
+	if signal == models.SignalLowerHand {
+		delete(s.signals[roomID], signalKey{participantID, models.SignalRaiseHand})
+		if len(s.signals[roomID]) == 0 {
+			delete(s.signals, roomID)
+		}
+	} else {
+		if s.signals[roomID] == nil {
+			s.signals[roomID] = make(map[signalKey]models.ParticipantSignal)
+		}
+		s.signals[roomID][signalKey{participantID, signal}] = entry
+	}
+	s.mu.Unlock()
+
+	if err := s.db.BroadcastSignal(roomID, &entry); err != nil {
+		log.Printf("Failed to broadcast signal for %s: %v", participantID, err)
+	}
+	return &entry, nil
+}
+
+// GetSignals returns the active signals in a room, dropping expired ones.
+func (s *SignalService) GetSignals(roomID string) []models.ParticipantSignal {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.clock.Now().UTC()
+	result := []models.ParticipantSignal{}
+	for key, entry := range s.signals[roomID] {
+		if !now.Before(entry.ExpiresAt) {
+			delete(s.signals[roomID], key)
+			continue
+		}
+		result = append(result, entry)
+	}
+
+	if len(s.signals[roomID]) == 0 {
+		delete(s.signals, roomID)
+	}
+	return result
+}
@@ -0,0 +1,125 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/adi-253/Talkie/backend/internal/models"
+)
+
+// activeSignals returns the signal types currently active per participant.
+func activeSignals(s *SignalService, roomID string) map[string][]string {
+	active := map[string][]string{}
+	for _, entry := range s.GetSignals(roomID) {
+		active[entry.ParticipantID] = append(active[entry.ParticipantID], entry.Signal)
+	}
+	return active
+}
+
+func TestSignalRaiseAndLowerHand(t *testing.T) {
+	srv, db := newTestDB(t)
+	clock := newFakeClock()
+	signals := NewSignalService(db, time.Minute, clock)
+	alice := seedParticipant(srv, "room1", "", clock.Now())
+
+	entry, err := signals.SendSignal("room1", alice.ID, models.SignalRaiseHand)
+	if err != nil {
+		t.Fatalf("raise_hand: %v", err)
+	}
+	if !entry.ExpiresAt.Equal(clock.Now().Add(time.Minute)) {
+		t.Errorf("ExpiresAt = %v, want now + TTL", entry.ExpiresAt)
+	}
+	if got := activeSignals(signals, "room1")[alice.ID]; len(got) != 1 || got[0] != models.SignalRaiseHand {
+		t.Fatalf("active signals after raise = %v, want [raise_hand]", got)
+	}
+
+	if _, err := signals.SendSignal("room1", alice.ID, models.SignalLowerHand); err != nil {
+		t.Fatalf("lower_hand: %v", err)
+	}
+	if got := signals.GetSignals("room1"); len(got) != 0 {
+		t.Errorf("active signals after lower = %v, want none", got)
+	}
+
+	broadcasts := srv.BroadcastsFor("signal")
+	if len(broadcasts) != 2 {
+		t.Fatalf("signal broadcasts = %d, want 2", len(broadcasts))
+	}
+	for i, want := range []string{models.SignalRaiseHand, models.SignalLowerHand} {
+		var payload struct {
+			ParticipantID string `json:"participant_id"`
+			Signal        string `json:"signal"`
+		}
+		if err := json.Unmarshal(broadcasts[i].Payload, &payload); err != nil {
+			t.Fatal(err)
+		}
+		if broadcasts[i].Topic != "room:room1" || payload.ParticipantID != alice.ID || payload.Signal != want {
+			t.Errorf("broadcast %d = %s %s, want %s from %s on room:room1", i, broadcasts[i].Topic, broadcasts[i].Payload, want, alice.ID)
+		}
+	}
+}
+
+func TestSignalsExpireAfterTTL(t *testing.T) {
+	srv, db := newTestDB(t)
+	clock := newFakeClock()
+	signals := NewSignalService(db, time.Minute, clock)
+	alice := seedParticipant(srv, "room1", "", clock.Now())
+	bob := seedParticipant(srv, "room1", "", clock.Now())
+
+	if _, err := signals.SendSignal("room1", alice.ID, models.SignalRaiseHand); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(30 * time.Second)
+	if _, err := signals.SendSignal("room1", bob.ID, models.SignalClap); err != nil {
+		t.Fatal(err)
+	}
+
+	clock.Advance(30 * time.Second)
+	active := activeSignals(signals, "room1")
+	if _, ok := active[alice.ID]; ok {
+		t.Errorf("alice's raised hand is still active at its TTL")
+	}
+	if got := active[bob.ID]; len(got) != 1 || got[0] != models.SignalClap {
+		t.Errorf("bob's signals = %v, want [clap] before its TTL", got)
+	}
+
+	clock.Advance(30 * time.Second)
+	if got := signals.GetSignals("room1"); len(got) != 0 {
+		t.Errorf("active signals after every TTL = %v, want none", got)
+	}
+}
+
+func TestSendSignalRejectsUnknownTypesAndOutsiders(t *testing.T) {
+	srv, db := newTestDB(t)
+	clock := newFakeClock()
+	signals := NewSignalService(db, time.Minute, clock)
+	alice := seedParticipant(srv, "room1", "", clock.Now())
+	outsider := seedParticipant(srv, "room2", "", clock.Now())
+
+	tests := []struct {
+		name          string
+		participantID string
+		signal        string
+		want          error
+	}{
+		{"unknown type", alice.ID, "wave", ErrInvalidSignal},
+		{"empty type", alice.ID, "", ErrInvalidSignal},
+		{"participant from another room", outsider.ID, models.SignalRaiseHand, ErrParticipantNotFound},
+		{"unknown participant", "00000000-0000-0000-0000-000000000000", models.SignalRaiseHand, ErrParticipantNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := signals.SendSignal("room1", tt.participantID, tt.signal); !errors.Is(err, tt.want) {
+				t.Errorf("SendSignal error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	if got := signals.GetSignals("room1"); len(got) != 0 {
+		t.Errorf("rejected signals were stored: %v", got)
+	}
+	if n := len(srv.BroadcastsFor("signal")); n != 0 {
+		t.Errorf("rejected signals broadcast %d times", n)
+	}
+}
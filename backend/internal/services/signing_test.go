@@ -0,0 +1,80 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"testing"
+
+	"github.com/adi-253/Talkie/backend/internal/models"
+)
+
+// sign returns the signature a client holding secret makes over a message.
+func sign(t *testing.T, secret, roomID, participantID, content string) string {
+	t.Helper()
+	key, err := base64.StdEncoding.DecodeString(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(roomID + "\n" + participantID + "\n" + content))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestMessageSignerAuthenticate(t *testing.T) {
+	signer := NewMessageSigner([]byte(testSigningKey))
+	secret := signer.Secret("alice")
+
+	if !signer.Authenticate("alice", secret) {
+		t.Error("issued secret was not accepted")
+	}
+	if signer.Authenticate("bob", secret) {
+		t.Error("alice's secret authenticated bob")
+	}
+	if signer.Authenticate("", secret) || signer.Authenticate("alice", "not base64!") {
+		t.Error("malformed credential was accepted")
+	}
+	if NewMessageSigner([]byte("another key")).Authenticate("alice", secret) {
+		t.Error("secret from another server key was accepted")
+	}
+}
+
+func TestSignedRoomVerifiesMessages(t *testing.T) {
+	srv, db := newTestDB(t)
+	clock := newFakeClock()
+	messages := newTestMessageService(db, MessageLimits{}, MessageRetention{}, clock)
+	alice := seedParticipant(srv, "signed", models.RoleParticipant, clock.Now())
+	bob := seedParticipant(srv, "signed", models.RoleParticipant, clock.Now())
+	messages.SetRoomRequireSignatures("signed", true)
+	aliceSecret := messages.SigningSecret(alice.ID)
+
+	tests := []struct {
+		name      string
+		sender    string
+		signature string
+		wantErr   error
+	}{
+		{"valid signature", alice.ID, sign(t, aliceSecret, "signed", alice.ID, "hello"), nil},
+		{"unsigned", alice.ID, "", ErrInvalidSignature},
+		{"forged as another participant", bob.ID, sign(t, aliceSecret, "signed", bob.ID, "hello"), ErrInvalidSignature},
+		{"signed for another room", alice.ID, sign(t, aliceSecret, "other", alice.ID, "hello"), ErrInvalidSignature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := messages.SendMessage("signed", models.SendMessageRequest{ParticipantID: tt.sender, Content: "hello", Signature: tt.signature})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+	if got := len(messages.GetMessages("signed", MessageFilter{})); got != 1 {
+		t.Errorf("stored messages = %d, want only the validly signed one", got)
+	}
+
+	// Rooms that don't opt in accept unsigned messages
+	carol := seedParticipant(srv, "open", models.RoleParticipant, clock.Now())
+	if _, err := messages.SendMessage("open", models.SendMessageRequest{ParticipantID: carol.ID, Content: "hi"}); err != nil {
+		t.Errorf("unsigned message in an opt-out room: %v", err)
+	}
+}
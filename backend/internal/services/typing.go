@@ -0,0 +1,112 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/adi-253/Talkie/backend/internal/models"
+	"github.com/adi-253/Talkie/backend/internal/supabase"
+)
+
+// TypingService tracks short-lived typing state per room in memory.
+// WebSocket clients see typing indicators via Supabase Realtime; this lets
+// polling clients see them too.
+type TypingService struct {
+	db *supabase.Client
+
+	// typing stores typing state per room: roomID -> participantID -> entry
+	typing map[string]map[string]models.TypingParticipant
+	ttl    time.Duration
+	clock  Clock
+	mu     sync.Mutex
+}
+
+// NewTypingService creates a new TypingService instance.
+// - ttl: how long a typing update stays valid without being refreshed
+func NewTypingService(db *supabase.Client, ttl time.Duration, clock Clock) *TypingService {
+	return &TypingService{
+		db:     db,
+		typing: make(map[string]map[string]models.TypingParticipant),
+		ttl:    ttl,
+		clock:  clock,
+	}
+}
+
+// SetTyping records that a participant started or stopped typing in a room,
+// under the participant's current username. Returns ErrParticipantNotFound
+// if the participant isn't in the room.
+func (s *TypingService) SetTyping(roomID, participantID string, isTyping bool) error {
+	participant, err := s.db.GetParticipant(participantID)
+	if err != nil {
+		if errors.Is(err, supabase.ErrNotFound) {
+			return ErrParticipantNotFound
+		}
+		return fmt.Errorf("failed to get participant: %w", err)
+	}
+	if participant.RoomID != roomID {
+		return ErrParticipantNotFound
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.clock.Now().UTC()
+	// Drop expired entries so rooms that are no longer polled don't accumulate
+	for id := range s.typing {
+		s.prune(id, now)
+	}
+
+	if !isTyping {
+		delete(s.typing[roomID], participantID)
+		if len(s.typing[roomID]) == 0 {
+			delete(s.typing, roomID)
+		}
+		return nil
+	}
+
+	if s.typing[roomID] == nil {
+		s.typing[roomID] = make(map[string]models.TypingParticipant)
+	}
+	s.typing[roomID][participantID] = models.TypingParticipant{
+		ParticipantID: participantID,
+		Username:      participant.Username,
+		ExpiresAt:     now.Add(s.ttl),
+	}
+	return nil
+}
+
+// GetTyping returns the participants currently typing in a room,
+// dropping any entries that have expired.
+func (s *TypingService) GetTyping(roomID string) []models.TypingParticipant {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.prune(roomID, s.clock.Now().UTC())
+	result := []models.TypingParticipant{}
+	for _, entry := range s.typing[roomID] {
+		result = append(result, entry)
+	}
+	return result
+}
+
+// ForgetRoom drops the typing state of a deleted room.
+func (s *TypingService) ForgetRoom(roomID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.typing, roomID)
+}
+
+// prune drops a room's entries that expired by now, and the room itself once
+// it has none left. The caller must hold s.mu.
+func (s *TypingService) prune(roomID string, now time.Time) {
+	for id, entry := range s.typing[roomID] {
+		if !now.Before(entry.ExpiresAt) {
+			delete(s.typing[roomID], id)
+		}
+	}
+	if len(s.typing[roomID]) == 0 {
+		delete(s.typing, roomID)
+	}
+}
@@ -0,0 +1,151 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTypingStateSetAndCleared(t *testing.T) {
+	srv, db := newTestDB(t)
+	clock := newFakeClock()
+	typing := NewTypingService(db, time.Minute, clock)
+	alice := seedParticipant(srv, "room1", "", clock.Now())
+	bob := seedParticipant(srv, "room1", "", clock.Now())
+	carol := seedParticipant(srv, "room2", "", clock.Now())
+
+	for _, p := range []struct{ roomID, id string }{{"room1", alice.ID}, {"room1", bob.ID}, {"room2", carol.ID}} {
+		if err := typing.SetTyping(p.roomID, p.id, true); err != nil {
+			t.Fatalf("SetTyping(%s): %v", p.id, err)
+		}
+	}
+
+	if got := typing.GetTyping("room1"); len(got) != 2 {
+		t.Fatalf("typing in room1 = %+v, want alice and bob", got)
+	}
+
+	if err := typing.SetTyping("room1", alice.ID, false); err != nil {
+		t.Fatal(err)
+	}
+	got := typing.GetTyping("room1")
+	if len(got) != 1 || got[0].ParticipantID != bob.ID || got[0].Username != bob.Username {
+		t.Errorf("typing after alice stopped = %+v, want only bob", got)
+	}
+	if got := typing.GetTyping("room2"); len(got) != 1 {
+		t.Errorf("typing in room2 = %+v, want carol", got)
+	}
+	if got := typing.GetTyping("empty"); got == nil || len(got) != 0 {
+		t.Errorf("typing in an empty room = %v, want empty non-nil slice", got)
+	}
+}
+
+func TestTypingStateExpires(t *testing.T) {
+	srv, db := newTestDB(t)
+	clock := newFakeClock()
+	typing := NewTypingService(db, time.Minute, clock)
+	alice := seedParticipant(srv, "room1", "", clock.Now())
+	bob := seedParticipant(srv, "room1", "", clock.Now())
+
+	if err := typing.SetTyping("room1", alice.ID, true); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(30 * time.Second)
+	if err := typing.SetTyping("room1", bob.ID, true); err != nil {
+		t.Fatal(err)
+	}
+
+	clock.Advance(30 * time.Second)
+	got := typing.GetTyping("room1")
+	if len(got) != 1 || got[0].ParticipantID != bob.ID {
+		t.Errorf("typing after alice's entry expired = %+v, want only bob", got)
+	}
+
+	clock.Advance(30 * time.Second)
+	if got := typing.GetTyping("room1"); len(got) != 0 {
+		t.Errorf("typing after every entry expired = %+v, want none", got)
+	}
+	typing.mu.Lock()
+	defer typing.mu.Unlock()
+	if _, ok := typing.typing["room1"]; ok {
+		t.Error("expired room state was not dropped")
+	}
+}
+
+func TestTypingSweepsRoomsThatAreNotPolled(t *testing.T) {
+	srv, db := newTestDB(t)
+	clock := newFakeClock()
+	typing := NewTypingService(db, time.Minute, clock)
+	alice := seedParticipant(srv, "room1", "", clock.Now())
+	bob := seedParticipant(srv, "room2", "", clock.Now())
+
+	if err := typing.SetTyping("room1", alice.ID, true); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Minute)
+	// room1 is never polled again; a write anywhere sweeps it
+	if err := typing.SetTyping("room2", bob.ID, true); err != nil {
+		t.Fatal(err)
+	}
+
+	typing.mu.Lock()
+	defer typing.mu.Unlock()
+	if _, ok := typing.typing["room1"]; ok {
+		t.Error("expired state of an unpolled room was not swept")
+	}
+	if _, ok := typing.typing["room2"]; !ok {
+		t.Error("live typing state was swept")
+	}
+}
+
+func TestSetTypingRejectsOutsiders(t *testing.T) {
+	srv, db := newTestDB(t)
+	clock := newFakeClock()
+	typing := NewTypingService(db, time.Minute, clock)
+	outsider := seedParticipant(srv, "room2", "", clock.Now())
+
+	for _, id := range []string{outsider.ID, "00000000-0000-0000-0000-000000000000"} {
+		if err := typing.SetTyping("room1", id, true); !errors.Is(err, ErrParticipantNotFound) {
+			t.Errorf("SetTyping(%s) error = %v, want %v", id, err, ErrParticipantNotFound)
+		}
+	}
+	if got := typing.GetTyping("room1"); len(got) != 0 {
+		t.Errorf("rejected typing state was stored: %+v", got)
+	}
+}
+
+func TestTypingStateDroppedWhenRoomDeleted(t *testing.T) {
+	ts := newTestServices(t)
+	typing := NewTypingService(ts.db, time.Hour, ts.clock)
+	ts.rooms.OnRoomDeleted(typing.ForgetRoom)
+	host := seedHostedRoom(ts, "closed", "")[0]
+	leaver := seedHostedRoom(ts, "left", "")[0]
+	ts.seedRoom("stale")
+	stale := seedParticipant(ts.srv, "stale", "", ts.clock.Now())
+
+	for _, p := range []struct{ roomID, id string }{{"closed", host.ID}, {"left", leaver.ID}, {"stale", stale.ID}} {
+		if err := typing.SetTyping(p.roomID, p.id, true); err != nil {
+			t.Fatalf("SetTyping(%s): %v", p.roomID, err)
+		}
+	}
+
+	if err := ts.rooms.CloseRoom("closed", host.ID); err != nil {
+		t.Fatalf("CloseRoom: %v", err)
+	}
+	if err := ts.rooms.LeaveRoom("left", leaver.ID); err != nil {
+		t.Fatalf("LeaveRoom: %v", err)
+	}
+	ts.srv.DeleteParticipant(stale.ID)
+	ts.clock.Advance(11 * time.Minute)
+	newTestCleanup(ts, 2*time.Minute, 10*time.Minute, 0).cleanupRooms(ts.clock.Now().Add(-10 * time.Minute))
+	if _, ok := ts.srv.Room("stale"); ok {
+		t.Fatal("stale room was not deleted")
+	}
+
+	typing.mu.Lock()
+	defer typing.mu.Unlock()
+	for _, roomID := range []string{"closed", "left", "stale"} {
+		if _, ok := typing.typing[roomID]; ok {
+			t.Errorf("typing state of deleted room %s was kept", roomID)
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package services
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/adi-253/Talkie/backend/internal/models"
+)
+
+// Lifecycle events delivered to the outbound webhook.
+const (
+	WebhookRoomCreated       = "room.created"
+	WebhookRoomDeleted       = "room.deleted"
+	WebhookParticipantJoined = "participant.joined"
+	WebhookParticipantLeft   = "participant.left"
+)
+
+const (
+	// webhookQueueSize bounds pending deliveries; events beyond it are dead-lettered
+	webhookQueueSize = 256
+
+	// webhookMaxAttempts is how many times a delivery is tried before dead-lettering
+	webhookMaxAttempts = 4
+
+	// webhookBaseBackoff is the delay before the first retry; it doubles per attempt
+	webhookBaseBackoff = 1 * time.Second
+)
+
+// webhookEvent is the JSON body of a webhook delivery.
+type webhookEvent struct {
+	Event      string      `json:"event"`
+	OccurredAt time.Time   `json:"occurred_at"`
+	Data       interface{} `json:"data"`
+}
+
+// WebhookNotifier delivers room lifecycle events to an integrator's URL.
+// Events are queued and POSTed asynchronously by a background worker, retried
+// with exponential backoff, and logged as dead letters if delivery fails.
+// Each body is signed with HMAC-SHA256 in the X-Talkie-Signature header
+// ("sha256=<hex>") so receivers can verify it came from this server.
+// A nil *WebhookNotifier is valid and drops every event.
+type WebhookNotifier struct {
+	url        string
+	secret     []byte
+	httpClient *http.Client
+	clock      Clock
+	queue      chan webhookEvent
+	stopChan   chan struct{}
+}
+
+// NewWebhookNotifier creates a notifier posting to url, signing with secret.
+func NewWebhookNotifier(url, secret string, clock Clock) *WebhookNotifier {
+	return &WebhookNotifier{
+		url:    url,
+		secret: []byte(secret),
+		httpClient: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+		clock:    clock,
+		queue:    make(chan webhookEvent, webhookQueueSize),
+		stopChan: make(chan struct{}),
+	}
+}
+
+// Start begins the background delivery worker.
+// This method runs in its own goroutine and should be called with 'go'.
+func (n *WebhookNotifier) Start() {
+	log.Printf("Webhook notifier started (url: %s)", n.url)
+	for {
+		select {
+		case event := <-n.queue:
+			n.deliver(event)
+		case <-n.stopChan:
+			log.Println("Webhook notifier stopped")
+			return
+		}
+	}
+}
+
+// Stop halts the delivery worker. Queued events are dropped.
+func (n *WebhookNotifier) Stop() {
+	close(n.stopChan)
+}
+
+// RoomCreated queues a room.created event.
+func (n *WebhookNotifier) RoomCreated(room *models.Room) {
+	n.notify(WebhookRoomCreated, roomData(room))
+}
+
+// RoomDeleted queues a room.deleted event.
+func (n *WebhookNotifier) RoomDeleted(room *models.Room) {
+	n.notify(WebhookRoomDeleted, roomData(room))
+}
+
+// ParticipantJoined queues a participant.joined event.
+func (n *WebhookNotifier) ParticipantJoined(participant *models.Participant) {
+	n.notify(WebhookParticipantJoined, participantData(participant))
+}
+
+// ParticipantLeft queues a participant.left event.
+func (n *WebhookNotifier) ParticipantLeft(participant *models.Participant) {
+	n.notify(WebhookParticipantLeft, participantData(participant))
+}
+
+// notify queues an event without blocking the caller.
+func (n *WebhookNotifier) notify(event string, data interface{}) {
+	if n == nil {
+		return
+	}
+
+	e := webhookEvent{Event: event, OccurredAt: n.clock.Now().UTC(), Data: data}
+	select {
+	case n.queue <- e:
+	default:
+		n.deadLetter(e, fmt.Errorf("queue full"))
+	}
+}
+
+// deliver POSTs an event, retrying with exponential backoff.
+func (n *WebhookNotifier) deliver(event webhookEvent) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		n.deadLetter(event, fmt.Errorf("failed to marshal event: %w", err))
+		return
+	}
+
+	backoff := webhookBaseBackoff
+	for attempt := 1; ; attempt++ {
+		err = n.post(event.Event, body)
+		if err == nil {
+			return
+		}
+		if attempt == webhookMaxAttempts {
+			break
+		}
+
+		log.Printf("[Webhook] %s delivery attempt %d failed, retrying in %v: %v", event.Event, attempt, backoff, err)
+		select {
+		case <-time.After(backoff):
+		case <-n.stopChan:
+			n.deadLetter(event, fmt.Errorf("shutting down: %w", err))
+			return
+		}
+		backoff *= 2
+	}
+	n.deadLetter(event, err)
+}
+
+// post sends one signed delivery attempt.
+func (n *WebhookNotifier) post(event string, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Talkie-Event", event)
+	req.Header.Set("X-Talkie-Signature", "sha256="+n.sign(body))
+
+	resp, err := n.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// sign returns the hex HMAC-SHA256 of body with the webhook secret.
+func (n *WebhookNotifier) sign(body []byte) string {
+	mac := hmac.New(sha256.New, n.secret)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// deadLetter logs an undeliverable event in full so it can be replayed by hand.
+func (n *WebhookNotifier) deadLetter(event webhookEvent, err error) {
+	body, _ := json.Marshal(event)
+	log.Printf("[Webhook] DEAD LETTER %s: %v: %s", event.Event, err, body)
+}
+
+// roomData returns the public fields of a room for webhook events.
+func roomData(room *models.Room) map[string]interface{} {
+	return map[string]interface{}{
+		"id":   room.ID,
+		"name": room.Name,
+	}
+}
+
+// participantData returns the public fields of a participant for webhook events.
+func participantData(participant *models.Participant) map[string]interface{} {
+	data := map[string]interface{}{
+		"id":       participant.ID,
+		"room_id":  participant.RoomID,
+		"username": participant.Username,
+		"avatar":   participant.Avatar,
+	}
+	if participant.DurationSeconds != nil {
+		data["duration_seconds"] = *participant.DurationSeconds
+	}
+	return data
+}
@@ -0,0 +1,194 @@
+package services
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/adi-253/Talkie/backend/internal/models"
+)
+
+const testWebhookSecret = "test-webhook-secret"
+
+// webhookDelivery is one request received by a fake webhook receiver.
+type webhookDelivery struct {
+	event     string
+	signature string
+	body      []byte
+	payload   struct {
+		Event      string                 `json:"event"`
+		OccurredAt time.Time              `json:"occurred_at"`
+		Data       map[string]interface{} `json:"data"`
+	}
+}
+
+// newWebhookReceiver starts a fake receiver that answers each request with the
+// next status from statuses (200 once they run out) and reports deliveries.
+func newWebhookReceiver(t *testing.T, statuses ...int) (*httptest.Server, <-chan webhookDelivery) {
+	t.Helper()
+	deliveries := make(chan webhookDelivery, 32)
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		d := webhookDelivery{event: r.Header.Get("X-Talkie-Event"), signature: r.Header.Get("X-Talkie-Signature"), body: body}
+		if err := json.Unmarshal(body, &d.payload); err != nil {
+			t.Errorf("decode webhook body: %v", err)
+		}
+		deliveries <- d
+
+		if n := int(calls.Add(1)); n <= len(statuses) {
+			w.WriteHeader(statuses[n-1])
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv, deliveries
+}
+
+// startNotifier starts a notifier posting to url until the test ends.
+func startNotifier(t *testing.T, url string, clock Clock) *WebhookNotifier {
+	t.Helper()
+	n := NewWebhookNotifier(url, testWebhookSecret, clock)
+	go n.Start()
+	t.Cleanup(n.Stop)
+	return n
+}
+
+// nextDelivery waits for the receiver's next delivery.
+func nextDelivery(t *testing.T, deliveries <-chan webhookDelivery) webhookDelivery {
+	t.Helper()
+	select {
+	case d := <-deliveries:
+		return d
+	case <-time.After(3 * time.Second):
+		t.Fatal("no webhook delivered")
+		return webhookDelivery{}
+	}
+}
+
+// validSignature reports whether a delivery carries the HMAC of its body.
+func validSignature(d webhookDelivery, secret string) bool {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(d.body)
+	return hmac.Equal([]byte(d.signature), []byte("sha256="+hex.EncodeToString(mac.Sum(nil))))
+}
+
+func TestWebhooksForRoomLifecycle(t *testing.T) {
+	s := newTestServices(t)
+	receiver, deliveries := newWebhookReceiver(t)
+	notifier := startNotifier(t, receiver.URL, s.clock)
+	rooms := NewRoomService(s.db, s.messages, notifier, RoomSettings{RoomIDBytes: 4}, s.clock, rand.Reader)
+
+	room, err := rooms.CreateRoom("", "Hooks", 0, true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, _, _, err := rooms.JoinRoom(room.ID, "alice", "", "", "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rooms.LeaveRoom(room.ID, p.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []struct{ event, id string }{
+		{WebhookRoomCreated, room.ID},
+		{WebhookParticipantJoined, p.ID},
+		{WebhookParticipantLeft, p.ID},
+		{WebhookRoomDeleted, room.ID},
+	} {
+		d := nextDelivery(t, deliveries)
+		if d.event != want.event || d.payload.Event != want.event {
+			t.Fatalf("delivery = %s (header %s), want %s", d.payload.Event, d.event, want.event)
+		}
+		if d.payload.Data["id"] != want.id {
+			t.Errorf("%s data = %v, want id %s", want.event, d.payload.Data, want.id)
+		}
+		if !validSignature(d, testWebhookSecret) {
+			t.Errorf("%s signature %q does not verify", want.event, d.signature)
+		}
+		if validSignature(d, "wrong-secret") {
+			t.Errorf("%s signature verifies with the wrong secret", want.event)
+		}
+	}
+}
+
+func TestWebhookRetriesFailedDelivery(t *testing.T) {
+	receiver, deliveries := newWebhookReceiver(t, http.StatusInternalServerError)
+	notifier := startNotifier(t, receiver.URL, RealClock{})
+
+	notifier.RoomCreated(&models.Room{ID: "room1", Name: "Room"})
+
+	first, retry := nextDelivery(t, deliveries), nextDelivery(t, deliveries)
+	if first.event != WebhookRoomCreated || !bytes.Equal(first.body, retry.body) || first.signature != retry.signature {
+		t.Errorf("retry = %s %q, want the same signed %s delivery", retry.event, retry.body, WebhookRoomCreated)
+	}
+}
+
+func TestWebhookDeadLettersWhenQueueIsFull(t *testing.T) {
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	// Not started, so nothing drains the queue
+	notifier := NewWebhookNotifier("http://127.0.0.1:0", testWebhookSecret, RealClock{})
+	for i := 0; i <= webhookQueueSize; i++ {
+		notifier.RoomDeleted(&models.Room{ID: "room1"})
+	}
+
+	if got := strings.Count(logs.String(), "DEAD LETTER "+WebhookRoomDeleted); got != 1 {
+		t.Errorf("dead letters = %d, want 1 for the event past the queue size", got)
+	}
+}
+
+func TestNilWebhookNotifierDropsEvents(t *testing.T) {
+	var n *WebhookNotifier
+	n.RoomCreated(&models.Room{ID: "room1"})
+	n.ParticipantLeft(&models.Participant{ID: "p1"})
+}
+
+func TestParticipantLeftWebhookCarriesSessionDuration(t *testing.T) {
+	s := newTestServices(t)
+	receiver, deliveries := newWebhookReceiver(t)
+	notifier := startNotifier(t, receiver.URL, s.clock)
+	rooms := NewRoomService(s.db, s.messages, notifier, RoomSettings{RoomIDBytes: 4}, s.clock, rand.Reader)
+	cleanup := NewCleanupService(s.db, s.messages, nil, notifier, rooms.InvalidateRoom, rooms.ForgetRoom, time.Minute,
+		5*time.Minute, 10*time.Minute, 0, false, s.clock)
+
+	// Joined 60 and 59 minutes ago; the third participant keeps the room alive
+	p := seedHostedRoom(s, "room1", "", "")
+	seedParticipant(s.srv, "room1", "", s.clock.Now())
+
+	if err := rooms.LeaveRoom("room1", p[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	s.clock.Advance(30 * time.Second)
+	cleanup.cleanupParticipants(s.clock.Now().Add(-5 * time.Minute))
+
+	for _, want := range []struct {
+		id      string
+		seconds float64
+	}{
+		{p[0].ID, 3600},
+		{p[1].ID, 59*60 + 30},
+	} {
+		d := nextDelivery(t, deliveries)
+		if d.event != WebhookParticipantLeft || d.payload.Data["id"] != want.id {
+			t.Fatalf("delivery = %s for %v, want %s for %s", d.event, d.payload.Data["id"], WebhookParticipantLeft, want.id)
+		}
+		if got := d.payload.Data["duration_seconds"]; got != want.seconds {
+			t.Errorf("%s duration_seconds = %v, want %v", want.id, got, want.seconds)
+		}
+	}
+}
@@ -3,29 +3,90 @@ package supabase
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/adi-253/Talkie/backend/internal/config"
+	"github.com/adi-253/Talkie/backend/internal/logging"
 	"github.com/adi-253/Talkie/backend/internal/models"
 )
 
+// ErrNotFound is returned when a requested row does not exist.
+var ErrNotFound = errors.New("not found")
+
+// ErrUpstreamRateLimited is returned (wrapped in a *RateLimitError) when Supabase
+// responds 429 and the request can't be retried within the retry budget.
+var ErrUpstreamRateLimited = errors.New("supabase rate limited")
+
+// RateLimitError carries the Retry-After hint from a Supabase 429 response.
+type RateLimitError struct {
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("%v (retry after %v)", ErrUpstreamRateLimited, e.RetryAfter)
+}
+
+// Unwrap lets callers match with errors.Is(err, ErrUpstreamRateLimited).
+func (e *RateLimitError) Unwrap() error {
+	return ErrUpstreamRateLimited
+}
+
+// APIError is returned for Supabase responses with an error status (other
+// than 429, see RateLimitError), so callers can branch on the status code
+// with errors.As.
+type APIError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("supabase error (status %d): %s", e.StatusCode, e.Body)
+}
+
+// IsStatus reports whether err is an *APIError with the given status code.
+func IsStatus(err error, statusCode int) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == statusCode
+}
+
+const (
+	// maxRateLimitRetries bounds how many times an idempotent request is retried after a 429
+	maxRateLimitRetries = 2
+
+	// maxRateLimitWait bounds the total time spent waiting on Retry-After across retries
+	maxRateLimitWait = 5 * time.Second
+
+	// defaultRetryAfter is used when a 429 response has no usable Retry-After header
+	defaultRetryAfter = 1 * time.Second
+)
+
 // Client is a wrapper around the Supabase REST API.
 // It uses the service role key for backend operations with elevated privileges.
+// Lobby and admin list/count reads may be spread across read replicas; every
+// other request, including existence checks that must see the caller's own
+// writes, goes to the primary.
 type Client struct {
-	baseURL    string
-	apiKey     string
-	httpClient *http.Client
+	baseURL     string
+	replicaURLs []string
+	nextReplica atomic.Uint64
+	apiKey      string
+	httpClient  *http.Client
 }
 
 // NewClient creates a new Supabase client with the given configuration.
 func NewClient(cfg *config.Config) *Client {
 	return &Client{
-		baseURL: cfg.SupabaseURL,
-		apiKey:  cfg.SupabaseKey,
+		baseURL:     cfg.SupabaseURL,
+		replicaURLs: cfg.SupabaseReadReplicaURLs,
+		apiKey:      cfg.SupabaseKey,
 		httpClient: &http.Client{
 			Timeout: 10 * time.Second,
 		},
@@ -34,44 +95,164 @@ func NewClient(cfg *config.Config) *Client {
 
 // doRequest executes an HTTP request to the Supabase REST API.
 // It automatically adds authentication headers and handles the response.
+// Idempotent requests that are rate limited (429) are retried after the
+// Retry-After delay within a bounded budget; otherwise a *RateLimitError is returned.
 func (c *Client) doRequest(method, endpoint string, body interface{}) ([]byte, error) {
-	var reqBody io.Reader
+	respBody, _, err := c.doRequestPrefer(method, endpoint, body, "return=representation")
+	return respBody, err
+}
+
+// doRequestPrefer is doRequest with a custom Prefer header, also returning
+// the response headers (e.g. Content-Range for count queries).
+func (c *Client) doRequestPrefer(method, endpoint string, body interface{}, prefer string) ([]byte, http.Header, error) {
+	return c.doRequestRetrying(method, endpoint, body, prefer, false)
+}
+
+// doReplicaRead is a GET that may be served by a read replica. Replicas lag
+// the primary and report a missing row as an empty result rather than an
+// error, so only use it for lists and counts that tolerate stale data.
+func (c *Client) doReplicaRead(endpoint, prefer string) ([]byte, http.Header, error) {
+	return c.doRequestRetrying(http.MethodGet, endpoint, nil, prefer, true)
+}
+
+// doRequestRetrying performs a request, retrying idempotent requests that are
+// rate limited. replicaOK allows a GET to be served by a read replica.
+func (c *Client) doRequestRetrying(method, endpoint string, body interface{}, prefer string, replicaOK bool) ([]byte, http.Header, error) {
+	var jsonBody []byte
 	if body != nil {
-		jsonBody, err := json.Marshal(body)
+		var err error
+		jsonBody, err = json.Marshal(body)
 		if err != nil {
-			return nil, fmt.Errorf("failed to marshal request body: %w", err)
+			return nil, nil, fmt.Errorf("failed to marshal request body: %w", err)
 		}
-		reqBody = bytes.NewBuffer(jsonBody)
 	}
 
-	url := fmt.Sprintf("%s/rest/v1/%s", c.baseURL, endpoint)
+	var waited time.Duration
+	for attempt := 0; ; attempt++ {
+		respBody, header, err := c.doRequestRouted(method, endpoint, jsonBody, prefer, replicaOK)
+
+		var rateLimitErr *RateLimitError
+		if !errors.As(err, &rateLimitErr) || !isIdempotent(method) ||
+			attempt >= maxRateLimitRetries || waited+rateLimitErr.RetryAfter > maxRateLimitWait {
+			return respBody, header, err
+		}
+
+		log.Printf("[Supabase] Rate limited on %s %s, retrying in %v", method, endpoint, rateLimitErr.RetryAfter)
+		time.Sleep(rateLimitErr.RetryAfter)
+		waited += rateLimitErr.RetryAfter
+	}
+}
+
+// doRequestRouted performs a single attempt of a request. When replicaOK is
+// set, GETs are sent round-robin to the read replicas when configured; if the
+// replica fails, the read is retried once against the primary.
+func (c *Client) doRequestRouted(method, endpoint string, jsonBody []byte, prefer string, replicaOK bool) ([]byte, http.Header, error) {
+	if !replicaOK || method != http.MethodGet || len(c.replicaURLs) == 0 {
+		return c.doRequestOnce(c.baseURL, method, endpoint, jsonBody, prefer)
+	}
+
+	replica := c.replicaURLs[(c.nextReplica.Add(1)-1)%uint64(len(c.replicaURLs))]
+	respBody, header, err := c.doRequestOnce(replica, method, endpoint, jsonBody, prefer)
+	if err == nil {
+		return respBody, header, nil
+	}
+
+	log.Printf("[Supabase] Read replica %s failed, falling back to primary: %v", replica, err)
+	return c.doRequestOnce(c.baseURL, method, endpoint, jsonBody, prefer)
+}
+
+// doRequestOnce performs a single attempt of a Supabase REST API request against baseURL.
+func (c *Client) doRequestOnce(baseURL, method, endpoint string, jsonBody []byte, prefer string) ([]byte, http.Header, error) {
+	var reqBody io.Reader
+	if jsonBody != nil {
+		reqBody = bytes.NewReader(jsonBody)
+	}
+
+	url := fmt.Sprintf("%s/rest/v1/%s", baseURL, endpoint)
 	req, err := http.NewRequest(method, url, reqBody)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return nil, nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	// Add Supabase authentication headers
 	req.Header.Set("apikey", c.apiKey)
 	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.apiKey))
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Prefer", "return=representation")
+	req.Header.Set("Prefer", prefer)
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("request failed: %w", err)
+		return nil, nil, fmt.Errorf("request failed: %w", err)
 	}
 	defer resp.Body.Close()
 
 	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %w", err)
+		return nil, nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return nil, nil, &RateLimitError{RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"))}
 	}
 
 	if resp.StatusCode >= 400 {
-		return nil, fmt.Errorf("supabase error (status %d): %s", resp.StatusCode, string(respBody))
+		return nil, nil, &APIError{StatusCode: resp.StatusCode, Body: string(respBody)}
+	}
+
+	return respBody, resp.Header, nil
+}
+
+// count returns the number of rows in a table without fetching any of them,
+// using PostgREST's exact count in Content-Range. The count may be served by a
+// (possibly lagging) read replica.
+func (c *Client) count(table string) (int, error) {
+	endpoint := fmt.Sprintf("%s?select=id&limit=0", table)
+	_, header, err := c.doRequestRetrying("GET", endpoint, nil, "count=exact", true)
+	if err != nil {
+		return 0, err
+	}
+	return parseContentRangeTotal(header.Get("Content-Range"))
+}
+
+// parseContentRangeTotal extracts the total from a Content-Range header such
+// as "0-24/3573" or "*/0".
+func parseContentRangeTotal(value string) (int, error) {
+	_, total, ok := strings.Cut(value, "/")
+	if !ok {
+		return 0, fmt.Errorf("missing count in Content-Range %q", value)
+	}
+	n, err := strconv.Atoi(total)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid count in Content-Range %q", value)
 	}
+	return n, nil
+}
 
-	return respBody, nil
+// isIdempotent reports whether a request with this method can be safely retried.
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// parseRetryAfter parses a Retry-After header given either as seconds or an HTTP date.
+// Falls back to defaultRetryAfter if the header is missing or invalid.
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return defaultRetryAfter
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if d := time.Until(date); d > 0 {
+			return d
+		}
+		return 0
+	}
+	return defaultRetryAfter
 }
 
 // CreateRoom inserts a new room into the database.
@@ -94,13 +275,128 @@ func (c *Client) GetRoom(id string) (*models.Room, error) {
 	}
 
 	if len(rooms) == 0 {
-		return nil, fmt.Errorf("room not found")
+		return nil, fmt.Errorf("room %s: %w", id, ErrNotFound)
 	}
 
 	return &rooms[0], nil
 }
 
-// ListRooms retrieves all active rooms.
+// GetRooms retrieves several rooms in a single request. The result follows the
+// order of ids with duplicates removed; rooms that don't exist are omitted.
+func (c *Client) GetRooms(ids []string) ([]models.Room, error) {
+	unique := make([]string, 0, len(ids))
+	seen := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	if len(unique) == 0 {
+		return []models.Room{}, nil
+	}
+
+	endpoint := fmt.Sprintf("rooms?id=in.(%s)&select=*", inList(unique))
+	respBody, err := c.doRequest("GET", endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var rooms []models.Room
+	if err := json.Unmarshal(respBody, &rooms); err != nil {
+		return nil, fmt.Errorf("failed to parse rooms: %w", err)
+	}
+
+	byID := make(map[string]models.Room, len(rooms))
+	for _, room := range rooms {
+		byID[room.ID] = room
+	}
+	ordered := make([]models.Room, 0, len(rooms))
+	for _, id := range unique {
+		if room, ok := byID[id]; ok {
+			ordered = append(ordered, room)
+		}
+	}
+	return ordered, nil
+}
+
+// inList formats values for a PostgREST in.(...) filter, quoting each so
+// commas or parentheses in a value can't break the list.
+func inList(values []string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = `"` + strings.ReplaceAll(v, `"`, `\"`) + `"`
+	}
+	return strings.Join(quoted, ",")
+}
+
+// roomWithParticipants is a room row with its participants embedded by PostgREST.
+type roomWithParticipants struct {
+	models.Room
+	Participants []models.Participant `json:"participants"`
+}
+
+// GetRoomWithParticipants retrieves a room and its participants in a single request
+// by embedding the related participants rows (select=*,participants(*)).
+// If PostgREST rejects the embed with a 400 (e.g. the foreign key relationship
+// isn't exposed), it falls back to fetching the room and participants
+// separately. Other errors, such as rate limits and outages, are returned as is.
+func (c *Client) GetRoomWithParticipants(id string) (*models.Room, []models.Participant, error) {
+	endpoint := fmt.Sprintf("rooms?id=eq.%s&select=*,participants(*)", id)
+	respBody, err := c.doRequest("GET", endpoint, nil)
+	if err != nil {
+		if !IsStatus(err, http.StatusBadRequest) {
+			return nil, nil, err
+		}
+		log.Printf("[Supabase] Participants embed failed for room %s, falling back: %v", id, err)
+		return c.getRoomAndParticipants(id)
+	}
+
+	var rooms []roomWithParticipants
+	if err := json.Unmarshal(respBody, &rooms); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse room: %w", err)
+	}
+
+	if len(rooms) == 0 {
+		return nil, nil, fmt.Errorf("room %s: %w", id, ErrNotFound)
+	}
+
+	participants := rooms[0].Participants
+	if participants == nil {
+		participants = []models.Participant{}
+	}
+	return &rooms[0].Room, participants, nil
+}
+
+// getRoomAndParticipants fetches a room and its participants with two requests.
+func (c *Client) getRoomAndParticipants(id string) (*models.Room, []models.Participant, error) {
+	room, err := c.GetRoom(id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	participants, err := c.GetParticipants(id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return room, participants, nil
+}
+
+// CountRooms returns the number of rooms without fetching them.
+// The count may be served by a read replica.
+func (c *Client) CountRooms() (int, error) {
+	return c.count("rooms")
+}
+
+// CountAllParticipants returns the number of participants across all rooms
+// without fetching them. The count may be served by a read replica.
+func (c *Client) CountAllParticipants() (int, error) {
+	return c.count("participants")
+}
+
+// ListRooms retrieves all active rooms, for server-side sweeps.
+// Client-facing lists use ListRoomsPage.
 func (c *Client) ListRooms() ([]models.Room, error) {
 	respBody, err := c.doRequest("GET", "rooms?select=*&order=created_at.desc", nil)
 	if err != nil {
@@ -115,6 +411,23 @@ func (c *Client) ListRooms() ([]models.Room, error) {
 	return rooms, nil
 }
 
+// ListRoomsPage retrieves one page of active rooms, newest first.
+// The page may be served by a read replica, so it can briefly miss new rooms.
+func (c *Client) ListRoomsPage(limit, offset int) ([]models.Room, error) {
+	endpoint := fmt.Sprintf("rooms?select=*&order=created_at.desc,id.asc&limit=%d&offset=%d", limit, offset)
+	respBody, _, err := c.doReplicaRead(endpoint, "return=representation")
+	if err != nil {
+		return nil, err
+	}
+
+	var rooms []models.Room
+	if err := json.Unmarshal(respBody, &rooms); err != nil {
+		return nil, fmt.Errorf("failed to parse rooms: %w", err)
+	}
+
+	return rooms, nil
+}
+
 // UpdateRoomActivity updates the last_active_at timestamp for a room.
 func (c *Client) UpdateRoomActivity(roomID string) error {
 	data := map[string]interface{}{
@@ -125,6 +438,95 @@ func (c *Client) UpdateRoomActivity(roomID string) error {
 	return err
 }
 
+// ClaimRoomHost makes the given participant the room host if the room has none yet.
+// The update is conditional on host_participant_id being null, so concurrent joins
+// cannot both claim the host role. Returns true if the participant became host.
+func (c *Client) ClaimRoomHost(roomID, participantID string) (bool, error) {
+	data := map[string]interface{}{
+		"host_participant_id": participantID,
+	}
+	endpoint := fmt.Sprintf("rooms?id=eq.%s&host_participant_id=is.null", roomID)
+	respBody, err := c.doRequest("PATCH", endpoint, data)
+	if err != nil {
+		return false, err
+	}
+
+	var rooms []models.Room
+	if err := json.Unmarshal(respBody, &rooms); err != nil {
+		return false, fmt.Errorf("failed to parse room: %w", err)
+	}
+
+	return len(rooms) > 0, nil
+}
+
+// TransferRoomHost moves the host role from one participant to another (or
+// clears it when toParticipantID is empty). The update is conditional on
+// fromParticipantID still being host, so concurrent transfers can't both win.
+// Returns true if the host was changed.
+func (c *Client) TransferRoomHost(roomID, fromParticipantID, toParticipantID string) (bool, error) {
+	data := map[string]interface{}{
+		"host_participant_id": nil,
+	}
+	if toParticipantID != "" {
+		data["host_participant_id"] = toParticipantID
+	}
+	endpoint := fmt.Sprintf("rooms?id=eq.%s&host_participant_id=eq.%s", roomID, fromParticipantID)
+	respBody, err := c.doRequest("PATCH", endpoint, data)
+	if err != nil {
+		return false, err
+	}
+
+	var rooms []models.Room
+	if err := json.Unmarshal(respBody, &rooms); err != nil {
+		return false, fmt.Errorf("failed to parse room: %w", err)
+	}
+
+	return len(rooms) > 0, nil
+}
+
+// SetRoomLocked updates whether a room accepts new participants.
+func (c *Client) SetRoomLocked(roomID string, locked bool) error {
+	data := map[string]interface{}{
+		"locked": locked,
+	}
+	endpoint := fmt.Sprintf("rooms?id=eq.%s", roomID)
+	_, err := c.doRequest("PATCH", endpoint, data)
+	return err
+}
+
+// RotateRoomKey moves a room to the next key epoch with a new salt.
+// The update is conditional on the room still being at fromVersion, so
+// concurrent rotations can't skip or reuse an epoch. Returns true if rotated.
+func (c *Client) RotateRoomKey(roomID string, fromVersion int, salt string) (bool, error) {
+	data := map[string]interface{}{
+		"key_version": fromVersion + 1,
+		"key_salt":    salt,
+	}
+	endpoint := fmt.Sprintf("rooms?id=eq.%s&key_version=eq.%d", roomID, fromVersion)
+	respBody, err := c.doRequest("PATCH", endpoint, data)
+	if err != nil {
+		return false, err
+	}
+
+	var rooms []models.Room
+	if err := json.Unmarshal(respBody, &rooms); err != nil {
+		return false, fmt.Errorf("failed to parse room: %w", err)
+	}
+
+	return len(rooms) > 0, nil
+}
+
+// MoveParticipants reassigns every participant of one room to another.
+// The target room must already exist.
+func (c *Client) MoveParticipants(fromRoomID, toRoomID string) error {
+	data := map[string]interface{}{
+		"room_id": toRoomID,
+	}
+	endpoint := fmt.Sprintf("participants?room_id=eq.%s", fromRoomID)
+	_, err := c.doRequest("PATCH", endpoint, data)
+	return err
+}
+
 // DeleteRoom removes a room from the database.
 // This will cascade delete all participants due to the foreign key constraint.
 func (c *Client) DeleteRoom(id string) error {
@@ -133,6 +535,26 @@ func (c *Client) DeleteRoom(id string) error {
 	return err
 }
 
+// DeleteRoomIfEmpty deletes a room only if it has no participants, checked
+// server-side in the same statement (see migration 008). Returns whether the
+// room was deleted.
+func (c *Client) DeleteRoomIfEmpty(id string) (bool, error) {
+	data := map[string]interface{}{
+		"p_room_id": id,
+	}
+	respBody, err := c.doRequest("POST", "rpc/delete_room_if_empty", data)
+	if err != nil {
+		return false, err
+	}
+
+	var rooms []models.Room
+	if err := json.Unmarshal(respBody, &rooms); err != nil {
+		return false, fmt.Errorf("failed to parse room: %w", err)
+	}
+
+	return len(rooms) > 0, nil
+}
+
 // AddParticipant inserts a new participant into a room.
 func (c *Client) AddParticipant(participant *models.Participant) error {
 	_, err := c.doRequest("POST", "participants", participant)
@@ -155,6 +577,23 @@ func (c *Client) GetParticipants(roomID string) ([]models.Participant, error) {
 	return participants, nil
 }
 
+// ListParticipantsPage retrieves one page of participants across all rooms,
+// ordered by join time.
+func (c *Client) ListParticipantsPage(limit, offset int) ([]models.Participant, error) {
+	endpoint := fmt.Sprintf("participants?select=*&order=joined_at.asc,id.asc&limit=%d&offset=%d", limit, offset)
+	respBody, err := c.doRequest("GET", endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var participants []models.Participant
+	if err := json.Unmarshal(respBody, &participants); err != nil {
+		return nil, fmt.Errorf("failed to parse participants: %w", err)
+	}
+
+	return participants, nil
+}
+
 // GetParticipant retrieves a single participant by ID.
 func (c *Client) GetParticipant(participantID string) (*models.Participant, error) {
 	endpoint := fmt.Sprintf("participants?id=eq.%s&select=*", participantID)
@@ -169,7 +608,7 @@ func (c *Client) GetParticipant(participantID string) (*models.Participant, erro
 	}
 
 	if len(participants) == 0 {
-		return nil, fmt.Errorf("participant not found: %s", participantID)
+		return nil, fmt.Errorf("participant %s: %w", participantID, ErrNotFound)
 	}
 
 	return &participants[0], nil
@@ -182,15 +621,6 @@ func (c *Client) RemoveParticipant(participantID string) error {
 	return err
 }
 
-// CountParticipants returns the number of participants in a room.
-func (c *Client) CountParticipants(roomID string) (int, error) {
-	participants, err := c.GetParticipants(roomID)
-	if err != nil {
-		return 0, err
-	}
-	return len(participants), nil
-}
-
 // GetInactiveRooms returns rooms that haven't been active since the given threshold.
 func (c *Client) GetInactiveRooms(threshold time.Time) ([]models.Room, error) {
 	endpoint := fmt.Sprintf("rooms?last_active_at=lt.%s&select=*", threshold.Format(time.RFC3339))
@@ -217,35 +647,55 @@ func (c *Client) UpdateParticipantActivity(participantID string) error {
 	return err
 }
 
-// BroadcastParticipantEvent sends a Supabase Realtime Broadcast event to notify
-// connected clients about a participant joining or leaving.
+// UpdateParticipantStatus sets a participant's custom status.
+func (c *Client) UpdateParticipantStatus(participantID, status string) error {
+	data := map[string]interface{}{
+		"status": status,
+	}
+	endpoint := fmt.Sprintf("participants?id=eq.%s", participantID)
+	_, err := c.doRequest("PATCH", endpoint, data)
+	return err
+}
+
+// UpdateParticipantIdentity sets a participant's username and avatar.
+func (c *Client) UpdateParticipantIdentity(participantID, username, avatar string) error {
+	data := map[string]interface{}{
+		"username": username,
+		"avatar":   avatar,
+	}
+	endpoint := fmt.Sprintf("participants?id=eq.%s", participantID)
+	_, err := c.doRequest("PATCH", endpoint, data)
+	return err
+}
+
+// broadcastMessage is a single message in a Supabase Realtime Broadcast request.
+type broadcastMessage struct {
+	Topic   string      `json:"topic"`
+	Event   string      `json:"event"`
+	Payload interface{} `json:"payload"`
+}
+
+// broadcast sends a single Supabase Realtime Broadcast message on the given topic.
 // This uses the Supabase Realtime REST API so no WebSocket connection is needed.
-func (c *Client) BroadcastParticipantEvent(roomID string, action string, participant *models.Participant) error {
-	payload := map[string]interface{}{
-		"messages": []map[string]interface{}{
-			{
-				"topic": fmt.Sprintf("room:%s", roomID),
-				"event": "participant",
-				"payload": map[string]interface{}{
-					"action": action,
-					"participant": map[string]interface{}{
-						"id":       participant.ID,
-						"room_id":  participant.RoomID,
-						"username": participant.Username,
-						"avatar":   participant.Avatar,
-					},
-				},
-			},
-		},
+func (c *Client) broadcast(topic, event string, payload interface{}) error {
+	return c.broadcastBatch([]broadcastMessage{{Topic: topic, Event: event, Payload: payload}})
+}
+
+// broadcastBatch sends several Supabase Realtime Broadcast messages in one request.
+func (c *Client) broadcastBatch(messages []broadcastMessage) error {
+	if len(messages) == 0 {
+		return nil
+	}
+	body := map[string]interface{}{
+		"messages": messages,
 	}
 
-	jsonBody, err := json.Marshal(payload)
+	jsonBody, err := json.Marshal(body)
 	if err != nil {
 		return fmt.Errorf("failed to marshal broadcast payload: %w", err)
 	}
 
 	url := fmt.Sprintf("%s/realtime/v1/api/broadcast", c.baseURL)
-	log.Printf("[Broadcast] Participant %s in room:%s (user: %s)", action, roomID, participant.Username)
 	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonBody))
 	if err != nil {
 		return fmt.Errorf("failed to create broadcast request: %w", err)
@@ -261,64 +711,192 @@ func (c *Client) BroadcastParticipantEvent(roomID string, action string, partici
 	}
 	defer resp.Body.Close()
 
-	body, _ := io.ReadAll(resp.Body)
+	respBody, _ := io.ReadAll(resp.Body)
 	if resp.StatusCode >= 400 {
-		log.Printf("[Broadcast] Participant event failed: status=%d body=%s", resp.StatusCode, string(body))
-		return fmt.Errorf("broadcast error (status %d): %s", resp.StatusCode, string(body))
+		log.Printf("[Broadcast] %s event on %s failed (%d messages): status=%d body=%s",
+			messages[0].Event, messages[0].Topic, len(messages), resp.StatusCode, string(respBody))
+		return fmt.Errorf("broadcast error (status %d): %s", resp.StatusCode, string(respBody))
 	}
 
 	return nil
 }
 
+// BroadcastParticipantEvent sends a Supabase Realtime Broadcast event to notify
+// connected clients about a participant joining or leaving.
+func (c *Client) BroadcastParticipantEvent(roomID string, action string, participant *models.Participant) error {
+	logging.Debugf("[Broadcast] Participant %s in room:%s (user: %s)", action, roomID, participant.Username)
+	return c.broadcast(fmt.Sprintf("room:%s", roomID), "participant", map[string]interface{}{
+		"action":      action,
+		"participant": participantPayload(participant),
+	})
+}
+
+// BroadcastParticipantsLeft notifies a room's connected clients that several
+// participants left, as one batched request instead of one per participant.
+// All participants must belong to the given room.
+func (c *Client) BroadcastParticipantsLeft(roomID string, participants []models.Participant) error {
+	logging.Debugf("[Broadcast] %d participants left room:%s", len(participants), roomID)
+	messages := make([]broadcastMessage, len(participants))
+	for i := range participants {
+		messages[i] = broadcastMessage{
+			Topic: fmt.Sprintf("room:%s", roomID),
+			Event: "participant",
+			Payload: map[string]interface{}{
+				"action":      "leave",
+				"participant": participantPayload(&participants[i]),
+			},
+		}
+	}
+	return c.broadcastBatch(messages)
+}
+
+// BroadcastParticipantUpdate notifies connected clients that a participant's
+// details (e.g. status) changed.
+func (c *Client) BroadcastParticipantUpdate(participant *models.Participant) error {
+	logging.Debugf("[Broadcast] Participant %s updated in room:%s", participant.ID, participant.RoomID)
+	return c.broadcast(fmt.Sprintf("room:%s", participant.RoomID), "participant_update", map[string]interface{}{
+		"participant": participantPayload(participant),
+	})
+}
+
+// participantPayload returns the public fields of a participant for broadcasts.
+func participantPayload(participant *models.Participant) map[string]interface{} {
+	return map[string]interface{}{
+		"id":       participant.ID,
+		"room_id":  participant.RoomID,
+		"username": participant.Username,
+		"avatar":   participant.Avatar,
+		"role":     participant.Role,
+		"status":   participant.Status,
+	}
+}
+
 // BroadcastRoomEvent sends a Supabase Realtime Broadcast event to notify
 // connected clients about a room being created or deleted.
 // This broadcasts on a global "rooms:lobby" channel so the Home page can update in real-time.
 func (c *Client) BroadcastRoomEvent(action string, room *models.Room) error {
-	payload := map[string]interface{}{
-		"messages": []map[string]interface{}{
-			{
-				"topic": "rooms:lobby",
-				"event": "room",
-				"payload": map[string]interface{}{
-					"action": action,
-					"room": map[string]interface{}{
-						"id":   room.ID,
-						"name": room.Name,
-					},
-				},
-			},
+	logging.Debugf("[Broadcast] Room %s: %s", action, room.ID)
+	return c.broadcast("rooms:lobby", "room", map[string]interface{}{
+		"action": action,
+		"room": map[string]interface{}{
+			"id":   room.ID,
+			"name": room.Name,
 		},
-	}
+	})
+}
 
-	jsonBody, err := json.Marshal(payload)
-	if err != nil {
-		return fmt.Errorf("failed to marshal room broadcast payload: %w", err)
-	}
+// BroadcastLockChanged notifies clients in a room that it was locked or unlocked.
+func (c *Client) BroadcastLockChanged(roomID string, locked bool) error {
+	logging.Debugf("[Broadcast] Room %s locked=%v", roomID, locked)
+	return c.broadcast(fmt.Sprintf("room:%s", roomID), "lock_changed", map[string]interface{}{
+		"room_id": roomID,
+		"locked":  locked,
+	})
+}
 
-	url := fmt.Sprintf("%s/realtime/v1/api/broadcast", c.baseURL)
-	log.Printf("[Broadcast] Room %s: %s", action, room.ID)
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonBody))
-	if err != nil {
-		return fmt.Errorf("failed to create room broadcast request: %w", err)
-	}
+// BroadcastRoomSettings sends the full snapshot of a room's mutable settings
+// whenever any of them changes, so clients can reconcile from one event type.
+func (c *Client) BroadcastRoomSettings(room *models.Room) error {
+	logging.Debugf("[Broadcast] Room %s settings changed", room.ID)
+	return c.broadcast(fmt.Sprintf("room:%s", room.ID), "room_settings", map[string]interface{}{
+		"room_id":             room.ID,
+		"name":                room.Name,
+		"locked":              room.Locked,
+		"host_participant_id": room.HostParticipantID,
+		"message_ttl_seconds": room.MessageTTLSeconds,
+		"persist":             room.Persist,
+	})
+}
 
-	req.Header.Set("apikey", c.apiKey)
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.apiKey))
+// BroadcastSignal notifies clients in a room of a participant's signal
+// (e.g. a raised hand or a reaction). Clients clear it at expires_at.
+func (c *Client) BroadcastSignal(roomID string, signal *models.ParticipantSignal) error {
+	logging.Debugf("[Broadcast] Signal %s from %s in room:%s", signal.Signal, signal.ParticipantID, roomID)
+	return c.broadcast(fmt.Sprintf("room:%s", roomID), "signal", map[string]interface{}{
+		"participant_id": signal.ParticipantID,
+		"signal":         signal.Signal,
+		"expires_at":     signal.ExpiresAt,
+	})
+}
 
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return fmt.Errorf("room broadcast request failed: %w", err)
-	}
-	defer resp.Body.Close()
+// BroadcastHostChanged notifies clients in a room that the host role moved.
+// newHostID is empty if the room has no host until someone joins.
+func (c *Client) BroadcastHostChanged(roomID, oldHostID, newHostID string) error {
+	logging.Debugf("[Broadcast] Room %s host changed from %s to %s", roomID, oldHostID, newHostID)
+	return c.broadcast(fmt.Sprintf("room:%s", roomID), "host_changed", map[string]interface{}{
+		"room_id":             roomID,
+		"old_host_id":         oldHostID,
+		"host_participant_id": newHostID,
+	})
+}
 
-	body, _ := io.ReadAll(resp.Body)
-	if resp.StatusCode >= 400 {
-		log.Printf("[Broadcast] Room event failed: status=%d body=%s", resp.StatusCode, string(body))
-		return fmt.Errorf("room broadcast error (status %d): %s", resp.StatusCode, string(body))
-	}
+// BroadcastInactivityWarning warns a participant that they will be removed for
+// inactivity at expiresAt unless they send a heartbeat before then.
+func (c *Client) BroadcastInactivityWarning(participant *models.Participant, expiresAt time.Time) error {
+	logging.Debugf("[Broadcast] Inactivity warning for %s in room:%s", participant.ID, participant.RoomID)
+	return c.broadcast(fmt.Sprintf("room:%s", participant.RoomID), "inactivity_warning", map[string]interface{}{
+		"participant_id": participant.ID,
+		"expires_at":     expiresAt,
+	})
+}
 
-	return nil
+// BroadcastMessagesExpired notifies clients in a room that messages passed their TTL
+// and should be removed from view.
+func (c *Client) BroadcastMessagesExpired(roomID string, messageIDs []string) error {
+	logging.Debugf("[Broadcast] %d messages expired in room:%s", len(messageIDs), roomID)
+	return c.broadcast(fmt.Sprintf("room:%s", roomID), "message_expired", map[string]interface{}{
+		"message_ids": messageIDs,
+	})
+}
+
+// BroadcastReconnectAdvisory tells clients in a room that the server is going into
+// maintenance and they should reconnect after the given backoff.
+func (c *Client) BroadcastReconnectAdvisory(roomID string, backoff time.Duration) error {
+	return c.broadcast(fmt.Sprintf("room:%s", roomID), "reconnect", map[string]interface{}{
+		"reason":     "maintenance",
+		"backoff_ms": backoff.Milliseconds(),
+	})
+}
+
+// BroadcastKeyRotated tells clients in a room to derive keys for the new epoch.
+func (c *Client) BroadcastKeyRotated(room *models.Room) error {
+	logging.Debugf("[Broadcast] Room %s key rotated to version %d", room.ID, room.KeyVersion)
+	return c.broadcast(fmt.Sprintf("room:%s", room.ID), "key_rotated", map[string]interface{}{
+		"key_version": room.KeyVersion,
+		"key_salt":    room.KeySalt,
+	})
+}
+
+// BroadcastRoomIDChanged tells clients in a room that it moved to a new ID,
+// so they can resubscribe and update the shareable link.
+func (c *Client) BroadcastRoomIDChanged(oldRoomID, newRoomID string) error {
+	logging.Debugf("[Broadcast] Room %s moved to %s", oldRoomID, newRoomID)
+	return c.broadcast(fmt.Sprintf("room:%s", oldRoomID), "room_id_changed", map[string]interface{}{
+		"old_room_id": oldRoomID,
+		"new_room_id": newRoomID,
+	})
+}
+
+// BroadcastRoomClosed tells clients in a room that the host closed it and it
+// no longer exists.
+func (c *Client) BroadcastRoomClosed(roomID string) error {
+	logging.Debugf("[Broadcast] Room %s closed", roomID)
+	return c.broadcast(fmt.Sprintf("room:%s", roomID), "room_closed", map[string]interface{}{
+		"room_id": roomID,
+	})
+}
+
+// BroadcastServerShutdown tells clients in a room that the server is shutting down,
+// so they can show reconnecting UX instead of failing silently.
+func (c *Client) BroadcastServerShutdown(roomID string, backoff time.Duration) error {
+	return c.broadcast(fmt.Sprintf("room:%s", roomID), "server_shutdown", map[string]interface{}{
+		"backoff_ms": backoff.Milliseconds(),
+	})
+}
+
+// BroadcastLobbyShutdown tells clients browsing the room list that the server is shutting down.
+func (c *Client) BroadcastLobbyShutdown() error {
+	return c.broadcast("rooms:lobby", "server_shutdown", map[string]interface{}{})
 }
 
 // GetInactiveParticipants returns participants that haven't been active since the given threshold.
@@ -0,0 +1,498 @@
+package supabase
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adi-253/Talkie/backend/internal/config"
+	"github.com/adi-253/Talkie/backend/internal/logging"
+	"github.com/adi-253/Talkie/backend/internal/models"
+	"github.com/adi-253/Talkie/backend/internal/supabase/supabasetest"
+	"github.com/google/uuid"
+)
+
+// seedRoom stores a room with the given number of participants in the fake.
+func seedRoom(srv *supabasetest.Server, id string, participants int) {
+	now := time.Now().UTC()
+	srv.AddRoom(models.Room{ID: id, Name: "Room " + id, CreatedAt: now, LastActiveAt: now, Persist: true, KeyVersion: 1})
+	for i := 0; i < participants; i++ {
+		srv.AddParticipant(models.Participant{
+			ID: uuid.New().String(), RoomID: id, Username: "user", JoinedAt: now, LastActiveAt: now, Role: models.RoleParticipant,
+		})
+	}
+}
+
+func TestGetRoomWithParticipantsEmbed(t *testing.T) {
+	srv := supabasetest.NewServer(t)
+	c := NewClient(srv.Config())
+	seedRoom(srv, "room1", 2)
+	seedRoom(srv, "room2", 1)
+	seedRoom(srv, "empty", 0)
+
+	room, participants, err := c.GetRoomWithParticipants("room1")
+	if err != nil {
+		t.Fatalf("GetRoomWithParticipants: %v", err)
+	}
+	if room.ID != "room1" || room.Name != "Room room1" || len(participants) != 2 {
+		t.Errorf("got room %+v with %d participants, want room1 with 2", room, len(participants))
+	}
+	for _, p := range participants {
+		if p.RoomID != "room1" {
+			t.Errorf("participant %s from room %s", p.ID, p.RoomID)
+		}
+	}
+	if got := len(srv.Requests()); got != 1 {
+		t.Errorf("requests = %d, want 1", got)
+	}
+
+	_, participants, err = c.GetRoomWithParticipants("empty")
+	if err != nil || participants == nil || len(participants) != 0 {
+		t.Errorf("empty room: participants = %v, err = %v, want empty non-nil slice", participants, err)
+	}
+
+	if _, _, err := c.GetRoomWithParticipants("missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("missing room: err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestGetRoomWithParticipantsFallsBackWithoutEmbed(t *testing.T) {
+	srv := supabasetest.NewServer(t)
+	c := NewClient(srv.Config())
+	seedRoom(srv, "room1", 3)
+	srv.DisableEmbedding()
+
+	room, participants, err := c.GetRoomWithParticipants("room1")
+	if err != nil {
+		t.Fatalf("GetRoomWithParticipants: %v", err)
+	}
+	if room.ID != "room1" || len(participants) != 3 {
+		t.Errorf("got room %+v with %d participants, want room1 with 3", room, len(participants))
+	}
+	if got := srv.CountRequests("GET", "participants"); got != 1 {
+		t.Errorf("separate participants requests = %d, want 1", got)
+	}
+}
+
+func TestGetRoomWithParticipantsReturnsOtherErrors(t *testing.T) {
+	isRateLimited := func(err error) bool {
+		var rateLimitErr *RateLimitError
+		return errors.As(err, &rateLimitErr)
+	}
+	tests := []struct {
+		name   string
+		status int
+		header http.Header
+		is     func(error) bool
+	}{
+		{"rate limited", http.StatusTooManyRequests, retryAfter("30"), isRateLimited},
+		{"server error", http.StatusInternalServerError, nil, func(err error) bool { return IsStatus(err, http.StatusInternalServerError) }},
+		{"unavailable", http.StatusServiceUnavailable, nil, func(err error) bool { return IsStatus(err, http.StatusServiceUnavailable) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := supabasetest.NewServer(t)
+			c := NewClient(srv.Config())
+			seedRoom(srv, "room1", 1)
+			srv.FailNext("GET", "rooms", tt.status, tt.header, "")
+
+			if _, _, err := c.GetRoomWithParticipants("room1"); !tt.is(err) {
+				t.Errorf("err = %v, want the %d error unchanged", err, tt.status)
+			}
+			if got := len(srv.Requests()); got != 1 {
+				t.Errorf("requests = %d, want 1 with no fallback", got)
+			}
+		})
+	}
+}
+
+func TestBroadcastLogsOnlyAtDebugLevel(t *testing.T) {
+	srv := supabasetest.NewServer(t)
+	c := NewClient(srv.Config())
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	participant := &models.Participant{ID: "p1", RoomID: "room1", Username: "alice"}
+	if err := c.BroadcastParticipantEvent("room1", "join", participant); err != nil {
+		t.Fatalf("broadcast: %v", err)
+	}
+	if strings.Contains(buf.String(), "[Broadcast]") {
+		t.Errorf("info level logged %q, want no per-broadcast lines", buf.String())
+	}
+
+	logging.SetDebug(true)
+	t.Cleanup(func() { logging.SetDebug(false) })
+	if err := c.BroadcastParticipantEvent("room1", "join", participant); err != nil {
+		t.Fatalf("broadcast: %v", err)
+	}
+	if !strings.Contains(buf.String(), "[Broadcast] Participant join in room:room1") {
+		t.Errorf("debug level logged %q, want the broadcast line", buf.String())
+	}
+	if got := len(srv.BroadcastsFor("participant")); got != 2 {
+		t.Errorf("broadcasts = %d, want 2", got)
+	}
+}
+
+// retryAfter returns a Retry-After response header.
+func retryAfter(value string) http.Header {
+	return http.Header{"Retry-After": []string{value}}
+}
+
+func TestRateLimitedReadIsRetried(t *testing.T) {
+	srv := supabasetest.NewServer(t)
+	c := NewClient(srv.Config())
+	seedRoom(srv, "room1", 0)
+	srv.FailNext("GET", "rooms", http.StatusTooManyRequests, retryAfter("0"), `{"message":"too many requests"}`)
+
+	room, err := c.GetRoom("room1")
+	if err != nil {
+		t.Fatalf("GetRoom after a 429: %v", err)
+	}
+	if room.ID != "room1" {
+		t.Errorf("room = %+v, want room1", room)
+	}
+	if got := srv.CountRequests("GET", "rooms"); got != 2 {
+		t.Errorf("requests = %d, want 2 (429 then 200)", got)
+	}
+}
+
+func TestRateLimitedReadGivesUpAfterMaxRetries(t *testing.T) {
+	srv := supabasetest.NewServer(t)
+	c := NewClient(srv.Config())
+	seedRoom(srv, "room1", 0)
+	for i := 0; i <= maxRateLimitRetries; i++ {
+		srv.FailNext("GET", "rooms", http.StatusTooManyRequests, retryAfter("0"), "")
+	}
+
+	var rateLimitErr *RateLimitError
+	if _, err := c.GetRoom("room1"); !errors.As(err, &rateLimitErr) || !errors.Is(err, ErrUpstreamRateLimited) {
+		t.Fatalf("err = %v, want a *RateLimitError", err)
+	}
+	if got := srv.CountRequests("GET", "rooms"); got != maxRateLimitRetries+1 {
+		t.Errorf("requests = %d, want %d", got, maxRateLimitRetries+1)
+	}
+}
+
+func TestRateLimitedReadOverBudgetIsNotRetried(t *testing.T) {
+	srv := supabasetest.NewServer(t)
+	c := NewClient(srv.Config())
+	seedRoom(srv, "room1", 0)
+	srv.FailNext("GET", "rooms", http.StatusTooManyRequests, retryAfter("30"), "")
+
+	start := time.Now()
+	var rateLimitErr *RateLimitError
+	if _, err := c.GetRoom("room1"); !errors.As(err, &rateLimitErr) {
+		t.Fatalf("err = %v, want a *RateLimitError", err)
+	}
+	if rateLimitErr.RetryAfter != 30*time.Second {
+		t.Errorf("RetryAfter = %v, want 30s", rateLimitErr.RetryAfter)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("waited %v for a Retry-After beyond the budget", elapsed)
+	}
+	if got := srv.CountRequests("GET", "rooms"); got != 1 {
+		t.Errorf("requests = %d, want 1", got)
+	}
+}
+
+func TestRateLimitedWriteIsNotRetried(t *testing.T) {
+	srv := supabasetest.NewServer(t)
+	c := NewClient(srv.Config())
+	srv.FailNext("POST", "rooms", http.StatusTooManyRequests, retryAfter("0"), "")
+
+	now := time.Now().UTC()
+	err := c.CreateRoom(&models.Room{ID: "room1", Name: "Room", CreatedAt: now, LastActiveAt: now, KeyVersion: 1})
+	if !errors.Is(err, ErrUpstreamRateLimited) {
+		t.Fatalf("err = %v, want ErrUpstreamRateLimited", err)
+	}
+	if got := srv.CountRequests("POST", "rooms"); got != 1 {
+		t.Errorf("requests = %d, want 1", got)
+	}
+	if _, ok := srv.Room("room1"); ok {
+		t.Error("rate limited insert was stored")
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"", defaultRetryAfter},
+		{"0", 0},
+		{"3", 3 * time.Second},
+		{"-1", defaultRetryAfter},
+		{"soon", defaultRetryAfter},
+		{time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat), 0},
+	}
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.value); got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+
+	future := time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)
+	if got := parseRetryAfter(future); got <= 50*time.Second || got > time.Minute {
+		t.Errorf("parseRetryAfter(%q) = %v, want about a minute", future, got)
+	}
+}
+
+// newReplicatedClient returns a client whose reads may go to the given
+// replicas, with every server seeded with the same room.
+func newReplicatedClient(t *testing.T, replicas int) (*Client, *supabasetest.Server, []*supabasetest.Server) {
+	t.Helper()
+	primary := supabasetest.NewServer(t)
+	seedRoom(primary, "room1", 1)
+	cfg := primary.Config()
+	var servers []*supabasetest.Server
+	for i := 0; i < replicas; i++ {
+		replica := supabasetest.NewServer(t)
+		seedRoom(replica, "room1", 1)
+		servers = append(servers, replica)
+		cfg.SupabaseReadReplicaURLs = append(cfg.SupabaseReadReplicaURLs, replica.URL)
+	}
+	return NewClient(cfg), primary, servers
+}
+
+func TestListReadsAreSpreadAcrossReplicas(t *testing.T) {
+	c, primary, replicas := newReplicatedClient(t, 2)
+
+	for i := 0; i < 4; i++ {
+		rooms, err := c.ListRoomsPage(10, 0)
+		if err != nil || len(rooms) != 1 {
+			t.Fatalf("ListRoomsPage = %v, %v, want one room", rooms, err)
+		}
+	}
+	if _, err := c.CountRooms(); err != nil {
+		t.Fatalf("CountRooms: %v", err)
+	}
+	if _, err := c.CountAllParticipants(); err != nil {
+		t.Fatalf("CountAllParticipants: %v", err)
+	}
+
+	for i, replica := range replicas {
+		if got := len(replica.Requests()); got != 3 {
+			t.Errorf("replica %d requests = %d, want 3", i, got)
+		}
+	}
+	if got := len(primary.Requests()); got != 0 {
+		t.Errorf("primary requests = %d, want 0", got)
+	}
+}
+
+func TestConsistencyReadsAndWritesUsePrimary(t *testing.T) {
+	c, primary, replicas := newReplicatedClient(t, 2)
+
+	if _, err := c.GetRoom("room1"); err != nil {
+		t.Fatalf("GetRoom: %v", err)
+	}
+	if _, _, err := c.GetRoomWithParticipants("room1"); err != nil {
+		t.Fatalf("GetRoomWithParticipants: %v", err)
+	}
+	if err := c.UpdateRoomActivity("room1"); err != nil {
+		t.Fatalf("UpdateRoomActivity: %v", err)
+	}
+
+	for i, replica := range replicas {
+		if got := len(replica.Requests()); got != 0 {
+			t.Errorf("replica %d requests = %d, want 0", i, got)
+		}
+	}
+	if got := len(primary.Requests()); got == 0 {
+		t.Error("primary received no requests")
+	}
+}
+
+func TestFailedReplicaReadFallsBackToPrimary(t *testing.T) {
+	c, primary, replicas := newReplicatedClient(t, 1)
+	replicas[0].FailNext("GET", "rooms", http.StatusServiceUnavailable, nil, "")
+
+	rooms, err := c.ListRoomsPage(10, 0)
+	if err != nil || len(rooms) != 1 {
+		t.Fatalf("ListRoomsPage = %v, %v, want one room from the primary", rooms, err)
+	}
+	if got := primary.CountRequests("GET", "rooms"); got != 1 {
+		t.Errorf("primary rooms reads = %d, want 1", got)
+	}
+
+	if _, err := c.ListRoomsPage(10, 0); err != nil {
+		t.Fatalf("ListRoomsPage after recovery: %v", err)
+	}
+	if got := replicas[0].CountRequests("GET", "rooms"); got != 2 {
+		t.Errorf("replica rooms reads = %d, want 2", got)
+	}
+}
+
+func TestAPIErrorPreservesStatus(t *testing.T) {
+	srv := supabasetest.NewServer(t)
+	c := NewClient(srv.Config())
+	seedRoom(srv, "room1", 0)
+
+	body := `{"code":"23505","message":"duplicate key value violates unique constraint"}`
+	srv.FailNext("POST", "rooms", http.StatusConflict, nil, body)
+	err := c.CreateRoom(&models.Room{ID: "room2", Name: "Room", KeyVersion: 1})
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("err = %v, want an *APIError", err)
+	}
+	if apiErr.StatusCode != http.StatusConflict || apiErr.Body != body {
+		t.Errorf("APIError = %d %q, want 409 with the response body", apiErr.StatusCode, apiErr.Body)
+	}
+	if !IsStatus(err, http.StatusConflict) || IsStatus(err, http.StatusInternalServerError) {
+		t.Errorf("IsStatus does not match only the 409")
+	}
+
+	srv.FailNext("PATCH", "rooms", http.StatusInternalServerError, nil, "boom")
+	if err := c.UpdateRoomActivity("room1"); !IsStatus(err, http.StatusInternalServerError) {
+		t.Errorf("UpdateRoomActivity err = %v, want status 500", err)
+	}
+	if IsStatus(errors.New("plain"), http.StatusConflict) {
+		t.Error("IsStatus matched an error without a status")
+	}
+}
+
+func TestDeleteRoomIfEmpty(t *testing.T) {
+	srv := supabasetest.NewServer(t)
+	c := NewClient(srv.Config())
+	seedRoom(srv, "empty", 0)
+	seedRoom(srv, "occupied", 1)
+
+	deleted, err := c.DeleteRoomIfEmpty("empty")
+	if err != nil || !deleted {
+		t.Errorf("empty room: deleted = %v, err = %v, want deleted", deleted, err)
+	}
+	if _, ok := srv.Room("empty"); ok {
+		t.Error("empty room still exists")
+	}
+
+	deleted, err = c.DeleteRoomIfEmpty("occupied")
+	if err != nil || deleted {
+		t.Errorf("occupied room: deleted = %v, err = %v, want kept", deleted, err)
+	}
+	if _, ok := srv.Room("occupied"); !ok {
+		t.Error("occupied room was deleted")
+	}
+
+	deleted, err = c.DeleteRoomIfEmpty("missing")
+	if err != nil || deleted {
+		t.Errorf("missing room: deleted = %v, err = %v, want false without error", deleted, err)
+	}
+
+	// Each check is a single conditional request, not a count followed by a delete
+	if got := srv.CountRequests("POST", "rpc/delete_room_if_empty"); got != 3 || len(srv.Requests()) != 3 {
+		t.Errorf("requests = %d (%d rpc), want exactly one rpc call per room", len(srv.Requests()), got)
+	}
+}
+
+func TestDeleteRoomIfEmptyWithoutMigration(t *testing.T) {
+	srv := supabasetest.NewServer(t)
+	c := NewClient(srv.Config())
+	seedRoom(srv, "empty", 0)
+	srv.DropFunction("delete_room_if_empty")
+
+	if _, err := c.DeleteRoomIfEmpty("empty"); err == nil {
+		t.Error("DeleteRoomIfEmpty without the function succeeded")
+	}
+	if _, ok := srv.Room("empty"); !ok {
+		t.Error("room was deleted without the conditional function")
+	}
+}
+
+func TestGetRooms(t *testing.T) {
+	srv := supabasetest.NewServer(t)
+	c := NewClient(srv.Config())
+	for _, id := range []string{"a", "b", "c"} {
+		seedRoom(srv, id, 0)
+	}
+
+	rooms, err := c.GetRooms([]string{"c", "a", "missing", "c", "b"})
+	if err != nil {
+		t.Fatalf("GetRooms: %v", err)
+	}
+	var ids []string
+	for _, room := range rooms {
+		ids = append(ids, room.ID)
+	}
+	if !slices.Equal(ids, []string{"c", "a", "b"}) {
+		t.Errorf("rooms = %v, want [c a b] in request order without duplicates", ids)
+	}
+
+	reqs := srv.Requests()
+	if len(reqs) != 1 {
+		t.Fatalf("requests = %d, want 1", len(reqs))
+	}
+	if got := reqs[0].Query.Get("id"); got != `in.("c","a","missing","b")` {
+		t.Errorf("id filter = %s, want the deduplicated quoted in. list", got)
+	}
+
+	srv.ResetRequests()
+	if rooms, err := c.GetRooms(nil); err != nil || rooms == nil || len(rooms) != 0 {
+		t.Errorf("no IDs: rooms = %v, err = %v, want empty non-nil slice", rooms, err)
+	}
+	if got := len(srv.Requests()); got != 0 {
+		t.Errorf("no IDs: requests = %d, want 0", got)
+	}
+}
+
+func TestInListQuotesValues(t *testing.T) {
+	if got, want := inList([]string{`a,b`, `c"d`, `e)`}), `"a,b","c\"d","e)"`; got != want {
+		t.Errorf("inList = %s, want %s", got, want)
+	}
+}
+
+func TestCountsUseContentRangeWithoutRows(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Prefer") != "count=exact" {
+			t.Errorf("%s: Prefer = %q, want count=exact", r.URL.Path, r.Header.Get("Prefer"))
+		}
+		if r.URL.Query().Get("limit") != "0" {
+			t.Errorf("%s: limit = %q, want 0 so no rows are fetched", r.URL.Path, r.URL.Query().Get("limit"))
+		}
+		switch r.URL.Path {
+		case "/rest/v1/rooms":
+			w.Header().Set("Content-Range", "*/3573")
+		case "/rest/v1/participants":
+			w.Header().Set("Content-Range", "*/12")
+		}
+		w.Write([]byte("[]"))
+	}))
+	t.Cleanup(srv.Close)
+	c := NewClient(&config.Config{SupabaseURL: srv.URL, SupabaseKey: "test-service-key"})
+
+	if n, err := c.CountRooms(); err != nil || n != 3573 {
+		t.Errorf("CountRooms = %d, %v; want 3573", n, err)
+	}
+	if n, err := c.CountAllParticipants(); err != nil || n != 12 {
+		t.Errorf("CountAllParticipants = %d, %v; want 12", n, err)
+	}
+}
+
+func TestParseContentRangeTotal(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    int
+		wantErr bool
+	}{
+		{"0-24/3573", 3573, false},
+		{"*/0", 0, false},
+		{"*/42", 42, false},
+		{"", 0, true},
+		{"0-24/*", 0, true},
+		{"0-24", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseContentRangeTotal(tt.value)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseContentRangeTotal(%q) = %d, %v; want %d (error %v)", tt.value, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package supabase
+
+import (
+	"fmt"
+	"strings"
+)
+
+// expectedSchema lists the columns the backend reads or writes, per table.
+// Keep in sync with the models and the migrations directory.
+var expectedSchema = []struct {
+	table   string
+	columns []string
+}{
+	{"rooms", []string{
+		"id", "name", "encryption_key", "created_at", "last_active_at",
+		"host_participant_id", "locked", "message_ttl_seconds", "persist", "key_version", "key_salt",
+		"require_signatures",
+	}},
+	{"participants", []string{
+		"id", "room_id", "username", "avatar", "joined_at", "last_active_at",
+		"role", "status",
+	}},
+}
+
+// expectedRPCs lists the database functions the backend calls, with the
+// arguments used to probe them. Probes must not change any data.
+var expectedRPCs = []struct {
+	name      string
+	migration string
+	args      map[string]interface{}
+}{
+	// Room IDs never contain underscores, so the probe can't delete a real room
+	{"delete_room_if_empty", "008_delete_room_if_empty.sql", map[string]interface{}{"p_room_id": "_schema_check"}},
+}
+
+// CheckSchema verifies that every expected table, column and function exists,
+// so schema drift is reported clearly at startup instead of as a PostgREST
+// error on the first request. Each table is probed with a limit=0 select of
+// its columns; if that fails, columns are probed one by one to name the
+// missing one. Functions are probed by calling them with harmless arguments.
+func (c *Client) CheckSchema() error {
+	for _, t := range expectedSchema {
+		if c.probeColumns(t.table, t.columns) == nil {
+			continue
+		}
+
+		// Narrow the failure down to a table or a specific column
+		if err := c.probeColumns(t.table, []string{"*"}); err != nil {
+			return fmt.Errorf("table %q is missing or unreadable: %w", t.table, err)
+		}
+		for _, column := range t.columns {
+			if err := c.probeColumns(t.table, []string{column}); err != nil {
+				return fmt.Errorf("table %q is missing column %q (apply the migrations in backend/migrations): %w", t.table, column, err)
+			}
+		}
+		return fmt.Errorf("table %q failed the schema check", t.table)
+	}
+
+	for _, rpc := range expectedRPCs {
+		if _, err := c.doRequest("POST", "rpc/"+rpc.name, rpc.args); err != nil {
+			return fmt.Errorf("function %q is missing or failing (apply migration %s): %w", rpc.name, rpc.migration, err)
+		}
+	}
+	return nil
+}
+
+// probeColumns selects the given columns from a table without fetching any rows.
+func (c *Client) probeColumns(table string, columns []string) error {
+	endpoint := fmt.Sprintf("%s?select=%s&limit=0", table, strings.Join(columns, ","))
+	_, err := c.doRequest("GET", endpoint, nil)
+	return err
+}
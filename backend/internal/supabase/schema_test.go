@@ -0,0 +1,59 @@
+package supabase
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/adi-253/Talkie/backend/internal/supabase/supabasetest"
+)
+
+func TestCheckSchemaPasses(t *testing.T) {
+	srv := supabasetest.NewServer(t)
+	seedRoom(srv, "room1", 1)
+
+	if err := NewClient(srv.Config()).CheckSchema(); err != nil {
+		t.Fatalf("CheckSchema: %v", err)
+	}
+	if _, ok := srv.Room("room1"); !ok {
+		t.Error("schema check changed data")
+	}
+}
+
+func TestCheckSchemaNamesMissingColumn(t *testing.T) {
+	srv := supabasetest.NewServer(t)
+	srv.DropColumn("participants", "status")
+
+	err := NewClient(srv.Config()).CheckSchema()
+	if err == nil {
+		t.Fatal("CheckSchema succeeded with a missing column")
+	}
+	if msg := err.Error(); !strings.Contains(msg, `table "participants" is missing column "status"`) {
+		t.Errorf("error = %q, want it to name participants.status", msg)
+	}
+}
+
+func TestCheckSchemaReportsMissingTable(t *testing.T) {
+	srv := supabasetest.NewServer(t)
+	body := `{"code":"42P01","message":"relation \"public.rooms\" does not exist"}`
+	srv.FailNext("GET", "rooms", http.StatusNotFound, nil, body)
+	srv.FailNext("GET", "rooms", http.StatusNotFound, nil, body)
+
+	err := NewClient(srv.Config()).CheckSchema()
+	if err == nil || !strings.Contains(err.Error(), `table "rooms" is missing or unreadable`) {
+		t.Errorf("error = %v, want the rooms table reported missing", err)
+	}
+}
+
+func TestCheckSchemaReportsMissingFunction(t *testing.T) {
+	srv := supabasetest.NewServer(t)
+	srv.DropFunction("delete_room_if_empty")
+
+	err := NewClient(srv.Config()).CheckSchema()
+	if err == nil || !strings.Contains(err.Error(), `function "delete_room_if_empty" is missing`) {
+		t.Errorf("error = %v, want delete_room_if_empty reported missing", err)
+	}
+	if err != nil && !strings.Contains(err.Error(), "008_delete_room_if_empty.sql") {
+		t.Errorf("error = %v, want it to name the migration", err)
+	}
+}
@@ -0,0 +1,818 @@
+// Package supabasetest provides an in-memory fake of the parts of the Supabase
+// REST (PostgREST) and Realtime broadcast APIs the backend uses, for tests.
+//
+// The fake implements the rooms and participants tables with the filters,
+// ordering, paging, embedding and count queries issued by supabase.Client,
+// the delete_room_if_empty function, and records every broadcast. Faults can
+// be injected per request to exercise error paths.
+package supabasetest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/adi-253/Talkie/backend/internal/config"
+	"github.com/adi-253/Talkie/backend/internal/models"
+	"github.com/google/uuid"
+)
+
+// row is a table row as decoded from JSON.
+type row = map[string]interface{}
+
+// columnDefaults lists each table's columns with the default used when an
+// insert omits them (nil means NULL). Mirrors backend/migrations.
+var columnDefaults = map[string]map[string]interface{}{
+	"rooms": {
+		"id": nil, "name": "Untitled Room", "encryption_key": nil,
+		"created_at": nil, "last_active_at": nil,
+		"host_participant_id": nil, "locked": false, "message_ttl_seconds": float64(0),
+		"persist": true, "key_version": float64(1), "key_salt": "", "require_signatures": false,
+	},
+	"participants": {
+		"id": nil, "room_id": nil, "username": nil, "avatar": "",
+		"joined_at": nil, "last_active_at": nil, "role": "participant", "status": "",
+	},
+}
+
+// Broadcast is a Realtime broadcast message received by the fake.
+type Broadcast struct {
+	Topic   string          `json:"topic"`
+	Event   string          `json:"event"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// Request is a REST request received by the fake.
+type Request struct {
+	Method string
+	// Path is relative to /rest/v1/, e.g. "rooms" or "rpc/delete_room_if_empty"
+	Path  string
+	Query url.Values
+}
+
+// fault is a one-shot injected response.
+type fault struct {
+	method string
+	path   string
+	status int
+	header http.Header
+	body   string
+}
+
+// Server is a fake Supabase project backed by an httptest.Server.
+type Server struct {
+	*httptest.Server
+
+	// OnRequest, if set, is called before each REST request is handled,
+	// without the server's lock held, e.g. to simulate a concurrent change.
+	OnRequest func(Request)
+
+	mu         sync.Mutex
+	tables     map[string][]row
+	columns    map[string]map[string]bool
+	rpcs       map[string]bool
+	noEmbed    bool
+	faults     []fault
+	requests   []Request
+	broadcasts []Broadcast
+	// broadcastCalls counts Realtime broadcast requests (each may carry several messages)
+	broadcastCalls int
+}
+
+// NewServer starts a fake Supabase project that is closed when the test ends.
+func NewServer(t testing.TB) *Server {
+	s := &Server{
+		tables:  map[string][]row{"rooms": nil, "participants": nil},
+		columns: make(map[string]map[string]bool),
+		rpcs:    map[string]bool{"delete_room_if_empty": true},
+	}
+	for table, defaults := range columnDefaults {
+		s.columns[table] = make(map[string]bool)
+		for column := range defaults {
+			s.columns[table][column] = true
+		}
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// Config returns a config pointing a supabase.Client at the fake.
+func (s *Server) Config() *config.Config {
+	return &config.Config{SupabaseURL: s.URL, SupabaseKey: "test-service-key"}
+}
+
+// AddRoom inserts a room row directly.
+func (s *Server) AddRoom(room models.Room) {
+	s.insertModel("rooms", room)
+}
+
+// AddParticipant inserts a participant row directly.
+func (s *Server) AddParticipant(participant models.Participant) {
+	s.insertModel("participants", participant)
+}
+
+// Room returns a stored room.
+func (s *Server) Room(id string) (models.Room, bool) {
+	var rooms []models.Room
+	s.selectModels("rooms", "id", id, &rooms)
+	if len(rooms) == 0 {
+		return models.Room{}, false
+	}
+	return rooms[0], true
+}
+
+// Rooms returns all stored rooms.
+func (s *Server) Rooms() []models.Room {
+	var rooms []models.Room
+	s.selectModels("rooms", "", "", &rooms)
+	return rooms
+}
+
+// Participant returns a stored participant.
+func (s *Server) Participant(id string) (models.Participant, bool) {
+	var participants []models.Participant
+	s.selectModels("participants", "id", id, &participants)
+	if len(participants) == 0 {
+		return models.Participant{}, false
+	}
+	return participants[0], true
+}
+
+// Participants returns the stored participants of a room ("" for all rooms).
+func (s *Server) Participants(roomID string) []models.Participant {
+	var participants []models.Participant
+	if roomID == "" {
+		s.selectModels("participants", "", "", &participants)
+	} else {
+		s.selectModels("participants", "room_id", roomID, &participants)
+	}
+	return participants
+}
+
+// DeleteRoom deletes a room and its participants directly, e.g. to simulate
+// a concurrent delete.
+func (s *Server) DeleteRoom(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deleteWhereLocked("rooms", func(r row) bool { return r["id"] == id })
+}
+
+// DeleteParticipant deletes a participant directly.
+func (s *Server) DeleteParticipant(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deleteWhereLocked("participants", func(r row) bool { return r["id"] == id })
+}
+
+// DropColumn removes a column from a table, as if a migration wasn't applied.
+func (s *Server) DropColumn(table, column string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.columns[table], column)
+	for _, r := range s.tables[table] {
+		delete(r, column)
+	}
+}
+
+// DropFunction removes a database function, as if a migration wasn't applied.
+func (s *Server) DropFunction(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.rpcs, name)
+}
+
+// DisableEmbedding makes select=*,participants(*) fail like a PostgREST
+// instance that doesn't expose the relationship.
+func (s *Server) DisableEmbedding() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.noEmbed = true
+}
+
+// FailNext makes the next REST request with the given method and path (e.g.
+// "GET", "participants") respond with status and body. Faults are consumed in
+// the order they were added.
+func (s *Server) FailNext(method, path string, status int, header http.Header, body string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = append(s.faults, fault{method: method, path: path, status: status, header: header, body: body})
+}
+
+// Requests returns the REST requests received so far.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// CountRequests returns how many REST requests matched method and path.
+func (s *Server) CountRequests(method, path string) int {
+	n := 0
+	for _, r := range s.Requests() {
+		if r.Method == method && r.Path == path {
+			n++
+		}
+	}
+	return n
+}
+
+// ResetRequests forgets the recorded REST requests.
+func (s *Server) ResetRequests() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = nil
+}
+
+// Broadcasts returns the Realtime broadcasts received so far.
+func (s *Server) Broadcasts() []Broadcast {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Broadcast(nil), s.broadcasts...)
+}
+
+// BroadcastCalls returns the number of Realtime broadcast requests received.
+func (s *Server) BroadcastCalls() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.broadcastCalls
+}
+
+// BroadcastsFor returns the broadcasts received for an event.
+func (s *Server) BroadcastsFor(event string) []Broadcast {
+	var matched []Broadcast
+	for _, b := range s.Broadcasts() {
+		if b.Event == event {
+			matched = append(matched, b)
+		}
+	}
+	return matched
+}
+
+// insertModel stores a model as a row, applying column defaults.
+func (s *Server) insertModel(table string, model interface{}) {
+	data, err := json.Marshal(model)
+	if err != nil {
+		panic(err)
+	}
+	var r row
+	if err := json.Unmarshal(data, &r); err != nil {
+		panic(err)
+	}
+	delete(r, "duration_seconds")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tables[table] = append(s.tables[table], s.withDefaultsLocked(table, r))
+}
+
+// selectModels decodes the rows of a table where column equals value
+// (all rows if column is empty) into out.
+func (s *Server) selectModels(table, column, value string, out interface{}) {
+	s.mu.Lock()
+	var rows []row
+	for _, r := range s.tables[table] {
+		if column == "" || r[column] == value {
+			rows = append(rows, r)
+		}
+	}
+	data, err := json.Marshal(rows)
+	s.mu.Unlock()
+	if err != nil {
+		panic(err)
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		panic(err)
+	}
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("apikey") == "" {
+		writeJSON(w, http.StatusUnauthorized, pgError("", "missing apikey"))
+		return
+	}
+
+	if r.URL.Path == "/realtime/v1/api/broadcast" && r.Method == http.MethodPost {
+		s.serveBroadcast(w, r)
+		return
+	}
+
+	path, ok := strings.CutPrefix(r.URL.Path, "/rest/v1/")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	req := Request{Method: r.Method, Path: path, Query: r.URL.Query()}
+
+	if s.OnRequest != nil {
+		s.OnRequest(req)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, req)
+
+	for i, f := range s.faults {
+		if f.method == r.Method && f.path == path {
+			s.faults = append(s.faults[:i], s.faults[i+1:]...)
+			for key, values := range f.header {
+				w.Header()[key] = values
+			}
+			w.WriteHeader(f.status)
+			io.WriteString(w, f.body)
+			return
+		}
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, pgError("", err.Error()))
+		return
+	}
+
+	if name, ok := strings.CutPrefix(path, "rpc/"); ok {
+		s.serveRPCLocked(w, name, body)
+		return
+	}
+	if _, ok := s.tables[path]; !ok {
+		writeJSON(w, http.StatusNotFound, pgError("42P01", fmt.Sprintf("relation %q does not exist", path)))
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		s.serveSelectLocked(w, r, path)
+	case http.MethodPost:
+		s.serveInsertLocked(w, path, body)
+	case http.MethodPatch:
+		s.serveUpdateLocked(w, r, path, body)
+	case http.MethodDelete:
+		s.serveDeleteLocked(w, r, path)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) serveBroadcast(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Messages []Broadcast `json:"messages"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeJSON(w, http.StatusBadRequest, pgError("", err.Error()))
+		return
+	}
+
+	s.mu.Lock()
+	s.broadcasts = append(s.broadcasts, body.Messages...)
+	s.broadcastCalls++
+	s.mu.Unlock()
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (s *Server) serveRPCLocked(w http.ResponseWriter, name string, body []byte) {
+	if !s.rpcs[name] {
+		writeJSON(w, http.StatusNotFound, pgError("PGRST202", fmt.Sprintf("Could not find the function public.%s", name)))
+		return
+	}
+
+	var args map[string]interface{}
+	if err := json.Unmarshal(body, &args); err != nil {
+		writeJSON(w, http.StatusBadRequest, pgError("", err.Error()))
+		return
+	}
+
+	switch name {
+	case "delete_room_if_empty":
+		roomID := args["p_room_id"]
+		for _, p := range s.tables["participants"] {
+			if p["room_id"] == roomID {
+				writeJSON(w, http.StatusOK, []row{})
+				return
+			}
+		}
+		deleted := s.deleteWhereLocked("rooms", func(r row) bool { return r["id"] == roomID })
+		writeJSON(w, http.StatusOK, deleted)
+	}
+}
+
+func (s *Server) serveSelectLocked(w http.ResponseWriter, r *http.Request, table string) {
+	query := r.URL.Query()
+
+	columns, embed, err := s.parseSelectLocked(table, query.Get("select"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, err)
+		return
+	}
+
+	rows, perr := s.filterLocked(table, query)
+	if perr != nil {
+		writeJSON(w, http.StatusBadRequest, perr)
+		return
+	}
+	total := len(rows)
+
+	if order := query.Get("order"); order != "" {
+		sortRows(rows, order)
+	}
+	if offset, err := strconv.Atoi(query.Get("offset")); err == nil {
+		rows = rows[min(offset, len(rows)):]
+	}
+	if limit, err := strconv.Atoi(query.Get("limit")); err == nil {
+		rows = rows[:min(limit, len(rows))]
+	}
+
+	result := make([]row, len(rows))
+	for i, src := range rows {
+		out := make(row)
+		for _, column := range columns {
+			out[column] = src[column]
+		}
+		if embed {
+			related := []row{}
+			for _, p := range s.tables["participants"] {
+				if p["room_id"] == src["id"] {
+					related = append(related, p)
+				}
+			}
+			out["participants"] = related
+		}
+		result[i] = out
+	}
+
+	if strings.Contains(r.Header.Get("Prefer"), "count=exact") {
+		if len(result) == 0 {
+			w.Header().Set("Content-Range", fmt.Sprintf("*/%d", total))
+		} else {
+			w.Header().Set("Content-Range", fmt.Sprintf("0-%d/%d", len(result)-1, total))
+		}
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (s *Server) serveInsertLocked(w http.ResponseWriter, table string, body []byte) {
+	var rows []row
+	if err := json.Unmarshal(body, &rows); err != nil {
+		var single row
+		if err := json.Unmarshal(body, &single); err != nil {
+			writeJSON(w, http.StatusBadRequest, pgError("", err.Error()))
+			return
+		}
+		rows = []row{single}
+	}
+
+	inserted := make([]row, 0, len(rows))
+	for _, r := range rows {
+		if perr := s.checkColumnsLocked(table, r); perr != nil {
+			writeJSON(w, http.StatusBadRequest, perr)
+			return
+		}
+		r = s.withDefaultsLocked(table, r)
+		for _, existing := range s.tables[table] {
+			if existing["id"] == r["id"] {
+				writeJSON(w, http.StatusConflict, pgError("23505", "duplicate key value violates unique constraint \""+table+"_pkey\""))
+				return
+			}
+		}
+		if table == "participants" && !s.roomExistsLocked(r["room_id"]) {
+			writeJSON(w, http.StatusConflict, pgError("23503", "insert or update on table \"participants\" violates foreign key constraint"))
+			return
+		}
+		inserted = append(inserted, r)
+	}
+	s.tables[table] = append(s.tables[table], inserted...)
+	writeJSON(w, http.StatusCreated, inserted)
+}
+
+func (s *Server) serveUpdateLocked(w http.ResponseWriter, r *http.Request, table string, body []byte) {
+	var changes row
+	if err := json.Unmarshal(body, &changes); err != nil {
+		writeJSON(w, http.StatusBadRequest, pgError("", err.Error()))
+		return
+	}
+	if perr := s.checkColumnsLocked(table, changes); perr != nil {
+		writeJSON(w, http.StatusBadRequest, perr)
+		return
+	}
+	if roomID, ok := changes["room_id"]; ok && table == "participants" && !s.roomExistsLocked(roomID) {
+		writeJSON(w, http.StatusConflict, pgError("23503", "insert or update on table \"participants\" violates foreign key constraint"))
+		return
+	}
+
+	rows, perr := s.filterLocked(table, r.URL.Query())
+	if perr != nil {
+		writeJSON(w, http.StatusBadRequest, perr)
+		return
+	}
+	for _, row := range rows {
+		for column, value := range changes {
+			row[column] = value
+		}
+	}
+	writeJSON(w, http.StatusOK, rows)
+}
+
+func (s *Server) serveDeleteLocked(w http.ResponseWriter, r *http.Request, table string) {
+	matched, perr := s.filterLocked(table, r.URL.Query())
+	if perr != nil {
+		writeJSON(w, http.StatusBadRequest, perr)
+		return
+	}
+	match := make(map[interface{}]bool, len(matched))
+	for _, row := range matched {
+		match[row["id"]] = true
+	}
+	deleted := s.deleteWhereLocked(table, func(r row) bool { return match[r["id"]] })
+	writeJSON(w, http.StatusOK, deleted)
+}
+
+// deleteWhereLocked deletes matching rows, cascading from rooms to their participants.
+func (s *Server) deleteWhereLocked(table string, match func(row) bool) []row {
+	deleted := []row{}
+	kept := s.tables[table][:0]
+	for _, r := range s.tables[table] {
+		if match(r) {
+			deleted = append(deleted, r)
+		} else {
+			kept = append(kept, r)
+		}
+	}
+	s.tables[table] = kept
+
+	if table == "rooms" && len(deleted) > 0 {
+		roomIDs := make(map[interface{}]bool, len(deleted))
+		for _, r := range deleted {
+			roomIDs[r["id"]] = true
+		}
+		s.deleteWhereLocked("participants", func(p row) bool { return roomIDs[p["room_id"]] })
+	}
+	return deleted
+}
+
+func (s *Server) roomExistsLocked(id interface{}) bool {
+	for _, r := range s.tables["rooms"] {
+		if r["id"] == id {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) withDefaultsLocked(table string, r row) row {
+	now := time.Now().UTC().Format(time.RFC3339Nano)
+	out := make(row, len(columnDefaults[table]))
+	for column, def := range columnDefaults[table] {
+		if !s.columns[table][column] {
+			continue
+		}
+		if value, ok := r[column]; ok {
+			out[column] = value
+			continue
+		}
+		switch column {
+		case "id":
+			out[column] = uuid.New().String()
+		case "created_at", "last_active_at", "joined_at":
+			out[column] = now
+		default:
+			out[column] = def
+		}
+	}
+	return out
+}
+
+func (s *Server) checkColumnsLocked(table string, r row) *postgrestError {
+	for column := range r {
+		if !s.columns[table][column] {
+			return pgError("PGRST204", fmt.Sprintf("Could not find the '%s' column of '%s' in the schema cache", column, table))
+		}
+	}
+	return nil
+}
+
+// parseSelectLocked resolves a select param to the selected columns and
+// whether participants are embedded.
+func (s *Server) parseSelectLocked(table, sel string) ([]string, bool, *postgrestError) {
+	if sel == "" {
+		sel = "*"
+	}
+	var columns []string
+	embed := false
+	for _, item := range strings.Split(sel, ",") {
+		switch {
+		case item == "*":
+			for column := range s.columns[table] {
+				columns = append(columns, column)
+			}
+		case item == "participants(*)" && table == "rooms":
+			if s.noEmbed {
+				return nil, false, pgError("PGRST200", "Could not find a relationship between 'rooms' and 'participants' in the schema cache")
+			}
+			embed = true
+		case s.columns[table][item]:
+			columns = append(columns, item)
+		default:
+			return nil, false, pgError("42703", fmt.Sprintf("column %s.%s does not exist", table, item))
+		}
+	}
+	return columns, embed, nil
+}
+
+// filterLocked returns the rows matching the query's column filters.
+func (s *Server) filterLocked(table string, query url.Values) ([]row, *postgrestError) {
+	type filter struct {
+		column, op, arg string
+	}
+	var filters []filter
+	for key, values := range query {
+		switch key {
+		case "select", "order", "limit", "offset":
+			continue
+		}
+		if !s.columns[table][key] {
+			return nil, pgError("42703", fmt.Sprintf("column %s.%s does not exist", table, key))
+		}
+		for _, value := range values {
+			op, arg, ok := strings.Cut(value, ".")
+			if !ok {
+				return nil, pgError("PGRST100", fmt.Sprintf("failed to parse filter (%s)", value))
+			}
+			filters = append(filters, filter{key, op, arg})
+		}
+	}
+
+	var rows []row
+	for _, r := range s.tables[table] {
+		matches := true
+		for _, f := range filters {
+			ok, err := matchFilter(r[f.column], f.op, f.arg)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			rows = append(rows, r)
+		}
+	}
+	return rows, nil
+}
+
+// matchFilter applies a PostgREST operator to a column value.
+func matchFilter(value interface{}, op, arg string) (bool, *postgrestError) {
+	switch op {
+	case "eq":
+		return value != nil && formatValue(value) == arg, nil
+	case "neq":
+		return value != nil && formatValue(value) != arg, nil
+	case "lt", "lte", "gt", "gte":
+		if value == nil {
+			return false, nil
+		}
+		c := compareValues(value, arg)
+		switch op {
+		case "lt":
+			return c < 0, nil
+		case "lte":
+			return c <= 0, nil
+		case "gt":
+			return c > 0, nil
+		default:
+			return c >= 0, nil
+		}
+	case "is":
+		switch arg {
+		case "null":
+			return value == nil, nil
+		case "true", "false":
+			return value != nil && formatValue(value) == arg, nil
+		}
+	case "in":
+		list, err := parseInList(arg)
+		if err != nil {
+			return false, err
+		}
+		for _, item := range list {
+			if value != nil && formatValue(value) == item {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	return false, pgError("PGRST100", fmt.Sprintf("unsupported operator %q", op))
+}
+
+// parseInList parses the argument of an in.(...) filter, honoring double quotes.
+func parseInList(arg string) ([]string, *postgrestError) {
+	if !strings.HasPrefix(arg, "(") || !strings.HasSuffix(arg, ")") {
+		return nil, pgError("PGRST100", fmt.Sprintf("failed to parse in list %q", arg))
+	}
+	inner := arg[1 : len(arg)-1]
+
+	var items []string
+	var current strings.Builder
+	quoted, escaped := false, false
+	for _, ch := range inner {
+		switch {
+		case escaped:
+			current.WriteRune(ch)
+			escaped = false
+		case quoted && ch == '\\':
+			escaped = true
+		case ch == '"':
+			quoted = !quoted
+		case ch == ',' && !quoted:
+			items = append(items, current.String())
+			current.Reset()
+		default:
+			current.WriteRune(ch)
+		}
+	}
+	return append(items, current.String()), nil
+}
+
+// formatValue renders a JSON value the way it appears in a filter argument.
+func formatValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return fmt.Sprint(value)
+}
+
+// compareValues orders a column value against a filter argument, comparing
+// timestamps and numbers by value and anything else as strings.
+func compareValues(value interface{}, arg string) int {
+	left := formatValue(value)
+	if lt, err := time.Parse(time.RFC3339Nano, left); err == nil {
+		if rt, err := time.Parse(time.RFC3339Nano, arg); err == nil {
+			return lt.Compare(rt)
+		}
+	}
+	if lf, ok := value.(float64); ok {
+		if rf, err := strconv.ParseFloat(arg, 64); err == nil {
+			switch {
+			case lf < rf:
+				return -1
+			case lf > rf:
+				return 1
+			}
+			return 0
+		}
+	}
+	return strings.Compare(left, arg)
+}
+
+// sortRows sorts rows by an order param such as "created_at.desc,id.asc".
+func sortRows(rows []row, order string) {
+	keys := strings.Split(order, ",")
+	sort.SliceStable(rows, func(i, j int) bool {
+		for _, key := range keys {
+			column, dir, _ := strings.Cut(key, ".")
+			a, b := rows[i][column], rows[j][column]
+			if a == nil || b == nil {
+				continue
+			}
+			c := compareValues(a, formatValue(b))
+			if c == 0 {
+				continue
+			}
+			if strings.HasPrefix(dir, "desc") {
+				return c > 0
+			}
+			return c < 0
+		}
+		return false
+	})
+}
+
+// postgrestError is the JSON error body PostgREST responds with.
+type postgrestError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func pgError(code, message string) *postgrestError {
+	return &postgrestError{Code: code, Message: message}
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,6 @@
+-- Room host and locking
+-- The first participant to join a room becomes its host. Hosts can lock a room
+-- to prevent new participants from joining (existing participants may rejoin).
+
+ALTER TABLE rooms ADD COLUMN IF NOT EXISTS host_participant_id TEXT;
+ALTER TABLE rooms ADD COLUMN IF NOT EXISTS locked BOOLEAN NOT NULL DEFAULT FALSE;
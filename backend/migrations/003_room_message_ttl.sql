@@ -0,0 +1,5 @@
+-- Per-room message TTL
+-- Messages older than this many seconds are dropped from the in-memory history
+-- while the room is still live. 0 keeps messages until the room is deleted.
+
+ALTER TABLE rooms ADD COLUMN IF NOT EXISTS message_ttl_seconds INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,4 @@
+-- Participant roles
+-- Observers can read messages and appear in presence, but cannot send.
+
+ALTER TABLE participants ADD COLUMN IF NOT EXISTS role TEXT NOT NULL DEFAULT 'participant';
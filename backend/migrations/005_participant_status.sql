@@ -0,0 +1,4 @@
+-- Participant custom status
+-- A short optional status such as "away" or "brb", shown in presence.
+
+ALTER TABLE participants ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,6 @@
+-- Room key epochs
+-- Clients derive per-epoch message keys from the room's encryption key, salt
+-- and version. Rotating the key bumps the version and replaces the salt.
+
+ALTER TABLE rooms ADD COLUMN IF NOT EXISTS key_version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE rooms ADD COLUMN IF NOT EXISTS key_salt TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,4 @@
+-- Per-room message persistence
+-- Rooms with persist = false never store messages; they are only relayed live.
+
+ALTER TABLE rooms ADD COLUMN IF NOT EXISTS persist BOOLEAN NOT NULL DEFAULT TRUE;
@@ -0,0 +1,14 @@
+-- Conditional room deletion
+-- Deletes a room only if it has no participants, in one statement, so a
+-- concurrent join can't land between counting participants and deleting.
+-- Returns the deleted row (none if the room was occupied or missing).
+
+CREATE OR REPLACE FUNCTION delete_room_if_empty(p_room_id TEXT)
+RETURNS SETOF rooms
+LANGUAGE sql
+AS $$
+    DELETE FROM rooms
+    WHERE id = p_room_id
+      AND NOT EXISTS (SELECT 1 FROM participants WHERE room_id = p_room_id)
+    RETURNING *;
+$$;
@@ -0,0 +1,5 @@
+-- Opt-in message signing
+-- Rooms with require_signatures = true only accept messages carrying a valid
+-- HMAC signature made with the sender's per-participant secret.
+
+ALTER TABLE rooms ADD COLUMN IF NOT EXISTS require_signatures BOOLEAN NOT NULL DEFAULT FALSE;
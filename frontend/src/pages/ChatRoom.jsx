@@ -135,9 +135,11 @@ export function ChatRoom() {
           for (const msg of existingMessages) {
             if (!seenMessageIds.has(msg.id)) {
               seenMessageIds.add(msg.id);
-              const decryptedContent = await decrypt(msg.content);
+              // System messages (e.g. the room welcome) are plaintext
+              const decryptedContent = msg.system ? msg.content : await decrypt(msg.content);
               setMessages(prev => [...prev, {
                 ...msg,
+                username: msg.system ? 'Talkie' : msg.username,
                 content: decryptedContent
               }]);
             }
@@ -220,8 +222,10 @@ export function ChatRoom() {
         await api.sendMessage(roomId, {
           participant_id: participantId,
           content: encryptedContent,
+          encrypted: true,
           username,
           avatar,
+          timestamp,
           reply_to: replyContext
         });
       } catch (err) {